@@ -0,0 +1,105 @@
+// mpq is a tool for inspecting the files of layered MPQ archives.
+//
+// Usage:
+//
+//    mpq [OPTION]... which [name]...
+//
+// Commands:
+//
+//    which
+//            Show the relative path of each file, and the layers which contain
+//            it in order of priority. The file is read from the first layer,
+//            which is marked with '*'.
+//
+// Flags:
+//
+//    -mpq=""
+//            Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of
+//            MPQ archives and directories in order of priority.
+//    -mpqdump="mpqdump/"
+//            Path to an extracted MPQ file.
+//    -mpqini="mpq.ini"
+//            Path to an ini file containing relative path information.
+//
+// The extracted MPQ archive of -mpqdump is the layer of lowest priority, unless
+// its priority is specified by listing it in -mpq (e.g. -mpq=mpqdump/,diabdat.mpq).
+//
+// Example:
+//
+//    $ mpq -mpq=mods/,patch_rt.mpq,diabdat.mpq which town.pal
+//    levels/towndata/town.pal
+//      * patch_rt.mpq
+//        diabdat.mpq
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mewrnd/blizzconv/mpq"
+)
+
+// Paths specified by command line flags.
+var archivePath, extractPath, mpqIniPath string
+
+func init() {
+	flag.Usage = usage
+	flag.StringVar(&archivePath, "mpq", "", "Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of MPQ archives and directories in order of priority.")
+	flag.StringVar(&extractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
+	flag.StringVar(&mpqIniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
+	flag.Parse()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]... which [name]...\n", os.Args[0])
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	if flag.NArg() < 2 || flag.Arg(0) != "which" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	archive, err := mpq.OpenArchive(extractPath, mpqIniPath, strings.Split(archivePath, ",")...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer archive.Close()
+	failed := false
+	for _, name := range flag.Args()[1:] {
+		err := which(archive, name)
+		if err != nil {
+			log.Println(err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// which prints the relative path of name, and the layers which contain it.
+func which(archive *mpq.Archive, name string) (err error) {
+	relPath, err := archive.GetRelPath(name)
+	if err != nil {
+		return err
+	}
+	layers, err := archive.Layers.Which(relPath)
+	if err != nil {
+		return err
+	}
+	fmt.Println(relPath)
+	for i, layer := range layers {
+		mark := " "
+		if i == 0 {
+			mark = "*"
+		}
+		fmt.Printf("  %s %s\n", mark, layer.Name)
+	}
+	return nil
+}
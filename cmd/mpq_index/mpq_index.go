@@ -0,0 +1,149 @@
+// mpq_index is a tool for generating the ini file which provides relative path
+// information for the files of an MPQ archive (e.g. mpq.ini).
+//
+// Usage:
+//
+//    mpq_index [OPTION]...
+//
+// Flags:
+//
+//    -dir=""
+//            Path to an extracted MPQ file.
+//    -listfile=""
+//            Path to a listfile.
+//    -mpq=""
+//            Path to an MPQ archive (e.g. DIABDAT.MPQ), whose (listfile) is used.
+//    -o="mpq.ini"
+//            Output path.
+//
+// At least one of -dir, -listfile or -mpq must be specified. Base names which
+// occur in more than one directory are reported to standard error.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mewrnd/blizzconv/mpq"
+)
+
+// Paths specified by command line flags.
+var dirPath, listfilePath, archivePath, outputPath string
+
+func init() {
+	flag.Usage = usage
+	flag.StringVar(&dirPath, "dir", "", "Path to an extracted MPQ file.")
+	flag.StringVar(&listfilePath, "listfile", "", "Path to a listfile.")
+	flag.StringVar(&archivePath, "mpq", "", "Path to an MPQ archive (e.g. DIABDAT.MPQ), whose (listfile) is used.")
+	flag.StringVar(&outputPath, "o", "mpq.ini", "Output path.")
+	flag.Parse()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]...\n", os.Args[0])
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	if dirPath == "" && listfilePath == "" && archivePath == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	var relPaths []string
+	if dirPath != "" {
+		paths, err := walkDir(dirPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		relPaths = append(relPaths, paths...)
+	}
+	if listfilePath != "" {
+		f, err := os.Open(listfilePath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		paths, err := mpq.ReadListfile(f)
+		f.Close()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		relPaths = append(relPaths, paths...)
+	}
+	if archivePath != "" {
+		paths, err := readArchiveListfile(archivePath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		relPaths = append(relPaths, paths...)
+	}
+
+	index, collisions := mpq.Index(relPaths)
+	var names []string
+	for name := range collisions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "collision: %q in %s\n", name, strings.Join(collisions[name], ", "))
+	}
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer f.Close()
+	err = mpq.WriteIndex(f, index)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// walkDir returns the relative paths of the files within dir.
+func walkDir(dir string) (relPaths []string, err error) {
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// Skip backups created by mpqfix.
+		if strings.HasSuffix(path, ".orig") {
+			return nil
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		relPaths = append(relPaths, filepath.ToSlash(relPath))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return relPaths, nil
+}
+
+// readArchiveListfile returns the file names of the (listfile) stored within
+// the MPQ archive located at archivePath.
+func readArchiveListfile(archivePath string) (relPaths []string, err error) {
+	archive, err := mpq.OpenReader(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+	buf, err := archive.ReadFile("(listfile)")
+	if err != nil {
+		return nil, err
+	}
+	return mpq.ReadListfile(bytes.NewReader(buf))
+}
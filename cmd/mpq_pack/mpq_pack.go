@@ -0,0 +1,104 @@
+// mpq_pack is a tool for creating an MPQ archive from an extracted MPQ file.
+//
+// Usage:
+//
+//    mpq_pack [OPTION]... -o=name.mpq
+//
+// Flags:
+//
+//    -encrypt=true
+//            Encrypt files.
+//    -implode=true
+//            Compress files using PKWARE implode.
+//    -mpqdump="mpqdump/"
+//            Path to an extracted MPQ file.
+//    -o=""
+//            Output path.
+//
+// The files of the archive are named by their path relative to -mpqdump.
+// Backups created by mpqfix (*.orig) are skipped.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mewrnd/blizzconv/mpq"
+)
+
+// Flags specified on the command line.
+var (
+	flagEncrypt, flagImplode bool
+	extractPath, outputPath  string
+)
+
+func init() {
+	flag.Usage = usage
+	flag.BoolVar(&flagEncrypt, "encrypt", true, "Encrypt files.")
+	flag.BoolVar(&flagImplode, "implode", true, "Compress files using PKWARE implode.")
+	flag.StringVar(&extractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
+	flag.StringVar(&outputPath, "o", "", "Output path.")
+	flag.Parse()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]... -o=name.mpq\n", os.Args[0])
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	if outputPath == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	err := pack(outputPath, extractPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// pack creates an MPQ archive at archivePath, which contains the files of dir.
+func pack(archivePath, dir string) (err error) {
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	mw := mpq.NewWriter(f)
+	mw.Encrypt = flagEncrypt
+	mw.Implode = flagImplode
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".orig") {
+			return nil
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		w, err := mw.Create(filepath.ToSlash(relPath))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(buf)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return mw.Close()
+}
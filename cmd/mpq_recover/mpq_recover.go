@@ -0,0 +1,374 @@
+// mpq_recover is a tool for recovering the names of files in an MPQ archive
+// which are not covered by the ini file or the listfile of the archive.
+//
+// Usage:
+//
+//    mpq_recover [OPTION]... -mpq=name.mpq
+//
+// Flags:
+//
+//    -dict=""
+//            Path to a dictionary of candidate file names, one per line.
+//    -mpq=""
+//            Path to an MPQ archive (e.g. DIABDAT.MPQ).
+//    -mpqini="mpq.ini"
+//            Path to an ini file containing relative path information.
+//    -o="recovered.ini"
+//            Output path of the recovered index entries.
+//    -templates="recover.ini"
+//            Path to an ini file of candidate file name patterns.
+//
+// Files are identified within the hash table of an MPQ archive by the hashes of
+// their names. Candidate names are taken from the dictionary and generated from
+// the pattern templates, and each candidate which matches the hashes of an
+// unknown hash entry is recovered. The recovered files are written as index
+// entries, in the format of the ini file.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mewbak/goini"
+	"github.com/mewrnd/blizzconv/mpq"
+)
+
+// Flags specified on the command line.
+var (
+	archivePath, mpqIniPath             string
+	dictPath, templatesPath, outputPath string
+)
+
+func init() {
+	flag.Usage = usage
+	flag.StringVar(&dictPath, "dict", "", "Path to a dictionary of candidate file names, one per line.")
+	flag.StringVar(&archivePath, "mpq", "", "Path to an MPQ archive (e.g. DIABDAT.MPQ).")
+	flag.StringVar(&mpqIniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
+	flag.StringVar(&outputPath, "o", "recovered.ini", "Output path of the recovered index entries.")
+	flag.StringVar(&templatesPath, "templates", "recover.ini", "Path to an ini file of candidate file name patterns.")
+	flag.Parse()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]... -mpq=name.mpq\n", os.Args[0])
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	if archivePath == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	err := recoverNames()
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// A hash identifies a file within the hash table of an MPQ archive.
+type hash [2]uint32
+
+// hashName returns the hash of the named file.
+func hashName(name string) hash {
+	nameA, nameB := mpq.HashName(name)
+	return hash{nameA, nameB}
+}
+
+// recoverNames recovers the names of the unknown files of the MPQ archive, and
+// writes them as index entries to outputPath.
+func recoverNames() (err error) {
+	rc, err := mpq.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	known, err := knownPaths(rc)
+	if err != nil {
+		return err
+	}
+
+	// Locate the hash entries of unknown files.
+	unknown := make(map[hash]bool)
+	knownHashes := map[hash]bool{hashName("(listfile)"): true}
+	for _, relPath := range known {
+		knownHashes[hashName(relPath)] = true
+	}
+	for _, entry := range rc.HashEntries() {
+		h := hash{entry.NameA, entry.NameB}
+		if !knownHashes[h] {
+			unknown[h] = true
+		}
+	}
+	total := len(unknown)
+	fmt.Printf("%d unknown hash entries.\n", total)
+	if total == 0 {
+		return nil
+	}
+
+	// Try candidate names.
+	var recovered []string
+	try := func(name string) {
+		name = mpq.CleanPath(name)
+		h := hashName(name)
+		if unknown[h] {
+			fmt.Printf("Recovered %q.\n", name)
+			recovered = append(recovered, name)
+			delete(unknown, h)
+		}
+	}
+	if dictPath != "" {
+		names, err := readDict(dictPath)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			try(name)
+		}
+	}
+	if templatesPath != "" {
+		templates, err := loadTemplates(templatesPath)
+		if err != nil {
+			return err
+		}
+		dirs := subdirs(append(known, recovered...))
+		for _, t := range templates {
+			err = t.expand(dirs, try)
+			if err != nil {
+				return fmt.Errorf("invalid template %q in %q; %v", t.name, templatesPath, err)
+			}
+		}
+	}
+	fmt.Printf("%d of %d unknown files recovered; %d remaining.\n", len(recovered), total, len(unknown))
+	if len(recovered) == 0 {
+		return nil
+	}
+	return writeIndex(outputPath, known, recovered)
+}
+
+// knownPaths returns the relative paths of the files provided by the ini file
+// and the listfile of the MPQ archive.
+func knownPaths(rc *mpq.ReadCloser) (relPaths []string, err error) {
+	archive, err := mpq.OpenArchive("", mpqIniPath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+	for _, name := range archive.Names() {
+		relPath, err := archive.GetRelPath(name)
+		if err != nil {
+			return nil, err
+		}
+		relPaths = append(relPaths, mpq.CleanPath(relPath))
+	}
+	if rc.Has("(listfile)") {
+		buf, err := rc.ReadFile("(listfile)")
+		if err != nil {
+			return nil, err
+		}
+		listed, err := mpq.ReadListfile(bytes.NewReader(buf))
+		if err != nil {
+			return nil, err
+		}
+		relPaths = append(relPaths, listed...)
+	}
+	return relPaths, nil
+}
+
+// readDict reads the candidate file names of the dictionary located at
+// dictPath.
+func readDict(dictPath string) (names []string, err error) {
+	f, err := os.Open(dictPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return mpq.ReadListfile(f)
+}
+
+// subdirs returns the set of directories of relPaths, including their parent
+// directories.
+func subdirs(relPaths []string) (dirs map[string]bool) {
+	dirs = make(map[string]bool)
+	for _, relPath := range relPaths {
+		for dir := path.Dir(relPath); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	return dirs
+}
+
+// A template specifies a pattern of candidate file names.
+type template struct {
+	// name is the section name of the template.
+	name string
+	// pattern contains variables of the form <var>.
+	pattern string
+	// vars contains the names of the variables of pattern, in order of first
+	// occurrence.
+	vars []string
+	// rawVals maps from variable names to their unexpanded values.
+	rawVals map[string]string
+}
+
+// varRegexp matches the variables of a pattern.
+var varRegexp = regexp.MustCompile(`<(\w+)>`)
+
+// loadTemplates loads the pattern templates located at templatesPath. Below is
+// an example template:
+//    [monster_trns]
+//    pattern = monsters/<dir>/<dir><suffix>.trn
+//    dir = $dirs:monsters
+//    suffix = ,b,r,y,$range:1-9
+func loadTemplates(templatesPath string) (templates []*template, err error) {
+	dict, err := ini.Load(templatesPath)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range dict {
+		if name == "" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := &template{name: name, rawVals: make(map[string]string)}
+		var found bool
+		t.pattern, found = dict.GetString(name, "pattern")
+		if !found {
+			return nil, fmt.Errorf("pattern not found for %q in %q.", name, templatesPath)
+		}
+		for _, m := range varRegexp.FindAllStringSubmatch(t.pattern, -1) {
+			v := m[1]
+			if _, ok := t.rawVals[v]; ok {
+				continue
+			}
+			t.rawVals[v], found = dict.GetString(name, v)
+			if !found {
+				return nil, fmt.Errorf("%s not found for %q in %q.", v, name, templatesPath)
+			}
+			t.vars = append(t.vars, v)
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+// expand calls fn for each candidate file name of the template. The $dirs
+// values are expanded using the known directories dirs.
+func (t *template) expand(dirs map[string]bool, fn func(name string)) (err error) {
+	vals := make([][]string, len(t.vars))
+	for i, v := range t.vars {
+		vals[i], err = expandVals(t.rawVals[v], dirs)
+		if err != nil {
+			return err
+		}
+	}
+	binding := make(map[string]string)
+	var walk func(i int)
+	walk = func(i int) {
+		if i == len(t.vars) {
+			fn(varRegexp.ReplaceAllStringFunc(t.pattern, func(s string) string {
+				return binding[s[1:len(s)-1]]
+			}))
+			return
+		}
+		for _, val := range vals[i] {
+			binding[t.vars[i]] = val
+			walk(i + 1)
+		}
+	}
+	walk(0)
+	return nil
+}
+
+// expandVals expands the comma-separated values of a variable.
+func expandVals(rawVals string, dirs map[string]bool) (vals []string, err error) {
+	seen := make(map[string]bool)
+	add := func(val string) {
+		if !seen[val] {
+			seen[val] = true
+			vals = append(vals, val)
+		}
+	}
+	for _, rawVal := range strings.Split(rawVals, ",") {
+		rawVal = strings.TrimSpace(rawVal)
+		switch {
+		case strings.HasPrefix(rawVal, "$dirs:"):
+			parent := mpq.CleanPath(rawVal[len("$dirs:"):])
+			var names []string
+			for dir := range dirs {
+				ok, err := path.Match(parent, path.Dir(dir))
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					names = append(names, path.Base(dir))
+				}
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				add(name)
+			}
+		case strings.HasPrefix(rawVal, "$range:"):
+			rawRange := rawVal[len("$range:"):]
+			posDelim := strings.Index(rawRange, "-")
+			if posDelim == -1 {
+				return nil, fmt.Errorf("no delim '-' found for %q", rawVal)
+			}
+			a, err := strconv.Atoi(rawRange[:posDelim])
+			if err != nil {
+				return nil, err
+			}
+			b, err := strconv.Atoi(rawRange[posDelim+1:])
+			if err != nil {
+				return nil, err
+			}
+			for n := a; n <= b; n++ {
+				add(strconv.Itoa(n))
+			}
+		default:
+			add(rawVal)
+		}
+	}
+	return vals, nil
+}
+
+// writeIndex writes the index entries of the recovered files to outputPath.
+// The names of the entries are based on all known files, to avoid collisions
+// with the names of the ini file.
+func writeIndex(outputPath string, known, recovered []string) (err error) {
+	index, _ := mpq.Index(append(known, recovered...))
+	isRecovered := make(map[string]bool)
+	for _, relPath := range recovered {
+		isRecovered[relPath] = true
+	}
+	for name, relPath := range index {
+		if !isRecovered[mpq.CleanPath(relPath)] {
+			delete(index, name)
+		}
+	}
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	err = mpq.WriteIndex(bw, index)
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
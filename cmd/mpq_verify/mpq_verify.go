@@ -0,0 +1,404 @@
+// mpq_verify is a tool for verifying the integrity of the files of an MPQ
+// archive, and for identifying the game version they belong to.
+//
+// Usage:
+//
+//    mpq_verify [OPTION]...
+//
+// Flags:
+//
+//    -gen=""
+//            Output path of a checksum table generated from the files.
+//    -manifest="mpqfix.ini"
+//            Path to a patch manifest of known-faulty files.
+//    -mpq=""
+//            Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of
+//            MPQ archives and directories in order of priority.
+//    -mpqdump="mpqdump/"
+//            Path to an extracted MPQ file.
+//    -mpqini="mpq.ini"
+//            Path to an ini file containing relative path information.
+//    -sums="sums/"
+//            Comma-separated list of checksum tables of known game versions, or
+//            directories of checksum tables.
+//    -version=""
+//            Game version of the checksum table generated by -gen.
+//
+// Each file listed in the ini file is hashed and reported as missing, faulty
+// (fixable by mpqfix), patched, corrupt or unknown (no checksum known). The
+// game version is identified by the checksum table which matches the most
+// files. Once identified, missing files which are not part of the game version
+// are ignored. Files which cannot be read or decompressed are reported as
+// corrupt. A missing default manifest contains no known-faulty files.
+//
+// A checksum table is an ini file with a version key and an md5 key for each
+// relative path. Patched files are recorded using the checksum of their
+// unpatched version. The checksum tables of the sums/ directory are used by
+// default, and the table of a verified install is generated using -gen (e.g.
+// -gen=sums/diablo_109b.ini -version="Diablo 1.09b"). Below is an example
+// checksum table:
+//    version = Diablo 1.09
+//
+//    [levels/l1data/banner2.dun]
+//    md5 = 101c309ecc06e249e0ff14d99e4db861
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mewbak/goini"
+	"github.com/mewrnd/blizzconv/mpq"
+)
+
+// defaultManifestPath is the default path of the patch manifest, which may be
+// absent.
+const defaultManifestPath = "mpqfix.ini"
+
+// Flags specified on the command line.
+var (
+	genPath, manifestPath, sumsPath, version string
+	archivePath, extractPath, mpqIniPath     string
+)
+
+func init() {
+	flag.Usage = usage
+	flag.StringVar(&genPath, "gen", "", "Output path of a checksum table generated from the files.")
+	flag.StringVar(&manifestPath, "manifest", defaultManifestPath, "Path to a patch manifest of known-faulty files.")
+	flag.StringVar(&archivePath, "mpq", "", "Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of MPQ archives and directories in order of priority.")
+	flag.StringVar(&extractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
+	flag.StringVar(&mpqIniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
+	flag.StringVar(&sumsPath, "sums", "sums/", "Comma-separated list of checksum tables of known game versions, or directories of checksum tables.")
+	flag.StringVar(&version, "version", "", "Game version of the checksum table generated by -gen.")
+	flag.Parse()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]...\n", os.Args[0])
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	archive, err := mpq.OpenArchive(extractPath, mpqIniPath, strings.Split(archivePath, ",")...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer archive.Close()
+	var fixes []fix
+	if manifestPath == defaultManifestPath {
+		// A missing default manifest contains no known-faulty files.
+		if _, err := os.Stat(manifestPath); errors.Is(err, fs.ErrNotExist) {
+			manifestPath = ""
+		}
+	}
+	if manifestPath != "" {
+		fixes, err = loadManifest(manifestPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	tables, err := loadTables(strings.Split(sumsPath, ","))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(tables) == 0 && genPath == "" {
+		log.Printf("no checksum tables found in %q; the game version cannot be identified", sumsPath)
+	}
+
+	// Hash files.
+	files, err := hashFiles(archive, fixes)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if genPath != "" {
+		err = writeTable(genPath, version, files)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	// Identify game version.
+	var best *table
+	bestCount := 0
+	for _, t := range tables {
+		count := t.matches(files)
+		fmt.Printf("%s: %d of %d files match.\n", t.version, count, len(t.sums))
+		if count > bestCount {
+			best, bestCount = t, count
+		}
+	}
+	if best != nil {
+		fmt.Printf("Game version: %s.\n", best.version)
+	} else {
+		fmt.Println("Game version: unknown.")
+	}
+
+	// Report status of each file.
+	counts := make(map[status]int)
+	for _, f := range files {
+		st := f.status(best)
+		counts[st]++
+		switch st {
+		case statusMissing:
+			fmt.Printf("%q: missing.\n", f.relPath)
+		case statusFaulty:
+			fmt.Printf("%q: faulty; fixable by mpqfix.\n", f.relPath)
+		case statusCorrupt:
+			if f.readErr != nil {
+				fmt.Printf("%q: corrupt; %v.\n", f.relPath, f.readErr)
+				continue
+			}
+			fmt.Printf("%q: corrupt; MD5 checksum %x, expected %s.\n", f.relPath, f.sum, best.sums[f.relPath])
+		}
+	}
+	fmt.Printf("%d files: %d ok, %d patched, %d faulty, %d corrupt, %d missing, %d unknown.\n", len(files), counts[statusOK], counts[statusPatched], counts[statusFaulty], counts[statusCorrupt], counts[statusMissing], counts[statusUnknown])
+	if counts[statusCorrupt] > 0 || counts[statusMissing] > 0 {
+		os.Exit(1)
+	}
+}
+
+// A fix specifies the checksums of a known-faulty file, before and after
+// being patched by mpqfix.
+type fix struct {
+	oldsum, newsum string
+}
+
+// loadManifest loads the fixes of the mpqfix patch manifest located at
+// manifestPath.
+func loadManifest(manifestPath string) (fixes []fix, err error) {
+	dict, err := ini.Load(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	for name := range dict {
+		if name == "" {
+			continue
+		}
+		oldsum, found := dict.GetString(name, "oldsum")
+		if !found {
+			return nil, fmt.Errorf("oldsum not found for %q in %q.", name, manifestPath)
+		}
+		newsum, found := dict.GetString(name, "newsum")
+		if !found {
+			return nil, fmt.Errorf("newsum not found for %q in %q.", name, manifestPath)
+		}
+		fixes = append(fixes, fix{oldsum: strings.ToLower(oldsum), newsum: strings.ToLower(newsum)})
+	}
+	return fixes, nil
+}
+
+// A table contains the checksums of the files of a game version.
+type table struct {
+	version string
+	// sums maps from relative paths to hex encoded MD5 checksums.
+	sums map[string]string
+}
+
+// loadTables loads the checksum tables located at tablePaths. Directories are
+// searched for checksum tables (*.ini), and a missing directory contains no
+// checksum tables.
+func loadTables(tablePaths []string) (tables []*table, err error) {
+	for _, tablePath := range tablePaths {
+		if tablePath == "" {
+			continue
+		}
+		fi, err := os.Stat(tablePath)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && strings.HasSuffix(tablePath, "/") {
+				continue
+			}
+			return nil, err
+		}
+		if !fi.IsDir() {
+			t, err := loadTable(tablePath)
+			if err != nil {
+				return nil, err
+			}
+			tables = append(tables, t)
+			continue
+		}
+		names, err := filepath.Glob(filepath.Join(tablePath, "*.ini"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			t, err := loadTable(name)
+			if err != nil {
+				return nil, err
+			}
+			tables = append(tables, t)
+		}
+	}
+	return tables, nil
+}
+
+// loadTable loads the checksum table located at tablePath.
+func loadTable(tablePath string) (t *table, err error) {
+	dict, err := ini.Load(tablePath)
+	if err != nil {
+		return nil, err
+	}
+	t = &table{sums: make(map[string]string)}
+	var found bool
+	t.version, found = dict.GetString("", "version")
+	if !found {
+		return nil, fmt.Errorf("version not found in %q.", tablePath)
+	}
+	for relPath := range dict {
+		if relPath == "" {
+			continue
+		}
+		sum, found := dict.GetString(relPath, "md5")
+		if !found {
+			return nil, fmt.Errorf("md5 not found for %q in %q.", relPath, tablePath)
+		}
+		t.sums[relPath] = strings.ToLower(sum)
+	}
+	return t, nil
+}
+
+// matches returns the number of files which match the checksum table.
+func (t *table) matches(files []*file) (count int) {
+	for _, f := range files {
+		if sum, ok := t.sums[f.relPath]; ok && sum == f.origSum {
+			count++
+		}
+	}
+	return count
+}
+
+// writeTable writes a checksum table of the present files to tablePath.
+func writeTable(tablePath, version string, files []*file) (err error) {
+	f, err := os.Create(tablePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	fmt.Fprintf(bw, "version = %s\n", version)
+	for _, file := range files {
+		if file.missing || file.readErr != nil {
+			continue
+		}
+		fmt.Fprintf(bw, "\n[%s]\nmd5 = %s\n", file.relPath, file.origSum)
+	}
+	return bw.Flush()
+}
+
+// A file is a hashed file of the MPQ archive.
+type file struct {
+	relPath string
+	missing bool
+	sum     [md5.Size]byte
+	// origSum is the hex encoded MD5 checksum of the file, before being
+	// patched by mpqfix.
+	origSum string
+	// faulty and patched specify whether the file matches the unpatched or the
+	// patched version of a known-faulty file.
+	faulty, patched bool
+	// readErr is the error encountered while reading or decompressing the
+	// file, if any. Such files are reported as corrupt.
+	readErr error
+}
+
+// hashFiles hashes the files listed in the ini file of the archive.
+func hashFiles(archive *mpq.Archive, fixes []fix) (files []*file, err error) {
+	fsys := archive.FS()
+	relPaths := make(map[string]bool)
+	for _, name := range archive.Names() {
+		relPath, err := archive.GetRelPath(name)
+		if err != nil {
+			return nil, err
+		}
+		relPaths[relPath] = true
+	}
+	for relPath := range relPaths {
+		f := &file{relPath: relPath}
+		files = append(files, f)
+		buf, err := fs.ReadFile(fsys, relPath)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				f.missing = true
+				continue
+			}
+			f.readErr = err
+			continue
+		}
+		f.sum = md5.Sum(buf)
+		sum := fmt.Sprintf("%x", f.sum)
+		f.origSum = sum
+		for _, fix := range fixes {
+			switch sum {
+			case fix.oldsum:
+				f.faulty = true
+			case fix.newsum:
+				f.patched = true
+				f.origSum = fix.oldsum
+			}
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].relPath < files[j].relPath
+	})
+	return files, nil
+}
+
+// status represents the verification status of a file.
+type status int
+
+// Verification statuses.
+const (
+	statusOK status = iota
+	statusMissing
+	statusFaulty
+	statusPatched
+	statusCorrupt
+	statusUnknown
+	// statusAbsent specifies that a missing file is not part of the game
+	// version.
+	statusAbsent
+)
+
+// status returns the verification status of the file, based on the checksum
+// table of the game version, if any.
+func (f *file) status(t *table) status {
+	switch {
+	case f.readErr != nil:
+		return statusCorrupt
+	case f.missing:
+		if t != nil {
+			if _, ok := t.sums[f.relPath]; !ok {
+				return statusAbsent
+			}
+		}
+		return statusMissing
+	case f.faulty:
+		return statusFaulty
+	case f.patched:
+		return statusPatched
+	}
+	if t == nil {
+		return statusUnknown
+	}
+	sum, ok := t.sums[f.relPath]
+	if !ok {
+		return statusUnknown
+	}
+	if sum != f.origSum {
+		return statusCorrupt
+	}
+	return statusOK
+}
@@ -1,56 +1,294 @@
+// mpqfix is a tool for patching faulty files of an MPQ archive, based on the
+// fixes of a patch manifest.
+//
+// Usage:
+//
+//    mpqfix [OPTION]...
+//
+// Flags:
+//
+//    -dry-run=false
+//            Report the status of each file without patching it.
+//    -list=false
+//            List the fixes of the patch manifest.
+//    -manifest="mpqfix.ini"
+//            Path to a patch manifest.
+//    -mpq=""
+//            Path to an MPQ archive (e.g. DIABDAT.MPQ).
+//    -mpqdump="mpqdump/"
+//            Path to an extracted MPQ file.
+//    -revert=false
+//            Restore the unpatched files.
+//
+// The status of each file is reported as unpatched, patched, unknown version or
+// missing.
 package main
 
 import (
 	"crypto/md5"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mewbak/goini"
+	"github.com/mewrnd/blizzconv/mpq"
 )
 
-var mpqpath string
+var mpqpath, archivePath, manifestPath string
+
+// Modes specified by command line flags.
+var flagDryRun, flagList, flagRevert bool
 
 func init() {
+	flag.Usage = usage
+	flag.BoolVar(&flagDryRun, "dry-run", false, "Report the status of each file without patching it.")
+	flag.BoolVar(&flagList, "list", false, "List the fixes of the patch manifest.")
+	flag.StringVar(&manifestPath, "manifest", "mpqfix.ini", "Path to a patch manifest.")
+	flag.StringVar(&archivePath, "mpq", "", "Path to an MPQ archive (e.g. DIABDAT.MPQ).")
 	flag.StringVar(&mpqpath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
+	flag.BoolVar(&flagRevert, "revert", false, "Restore the unpatched files.")
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]...\n", os.Args[0])
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
 }
 
+// archive is used to read the files which are not present in mpqpath.
+var archive *mpq.ReadCloser
+
 func main() {
 	flag.Parse()
-	fixes := []Fix{
-		{
-			path:   "monsters/unrav/unravw.cel",
-			data:   map[int]byte{4: 0x07, 8: 0xC5, 12: 0xA3, 16: 0xC3, 20: 0x26, 24: 0x4C, 28: 0x93},
-			oldsum: [md5.Size]byte{0x09, 0xAE, 0xC6, 0x35, 0xE0, 0xFC, 0x9E, 0x08, 0x43, 0x91, 0xF0, 0x0D, 0x4C, 0xDD, 0xB2, 0x99},
-			newsum: [md5.Size]byte{0x59, 0xE5, 0x2E, 0x32, 0xA7, 0x35, 0xCC, 0x46, 0x42, 0x6A, 0x36, 0xB2, 0x40, 0x7B, 0xE4, 0xC0},
-		},
-		{
-			path:   "levels/l1data/banner2.dun",
-			data:   map[int]byte{6: 0x02, 8: 0x02, 12: 0x02, 14: 0x02, 16: 0x02},
-			oldsum: [md5.Size]byte{0x10, 0x1C, 0x30, 0x9E, 0xCC, 0x06, 0xE2, 0x49, 0xE0, 0xFF, 0x14, 0xD9, 0x9E, 0x4D, 0xB8, 0x61},
-			newsum: [md5.Size]byte{0xA5, 0x19, 0xF7, 0x38, 0xA5, 0xCC, 0xE3, 0xD5, 0x04, 0x41, 0xD6, 0x21, 0xDE, 0x35, 0x01, 0x69},
-		},
+	if flagDryRun && flagRevert {
+		log.Fatalln("the -dry-run and -revert flags are mutually exclusive")
 	}
-	for _, fix := range fixes {
-		fmt.Printf("Patching %q.\n", fix.path)
-		err := fix.Apply()
+	fixes, err := loadManifest(manifestPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if flagList {
+		for _, fix := range fixes {
+			fmt.Printf("%s: %q (%d bytes)\n", fix.name, fix.path, len(fix.data))
+		}
+		return
+	}
+	if archivePath != "" {
+		archive, err = mpq.OpenReader(archivePath)
 		if err != nil {
-			log.Println(err)
-			continue
+			log.Fatalln(err)
+		}
+		defer archive.Close()
+	}
+	for _, fix := range fixes {
+		switch {
+		case flagDryRun:
+			status, err := fix.Status()
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			fmt.Printf("%q: %v.\n", fix.path, status)
+		case flagRevert:
+			fmt.Printf("Reverting %q.\n", fix.path)
+			err := fix.Revert()
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+		default:
+			fmt.Printf("Patching %q.\n", fix.path)
+			err := fix.Apply()
+			if err != nil {
+				log.Println(err)
+				continue
+			}
 		}
 	}
 }
 
+// A Fix specifies the changes required to patch a faulty file.
 type Fix struct {
+	// name is the section name of the fix within the patch manifest.
+	name   string
 	path   string
 	data   map[int]byte
 	oldsum [md5.Size]byte
 	newsum [md5.Size]byte
 }
 
+// loadManifest loads the fixes of the patch manifest located at manifestPath.
+// Below is an example fix:
+//    [banner2.dun]
+//    path = levels/l1data/banner2.dun
+//    oldsum = 101c309ecc06e249e0ff14d99e4db861
+//    newsum = a519f738a5cce3d50441d621de350169
+//    data = 6:0x02,8:0x02,12:0x02,14:0x02,16:0x02
+func loadManifest(manifestPath string) (fixes []Fix, err error) {
+	dict, err := ini.Load(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range dict {
+		if name == "" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fix, err := parseFix(dict, name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fix %q in %q; %v", name, manifestPath, err)
+		}
+		fixes = append(fixes, fix)
+	}
+	return fixes, nil
+}
+
+// parseFix parses the fix of the given section name.
+func parseFix(dict ini.Dict, name string) (fix Fix, err error) {
+	fix.name = name
+	var found bool
+	fix.path, found = dict.GetString(name, "path")
+	if !found {
+		return Fix{}, errors.New("path not found")
+	}
+	rawOldsum, found := dict.GetString(name, "oldsum")
+	if !found {
+		return Fix{}, errors.New("oldsum not found")
+	}
+	fix.oldsum, err = parseSum(rawOldsum)
+	if err != nil {
+		return Fix{}, err
+	}
+	rawNewsum, found := dict.GetString(name, "newsum")
+	if !found {
+		return Fix{}, errors.New("newsum not found")
+	}
+	fix.newsum, err = parseSum(rawNewsum)
+	if err != nil {
+		return Fix{}, err
+	}
+	rawData, found := dict.GetString(name, "data")
+	if !found {
+		return Fix{}, errors.New("data not found")
+	}
+	fix.data = make(map[int]byte)
+	for _, rawPair := range strings.Split(rawData, ",") {
+		rawPair = strings.TrimSpace(rawPair)
+		posDelim := strings.Index(rawPair, ":")
+		if posDelim == -1 {
+			return Fix{}, fmt.Errorf("no delim ':' found for %q", rawPair)
+		}
+		pos, err := strconv.ParseUint(rawPair[:posDelim], 0, 31)
+		if err != nil {
+			return Fix{}, err
+		}
+		val, err := strconv.ParseUint(rawPair[posDelim+1:], 0, 8)
+		if err != nil {
+			return Fix{}, err
+		}
+		fix.data[int(pos)] = byte(val)
+	}
+	return fix, nil
+}
+
+// parseSum parses a hex encoded MD5 checksum.
+func parseSum(rawSum string) (sum [md5.Size]byte, err error) {
+	buf, err := hex.DecodeString(rawSum)
+	if err != nil {
+		return sum, err
+	}
+	if len(buf) != md5.Size {
+		return sum, fmt.Errorf("invalid MD5 checksum %q", rawSum)
+	}
+	copy(sum[:], buf)
+	return sum, nil
+}
+
+// Status represents the patch status of a file.
+type Status int
+
+// Patch statuses.
+const (
+	// StatusMissing specifies that the file is not present.
+	StatusMissing Status = iota
+	// StatusUnpatched specifies that the file matches the unpatched version.
+	StatusUnpatched
+	// StatusPatched specifies that the file matches the patched version.
+	StatusPatched
+	// StatusUnknown specifies that the file matches neither version.
+	StatusUnknown
+)
+
+// String returns a string representation of the status.
+func (status Status) String() string {
+	switch status {
+	case StatusMissing:
+		return "missing"
+	case StatusUnpatched:
+		return "unpatched"
+	case StatusPatched:
+		return "patched"
+	case StatusUnknown:
+		return "unknown version"
+	}
+	return fmt.Sprintf("<unknown status %d>", int(status))
+}
+
+// read returns the contents of the file to patch. The file is read from the MPQ
+// archive if not present in mpqpath, in which case local is false.
+func (fix Fix) read() (buf []byte, local bool, err error) {
+	path := filepath.Join(mpqpath, fix.path)
+	buf, err = ioutil.ReadFile(path)
+	if err == nil {
+		return buf, true, nil
+	}
+	if archive == nil || !os.IsNotExist(err) {
+		return nil, false, err
+	}
+	buf, err = archive.ReadFile(fix.path)
+	if err != nil {
+		return nil, false, err
+	}
+	return buf, false, nil
+}
+
+// Status returns the patch status of the file.
+func (fix Fix) Status() (status Status, err error) {
+	buf, _, err := fix.read()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return StatusMissing, nil
+		}
+		return 0, err
+	}
+	switch md5.Sum(buf) {
+	case fix.oldsum:
+		return StatusUnpatched, nil
+	case fix.newsum:
+		return StatusPatched, nil
+	}
+	return StatusUnknown, nil
+}
+
+// Apply patches the file and stores the patched version in mpqpath, where it
+// takes precedence over the MPQ archive when listed first by -mpq. A backup of
+// files already present in mpqpath is stored with the ".orig" extension.
 func (fix Fix) Apply() error {
 	path := filepath.Join(mpqpath, fix.path)
-	buf, err := ioutil.ReadFile(path)
+	buf, local, err := fix.read()
 	if err != nil {
 		return err
 	}
@@ -61,11 +299,22 @@ func (fix Fix) Apply() error {
 	if oldsum != fix.oldsum {
 		return fmt.Errorf("MD5 checksum mismatch for unpatched version of %q.", fix.path)
 	}
-	err = ioutil.WriteFile(path+".orig", buf, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to create backup for %q; %v", fix.path, err)
+	if local {
+		err = ioutil.WriteFile(path+".orig", buf, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to create backup for %q; %v", fix.path, err)
+		}
+	} else {
+		// The unpatched version remains in the MPQ archive.
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return err
+		}
 	}
 	for pos, val := range fix.data {
+		if pos >= len(buf) {
+			return fmt.Errorf("offset %d out of range for %q.", pos, fix.path)
+		}
 		buf[pos] = val
 	}
 	newsum := md5.Sum(buf)
@@ -74,3 +323,34 @@ func (fix Fix) Apply() error {
 	}
 	return ioutil.WriteFile(path, buf, 0644)
 }
+
+// Revert restores the unpatched version of the file from its ".orig" backup.
+// Patched files without a backup are removed from mpqpath if the unpatched
+// version is present in the MPQ archive.
+func (fix Fix) Revert() error {
+	path := filepath.Join(mpqpath, fix.path)
+	buf, err := ioutil.ReadFile(path + ".orig")
+	if err == nil {
+		if md5.Sum(buf) != fix.oldsum {
+			return fmt.Errorf("MD5 checksum mismatch for backup of %q.", fix.path)
+		}
+		return os.Rename(path+".orig", path)
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	buf, err = ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%q missing.", fix.path)
+		}
+		return err
+	}
+	if md5.Sum(buf) != fix.newsum {
+		return fmt.Errorf("%q not patched.", fix.path)
+	}
+	if archive == nil || !archive.Has(fix.path) {
+		return fmt.Errorf("no backup found for %q.", fix.path)
+	}
+	return os.Remove(path)
+}
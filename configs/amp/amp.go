@@ -0,0 +1,131 @@
+// Package amp implements functionality for parsing AMP files.
+//
+// AMP files contain information about how the squares, which are constructed
+// based on the TIL format, appear on the automap. Below is a description of the
+// AMP format:
+//
+// AMP format:
+//    squares []Square
+//
+// Square format:
+//    // square is a bitfield containing Kind and the flags:
+//    //    Kind      := square & 0x000F
+//    //    VertDoor  := square & 0x0100
+//    //    HorzDoor  := square & 0x0200
+//    //    VertArch  := square & 0x0400
+//    //    HorzArch  := square & 0x0800
+//    //    VertGrate := square & 0x1000
+//    //    HorzGrate := square & 0x2000
+//    //    Dirt      := square & 0x4000
+//    //    Stairs    := square & 0x8000
+//    square uint16
+//
+// The automap information of a square can be obtained using the squareNum as
+// an offset into the squares array.
+package amp
+
+import (
+	"encoding/binary"
+	"io"
+	"io/fs"
+)
+
+// Kind specifies the shape of a square on the automap.
+type Kind int
+
+// Automap square kinds.
+const (
+	// KindNone specifies a square which is not drawn.
+	KindNone Kind = 0
+	// KindPillar specifies a stand-alone pillar or other impassable object.
+	KindPillar Kind = 1
+	// KindVertWall specifies a wall along the upper left edge of the square.
+	KindVertWall Kind = 2
+	// KindHorzWall specifies a wall along the upper right edge of the square.
+	KindHorzWall Kind = 3
+	// KindCorner specifies walls along both upper edges of the square.
+	KindCorner Kind = 4
+	// KindVertWallEnd specifies a wall along the upper left edge of the
+	// square, which ends a vertical wall.
+	KindVertWallEnd Kind = 5
+	// KindHorzWallEnd specifies a wall along the upper right edge of the
+	// square, which ends a horizontal wall.
+	KindHorzWallEnd Kind = 6
+	// The half wall kinds specify walls along the upper half of the upper
+	// right (Horz) or upper left (Vert) edge of the square, which connect to
+	// walls of adjacent squares.
+	KindHorzHalf  Kind = 8
+	KindVertHalf  Kind = 9
+	KindHorzHalf2 Kind = 10
+	KindVertHalf2 Kind = 11
+	KindHorzCap   Kind = 12
+	KindVertCap   Kind = 13
+	// KindHorzLine and KindVertLine specify walls which are used by the caves.
+	KindHorzLine Kind = 14
+	KindVertLine Kind = 15
+)
+
+// Square defines how a square appears on the automap.
+type Square struct {
+	Kind      Kind
+	VertDoor  bool
+	HorzDoor  bool
+	VertArch  bool
+	HorzArch  bool
+	VertGrate bool
+	HorzGrate bool
+	Dirt      bool
+	Stairs    bool
+}
+
+// VertWall returns true if the square has a wall along its upper left edge.
+func (square Square) VertWall() bool {
+	switch square.Kind {
+	case KindVertWall, KindCorner, KindVertWallEnd, KindVertLine:
+		return true
+	}
+	return false
+}
+
+// HorzWall returns true if the square has a wall along its upper right edge.
+func (square Square) HorzWall() bool {
+	switch square.Kind {
+	case KindHorzWall, KindCorner, KindHorzWallEnd, KindHorzLine:
+		return true
+	}
+	return false
+}
+
+// Parse parses a given AMP file and returns a slice of squares, based on the
+// AMP format described above. The AMP file is located at relAmpPath within
+// fsys.
+func Parse(fsys fs.FS, relAmpPath string) (squares []Square, err error) {
+	fr, err := fsys.Open(relAmpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer fr.Close()
+	for {
+		var x uint16
+		err = binary.Read(fr, binary.LittleEndian, &x)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		square := Square{
+			Kind:      Kind(x & 0x000F),
+			VertDoor:  x&0x0100 != 0,
+			HorzDoor:  x&0x0200 != 0,
+			VertArch:  x&0x0400 != 0,
+			HorzArch:  x&0x0800 != 0,
+			VertGrate: x&0x1000 != 0,
+			HorzGrate: x&0x2000 != 0,
+			Dirt:      x&0x4000 != 0,
+			Stairs:    x&0x8000 != 0,
+		}
+		squares = append(squares, square)
+	}
+	return squares, nil
+}
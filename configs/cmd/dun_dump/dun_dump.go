@@ -9,25 +9,37 @@
 //
 //    -a=false
 //            Dump all dungeons.
+//    -automap=false
+//            Dump the automaps of the dungeons, instead of their pillars.
 //    -celini="cel.ini"
 //            Path to an ini file containing image information.
 //            Note: 'cl2.ini' will be used for files that have the '.cl2' extension.
+//    -mpq=""
+//            Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of
+//            MPQ archives and directories in order of priority.
 //    -mpqdump="mpqdump/"
 //            Path to an extracted MPQ file.
 //    -mpqini="mpq.ini"
 //            Path to an ini file containing relative path information.
+//
+// Automaps are rendered based on the AMP file of the level (e.g. l1.amp), and
+// stored in the "_dump_/_automaps_/" directory. The town has no automap, and is
+// skipped when dumping all dungeons.
 package main
 
 import (
 	"flag"
 	dbg "fmt"
 	"fmt"
+	"image"
+	"io/fs"
 	"log"
 	"os"
 	"path"
 	"strings"
 
 	"github.com/mewkiz/pkg/imgutil"
+	"github.com/mewrnd/blizzconv/configs/amp"
 	"github.com/mewrnd/blizzconv/configs/dun"
 	"github.com/mewrnd/blizzconv/configs/dunconf"
 	"github.com/mewrnd/blizzconv/configs/min"
@@ -38,23 +50,45 @@ import (
 
 var flagAll bool
 
+// flagAutomap specifies if the automaps of the dungeons should be dumped
+// instead of their pillars.
+var flagAutomap bool
+
+// Paths specified by command line flags.
+var imgIniPath, dunIniPath, archivePath, extractPath, mpqIniPath string
+
+var (
+	// archive provides access to the files of the MPQ archive.
+	archive *mpq.Archive
+	// fsys is the file system of the MPQ archive.
+	fsys fs.FS
+	// dunConf provides the starting coordinates of DUN files.
+	dunConf *dunconf.Config
+	// imgConf provides image information.
+	imgConf *imgconf.Config
+)
+
 func init() {
 	flag.Usage = usage
 	flag.BoolVar(&flagAll, "a", false, "Dump all dungeons.")
-	flag.StringVar(&imgconf.IniPath, "celini", "cel.ini", "Path to an ini file containing image information.")
-	flag.StringVar(&dunconf.IniPath, "dunini", "dun.ini", "Path to an ini file containing starting coordinate information.")
-	flag.StringVar(&mpq.ExtractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
-	flag.StringVar(&mpq.IniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
+	flag.BoolVar(&flagAutomap, "automap", false, "Dump the automaps of the dungeons, instead of their pillars.")
+	flag.StringVar(&imgIniPath, "celini", "cel.ini", "Path to an ini file containing image information.")
+	flag.StringVar(&dunIniPath, "dunini", "dun.ini", "Path to an ini file containing starting coordinate information.")
+	flag.StringVar(&archivePath, "mpq", "", "Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of MPQ archives and directories in order of priority.")
+	flag.StringVar(&extractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
+	flag.StringVar(&mpqIniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
 	flag.Parse()
-	err := mpq.Init()
+	var err error
+	archive, err = mpq.OpenArchive(extractPath, mpqIniPath, strings.Split(archivePath, ",")...)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	err = dunconf.Init()
+	fsys = archive.FS()
+	dunConf, err = dunconf.Load(dunIniPath)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	err = imgconf.Init()
+	imgConf, err = imgconf.Load(imgIniPath)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -70,7 +104,7 @@ func usage() {
 func main() {
 	var dungeonNames []string
 	if flagAll {
-		dungeonNames = dunconf.DungeonNames()
+		dungeonNames = dunConf.DungeonNames()
 	} else if flag.NArg() > 0 {
 		dungeonNames = flag.Args()
 	} else {
@@ -92,50 +126,73 @@ const dumpPrefix = "_dump_/"
 // constructed based on the given DUN files, as a png image once for each image
 // config (pal).
 func dungeonDump(dungeonName string) (err error) {
-	dunNames, err := dunconf.GetDunNames(dungeonName)
+	dunNames, err := dunConf.GetDunNames(dungeonName)
 	if err != nil {
 		return err
 	}
 	dungeon := dun.New()
+	var relDunPaths []string
 	for _, dunName := range dunNames {
-		err = dungeon.Parse(dunName)
+		relDunPath, err := archive.GetRelPath(dunName)
+		if err != nil {
+			return err
+		}
+		relDunPaths = append(relDunPaths, relDunPath)
+		err = dungeon.Parse(fsys, dunConf, relDunPath)
 		if err != nil {
 			return fmt.Errorf("failed to parse %q: %s", dungeonName, err)
 		}
 	}
-	colCount, err := dunconf.GetColCount(dungeonName)
+	colCount, err := dunConf.GetColCount(dungeonName)
 	if err != nil {
 		return err
 	}
-	rowCount, err := dunconf.GetRowCount(dungeonName)
+	rowCount, err := dunConf.GetRowCount(dungeonName)
 	if err != nil {
 		return err
 	}
-	nameWithoutExt, err := dun.GetLevelName(dunNames[0])
+	nameWithoutExt, err := dun.GetLevelName(relDunPaths[0])
 	if err != nil {
 		return err
 	}
-	minName := nameWithoutExt + ".min"
-	pillars, err := min.Parse(minName)
+	if flagAutomap {
+		return automapDump(dungeon, dungeonName, nameWithoutExt, colCount, rowCount)
+	}
+	relMinPath, err := archive.GetRelPath(nameWithoutExt + ".min")
+	if err != nil {
+		return err
+	}
+	pillars, err := min.Parse(fsys, relMinPath)
 	if err != nil {
 		return err
 	}
+	frameTypes := min.FrameTypes(pillars)
 	imgName := nameWithoutExt + ".cel"
-	relPalPaths := imgconf.GetRelPalPaths(imgName)
+	relImgPath, err := archive.GetRelPath(imgName)
+	if err != nil {
+		return err
+	}
+	relPalPaths := imgConf.GetRelPalPaths(imgName)
+	var frames []image.Image
 	for _, relPalPath := range relPalPaths {
-		conf, err := cel.GetConf(imgName, relPalPath)
+		conf, err := cel.GetConf(fsys, imgConf, imgName, relPalPath)
 		if err != nil {
 			return err
 		}
+		conf.FrameTypes = frameTypes
 		var palDir string
 		if len(relPalPaths) > 1 {
 			dbg.Println("using pal:", relPalPath)
 			palDir = dungeonName + "/"
 		}
-		levelFrames, err := cel.DecodeAll(imgName, conf)
-		if err != nil {
-			return err
+		if frames == nil {
+			// decode the frames once, and recolor them for each palette.
+			frames, err = cel.DecodeAll(fsys, relImgPath, conf)
+			if err != nil {
+				return err
+			}
 		}
+		levelFrames := cel.RecolorAll(frames, conf.Pal)
 		dumpDir := path.Clean(dumpPrefix+"_dungeons_/") + "/" + palDir
 		// prevent directory traversal
 		if !strings.HasPrefix(dumpDir, dumpPrefix) {
@@ -160,3 +217,34 @@ func dungeonDump(dungeonName string) (err error) {
 	}
 	return nil
 }
+
+// automapDump creates a dump directory and stores the automap of the dungeon as
+// a png image.
+func automapDump(dungeon *dun.Dungeon, dungeonName, nameWithoutExt string, colCount, rowCount int) (err error) {
+	relAmpPath, err := archive.GetRelPath(nameWithoutExt + ".amp")
+	if err != nil {
+		if flagAll {
+			// levels without an AMP file (e.g. the town) have no automap.
+			dbg.Println("Skipping dungeon without automap:", dungeonName)
+			return nil
+		}
+		return err
+	}
+	squares, err := amp.Parse(fsys, relAmpPath)
+	if err != nil {
+		return err
+	}
+	dumpDir := dumpPrefix + "_automaps_/"
+	err = os.MkdirAll(dumpDir, 0755)
+	if err != nil {
+		return err
+	}
+	automapPath := path.Clean(dumpDir + dungeonName + ".png")
+	// prevent directory traversal
+	if !strings.HasPrefix(automapPath, dumpDir) {
+		return fmt.Errorf("path (%s) contains no dump prefix (%s).", automapPath, dumpDir)
+	}
+	dbg.Println("Creating image:", path.Base(automapPath))
+	img := dungeon.Automap(colCount, rowCount, squares)
+	return imgutil.WriteFile(automapPath, img)
+}
@@ -0,0 +1,156 @@
+// exe_dump is a tool for extracting the data tables of the game (monsters,
+// objects, items and spells) from Diablo.exe, and storing them as ini files.
+//
+// Usage:
+//
+//    exe_dump [OPTION]... diablo.exe
+//
+// Flags:
+//
+//    -exeini="exe.ini"
+//            Path to an ini file containing the locations of the data tables.
+//    -version=""
+//            Version of the executable (e.g. 1.09b); by default based on the
+//            timestamp of the executable.
+//
+// The tables are stored in the "_dump_/_tables_/" directory, with one section
+// per record named after the record index. The "cel" key of the object table
+// is the graphics of the object, as specified by the object_files table. The
+// item table may be used as the items ini file of save_dump.
+//
+// Example:
+//
+//    $ exe_dump diablo.exe
+//    $ save_dump -mpq=diabdat.mpq -itemini=_dump_/_tables_/items.ini -png single_0.sv
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mewrnd/blizzconv/configs/exe"
+)
+
+// version specifies the version of the executable.
+var version string
+
+// Paths specified by command line flags.
+var exeIniPath string
+
+func init() {
+	flag.Usage = usage
+	flag.StringVar(&exeIniPath, "exeini", "exe.ini", "Path to an ini file containing the locations of the data tables.")
+	flag.StringVar(&version, "version", "", "Version of the executable (e.g. 1.09b); by default based on the timestamp of the executable.")
+	flag.Parse()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]... diablo.exe\n", os.Args[0])
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	err := exeDump(flag.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// dumpDir is the name of the dump directory.
+const dumpDir = "_dump_/_tables_/"
+
+// exeDump extracts the data tables of the executable located at exePath, and
+// stores them as ini files.
+func exeDump(exePath string) (err error) {
+	conf, err := exe.LoadConfig(exeIniPath)
+	if err != nil {
+		return err
+	}
+	f, err := exe.Open(exePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if version == "" {
+		version, _ = conf.Version(f)
+	}
+	err = os.MkdirAll(dumpDir, 0755)
+	if err != nil {
+		return err
+	}
+	var objectFiles []exe.Record
+	for _, table := range exe.Tables {
+		va, count, err := conf.Locate(f, version, table)
+		if err != nil {
+			if table == exe.Objects {
+				// The object table is located by the structure of its records,
+				// which may fail for unknown versions of the game.
+				log.Println(err)
+				continue
+			}
+			return err
+		}
+		recs, err := f.Decode(table, va, count)
+		if err != nil {
+			return err
+		}
+		switch table {
+		case exe.ObjectFiles:
+			objectFiles = recs
+		case exe.Objects:
+			for _, rec := range recs {
+				fileIndex := rec["file_index"].(int)
+				if fileIndex >= 0 && fileIndex < len(objectFiles) {
+					rec["cel"] = strings.ToLower(objectFiles[fileIndex]["file"].(string)) + ".cel"
+				}
+			}
+		}
+		fmt.Printf("%s: %d records at 0x%08X\n", table.Name, len(recs), va)
+		err = writeTable(exePath, table, recs)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTable stores the records of the table as an ini file.
+func writeTable(exePath string, table *exe.Table, recs []exe.Record) (err error) {
+	fw, err := os.Create(dumpDir + table.Name + ".ini")
+	if err != nil {
+		return err
+	}
+	defer fw.Close()
+	bw := bufio.NewWriter(fw)
+	fmt.Fprintf(bw, "# Table %s of %s", table.Name, exePath)
+	if version != "" {
+		fmt.Fprintf(bw, " (%s)", version)
+	}
+	fmt.Fprintln(bw)
+	keys := make([]string, 0, len(table.Fields)+1)
+	for _, field := range table.Fields {
+		keys = append(keys, field.Name)
+	}
+	if table == exe.Objects {
+		keys = append(keys, "cel")
+	}
+	for i, rec := range recs {
+		fmt.Fprintf(bw, "\n[%d]\n", i)
+		for _, key := range keys {
+			if v, ok := rec[key]; ok {
+				fmt.Fprintf(bw, "%s = %v\n", key, v)
+			}
+		}
+	}
+	return bw.Flush()
+}
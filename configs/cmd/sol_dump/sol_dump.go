@@ -3,22 +3,37 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/mewrnd/blizzconv/configs/sol"
 	"github.com/mewrnd/blizzconv/mpq"
 )
 
+// Paths specified by command line flags.
+var archivePath, extractPath, mpqIniPath string
+
+var (
+	// archive provides access to the files of the MPQ archive.
+	archive *mpq.Archive
+	// fsys is the file system of the MPQ archive.
+	fsys fs.FS
+)
+
 func init() {
 	flag.Usage = usage
-	flag.StringVar(&mpq.ExtractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
-	flag.StringVar(&mpq.IniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
+	flag.StringVar(&archivePath, "mpq", "", "Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of MPQ archives and directories in order of priority.")
+	flag.StringVar(&extractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
+	flag.StringVar(&mpqIniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
 	flag.Parse()
-	err := mpq.Init()
+	var err error
+	archive, err = mpq.OpenArchive(extractPath, mpqIniPath, strings.Split(archivePath, ",")...)
 	if err != nil {
 		log.Fatalln(err)
 	}
+	fsys = archive.FS()
 }
 
 func usage() {
@@ -42,7 +57,11 @@ func main() {
 }
 
 func solDump(solName string) (err error) {
-	solids, err := sol.Parse(solName)
+	relSolPath, err := archive.GetRelPath(solName)
+	if err != nil {
+		return err
+	}
+	solids, err := sol.Parse(fsys, relSolPath)
 	if err != nil {
 		return err
 	}
@@ -10,6 +10,9 @@
 //    -celini="cel.ini"
 //            Path to an ini file containing image information.
 //            Note: 'cl2.ini' will be used for files that have the '.cl2' extension.
+//    -mpq=""
+//            Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of
+//            MPQ archives and directories in order of priority.
 //    -mpqdump="mpqdump/"
 //            Path to an extracted MPQ file.
 //    -mpqini="mpq.ini"
@@ -21,6 +24,7 @@ import (
 	dbg "fmt"
 	"fmt"
 	"image"
+	"io/fs"
 	"log"
 	"os"
 	"path"
@@ -35,17 +39,32 @@ import (
 	"github.com/mewrnd/blizzconv/mpq"
 )
 
+// Paths specified by command line flags.
+var imgIniPath, archivePath, extractPath, mpqIniPath string
+
+var (
+	// archive provides access to the files of the MPQ archive.
+	archive *mpq.Archive
+	// fsys is the file system of the MPQ archive.
+	fsys fs.FS
+	// imgConf provides image information.
+	imgConf *imgconf.Config
+)
+
 func init() {
 	flag.Usage = usage
-	flag.StringVar(&imgconf.IniPath, "celini", "cel.ini", "Path to an ini file containing image information.")
-	flag.StringVar(&mpq.ExtractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
-	flag.StringVar(&mpq.IniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
+	flag.StringVar(&imgIniPath, "celini", "cel.ini", "Path to an ini file containing image information.")
+	flag.StringVar(&archivePath, "mpq", "", "Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of MPQ archives and directories in order of priority.")
+	flag.StringVar(&extractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
+	flag.StringVar(&mpqIniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
 	flag.Parse()
-	err := mpq.Init()
+	var err error
+	archive, err = mpq.OpenArchive(extractPath, mpqIniPath, strings.Split(archivePath, ",")...)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	err = imgconf.Init()
+	fsys = archive.FS()
+	imgConf, err = imgconf.Load(imgIniPath)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -81,23 +100,37 @@ const dumpPrefix = "_dump_/"
 // pillars constructed based on the MIN format, once for each image config
 // (pal).
 func tilDump(tilName string) (err error) {
-	squares, err := til.Parse(tilName)
+	relTilPath, err := archive.GetRelPath(tilName)
+	if err != nil {
+		return err
+	}
+	squares, err := til.Parse(fsys, relTilPath)
 	if err != nil {
 		return err
 	}
 	nameWithoutExt := tilName[:len(tilName)-len(path.Ext(tilName))]
-	minName := nameWithoutExt + ".min"
-	pillars, err := min.Parse(minName)
+	relMinPath, err := archive.GetRelPath(nameWithoutExt + ".min")
+	if err != nil {
+		return err
+	}
+	pillars, err := min.Parse(fsys, relMinPath)
 	if err != nil {
 		return err
 	}
+	frameTypes := min.FrameTypes(pillars)
 	imgName := nameWithoutExt + ".cel"
-	relPalPaths := imgconf.GetRelPalPaths(imgName)
+	relImgPath, err := archive.GetRelPath(imgName)
+	if err != nil {
+		return err
+	}
+	relPalPaths := imgConf.GetRelPalPaths(imgName)
+	var frames []image.Image
 	for _, relPalPath := range relPalPaths {
-		conf, err := cel.GetConf(imgName, relPalPath)
+		conf, err := cel.GetConf(fsys, imgConf, imgName, relPalPath)
 		if err != nil {
 			return err
 		}
+		conf.FrameTypes = frameTypes
 		var palDir string
 		if len(relPalPaths) > 1 {
 			dbg.Println("using pal:", relPalPath)
@@ -107,10 +140,14 @@ func tilDump(tilName string) (err error) {
 		if err != nil {
 			return err
 		}
-		levelFrames, err := cel.DecodeAll(imgName, conf)
-		if err != nil {
-			return err
+		if frames == nil {
+			// decode the frames once, and recolor them for each palette.
+			frames, err = cel.DecodeAll(fsys, relImgPath, conf)
+			if err != nil {
+				return err
+			}
 		}
+		levelFrames := cel.RecolorAll(frames, conf.Pal)
 		dumpDir := path.Clean(dumpPrefix+"_squares_/"+nameWithoutExt) + "/" + palDir
 		// prevent directory traversal
 		if !strings.HasPrefix(dumpDir, dumpPrefix) {
@@ -0,0 +1,182 @@
+package dun
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/mewrnd/blizzconv/configs/amp"
+)
+
+// The width and height of a square on the automap.
+const (
+	AutomapSquareWidth  = 32
+	AutomapSquareHeight = 16
+)
+
+// Colors of the automap.
+var (
+	// automapWall is the color of walls, arches and grates.
+	automapWall = color.RGBA{R: 0x8C, G: 0x70, B: 0x48, A: 0xFF}
+	// automapDoor is the color of doors and stairs.
+	automapDoor = color.RGBA{R: 0xD8, G: 0xC0, B: 0x8C, A: 0xFF}
+	// automapDirt is the color of dirt.
+	automapDirt = color.RGBA{R: 0x58, G: 0x48, B: 0x30, A: 0xFF}
+)
+
+// Automap returns an image of the dungeon as it appears on the automap of the
+// game, based on the automap information of each square. The squareNum of each
+// square is used as an offset into squares.
+//
+// Each square is drawn as a diamond, using the map coordinate system
+// illustrated by GetPillarRect. The vertical walls of a square are located
+// along its upper left edge and the horizontal walls along its upper right
+// edge.
+func (dungeon *Dungeon) Automap(colCount, rowCount int, squares []amp.Square) (img image.Image) {
+	mapWidth := (colCount + rowCount) * AutomapSquareWidth / 4
+	mapHeight := (colCount + rowCount) * AutomapSquareHeight / 4
+	dst := image.NewRGBA(image.Rect(0, 0, mapWidth+1, mapHeight+1))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.Black), image.ZP, draw.Src)
+	for row := 0; row < rowCount; row++ {
+		for col := 0; col < colCount; col++ {
+			squareNum, ok := dungeon[col][row]["squareNum"]
+			if !ok || squareNum >= len(squares) {
+				continue
+			}
+			top := GetAutomapPoint(col, row, rowCount)
+			drawSquare(dst, top, squares[squareNum])
+		}
+	}
+	return dst
+}
+
+// GetAutomapPoint returns the point on the automap of the top corner of the
+// square located at the col and row coordinates.
+func GetAutomapPoint(col, row, rowCount int) (top image.Point) {
+	x := rowCount*AutomapSquareWidth/4 + (col-row)*AutomapSquareWidth/4
+	y := (col + row) * AutomapSquareHeight / 4
+	return image.Pt(x, y)
+}
+
+// drawSquare draws a square onto dst, based on its automap information and the
+// point of its top corner.
+//
+//    top
+//     /\
+//    /  \
+//    \  /
+//     \/
+func drawSquare(dst *image.RGBA, top image.Point, square amp.Square) {
+	const (
+		w = AutomapSquareWidth / 2
+		h = AutomapSquareHeight / 2
+	)
+	left := top.Add(image.Pt(-w, h))
+	right := top.Add(image.Pt(w, h))
+	center := top.Add(image.Pt(0, h))
+	switch square.Kind {
+	case amp.KindPillar:
+		drawDiamond(dst, center, w/2, h/2, automapWall)
+	case amp.KindHorzHalf, amp.KindHorzHalf2, amp.KindHorzCap:
+		drawLine(dst, top, midpoint(top, right), automapWall)
+	case amp.KindVertHalf, amp.KindVertHalf2, amp.KindVertCap:
+		drawLine(dst, top, midpoint(top, left), automapWall)
+	}
+	if square.VertWall() {
+		drawEdge(dst, top, left, square.VertDoor, square.VertArch, square.VertGrate)
+	}
+	if square.HorzWall() {
+		drawEdge(dst, top, right, square.HorzDoor, square.HorzArch, square.HorzGrate)
+	}
+	if square.Stairs {
+		// Stairs are drawn as lines parallel to the upper left edge.
+		for i := 1; i < 4; i++ {
+			start := top.Add(image.Pt(i*w/4, i*h/4))
+			drawLine(dst, start, start.Add(image.Pt(-w, h)), automapDoor)
+		}
+	}
+	if square.Dirt {
+		for _, d := range []image.Point{{0, -h / 2}, {-w / 2, 0}, {w / 2, 0}, {0, h / 2}} {
+			dst.Set(center.X+d.X, center.Y+d.Y, automapDirt)
+		}
+	}
+}
+
+// drawEdge draws a wall along the edge from top to end. Doors are drawn as a
+// diamond between two wall segments, grates as a wall segment along the lower
+// half of the edge, and arches as a diamond at the lower end of the edge.
+func drawEdge(dst *image.RGBA, top, end image.Point, door, arch, grate bool) {
+	const (
+		w = AutomapSquareWidth / 8
+		h = AutomapSquareHeight / 8
+	)
+	mid := midpoint(top, end)
+	switch {
+	case door:
+		drawLine(dst, top, midpoint(top, mid), automapWall)
+		drawLine(dst, midpoint(mid, end), end, automapWall)
+		drawDiamond(dst, mid, w, h, automapDoor)
+	case grate:
+		drawLine(dst, mid, end, automapWall)
+		arch = true
+	case !arch:
+		drawLine(dst, top, end, automapWall)
+	}
+	if arch {
+		drawDiamond(dst, midpoint(mid, end), w, h, automapWall)
+	}
+}
+
+// drawDiamond draws the outline of a diamond onto dst, with the given center
+// and half width and height.
+func drawDiamond(dst *image.RGBA, center image.Point, w, h int, c color.Color) {
+	top := center.Add(image.Pt(0, -h))
+	right := center.Add(image.Pt(w, 0))
+	bottom := center.Add(image.Pt(0, h))
+	left := center.Add(image.Pt(-w, 0))
+	drawLine(dst, top, right, c)
+	drawLine(dst, right, bottom, c)
+	drawLine(dst, bottom, left, c)
+	drawLine(dst, left, top, c)
+}
+
+// drawLine draws a line from p to q onto dst, using Bresenham's line algorithm.
+func drawLine(dst *image.RGBA, p, q image.Point, c color.Color) {
+	dx, dy := abs(q.X-p.X), -abs(q.Y-p.Y)
+	sx, sy := 1, 1
+	if p.X > q.X {
+		sx = -1
+	}
+	if p.Y > q.Y {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		dst.Set(p.X, p.Y, c)
+		if p == q {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			p.X += sx
+		}
+		if e2 <= dx {
+			e += dx
+			p.Y += sy
+		}
+	}
+}
+
+// midpoint returns the point halfway between p and q.
+func midpoint(p, q image.Point) image.Point {
+	return image.Pt((p.X+q.X)/2, (p.Y+q.Y)/2)
+}
+
+// abs returns the absolute value of x.
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
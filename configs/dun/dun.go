@@ -20,12 +20,11 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
-	"os"
+	"io/fs"
 	"path"
 
 	"github.com/mewrnd/blizzconv/configs/dunconf"
 	"github.com/mewrnd/blizzconv/configs/til"
-	"github.com/mewrnd/blizzconv/mpq"
 )
 
 // The maximum number of cols and rows in a dungeon map.
@@ -39,6 +38,7 @@ const (
 //
 // The valid keys are:
 //    "pillarNum"
+//    "squareNum" // only present at the top cell of each square.
 //    "unknown" // TODO: update this key once known.
 //    "dunMonstersIDs"
 //    "dunObjectIDs"
@@ -189,12 +189,12 @@ func New() (dungeon *Dungeon) {
 // ref: GetPillarRect (illustration of map coordinate system)
 //
 // Any additional cell data is stored afterwards using row major.
-func (dungeon *Dungeon) Parse(dunName string) (err error) {
-	dunPath, err := mpq.GetPath(dunName)
-	if err != nil {
-		return err
-	}
-	fr, err := os.Open(dunPath)
+//
+// The DUN file is located at relDunPath within fsys, and the TIL file of its
+// level is located in the same directory. The starting coordinates of the DUN
+// file are provided by dunConf.
+func (dungeon *Dungeon) Parse(fsys fs.FS, dunConf *dunconf.Config, relDunPath string) (err error) {
+	fr, err := fsys.Open(relDunPath)
 	if err != nil {
 		return err
 	}
@@ -206,21 +206,22 @@ func (dungeon *Dungeon) Parse(dunName string) (err error) {
 	}
 	dunQWidth := int(tmp[0])
 	dunQHeight := int(tmp[1])
-	colStart, err := dunconf.GetColStart(dunName)
+	dunDir, dunName := path.Split(relDunPath)
+	colStart, err := dunConf.GetColStart(dunName)
 	if err != nil {
 		return err
 	}
-	rowStart, err := dunconf.GetRowStart(dunName)
+	rowStart, err := dunConf.GetRowStart(dunName)
 	if err != nil {
 		return err
 	}
-	nameWithoutExt, err := GetLevelName(dunName)
+	nameWithoutExt, err := GetLevelName(relDunPath)
 	if err != nil {
 		return err
 	}
 
 	// squareNumsPlus1.
-	squares, err := til.Parse(nameWithoutExt + ".til")
+	squares, err := til.Parse(fsys, dunDir+nameWithoutExt+".til")
 	if err != nil {
 		return err
 	}
@@ -236,6 +237,7 @@ func (dungeon *Dungeon) Parse(dunName string) (err error) {
 			squareNumPlus1 := int(x)
 			if squareNumPlus1 != 0 {
 				square := squares[squareNumPlus1-1]
+				dungeon[col][row]["squareNum"] = squareNumPlus1 - 1
 				dungeon[col][row]["pillarNum"] = square.PillarNumTop
 				dungeon[col+1][row]["pillarNum"] = square.PillarNumRight
 				dungeon[col][row+1]["pillarNum"] = square.PillarNumLeft
@@ -333,12 +335,9 @@ func (dungeon *Dungeon) Parse(dunName string) (err error) {
 	return nil
 }
 
-// GetLevelName returns the level name (without extension) of a given DUN file.
-func GetLevelName(dunName string) (nameWithoutExt string, err error) {
-	relDunPath, err := mpq.GetRelPath(dunName)
-	if err != nil {
-		return "", err
-	}
+// GetLevelName returns the level name (without extension) of a DUN file located
+// at relDunPath.
+func GetLevelName(relDunPath string) (nameWithoutExt string, err error) {
 	dunDir, _ := path.Split(relDunPath)
 	switch dunDir {
 	case "levels/l1data/":
@@ -10,25 +10,25 @@ import (
 	"github.com/mewbak/goini"
 )
 
-var dict ini.Dict
-
-// IniPath is the path to an ini file which provides information about the
-// starting coordinates of a given DUN file.
-var IniPath string
+// A Config provides relevant information required for parsing DUN files, such
+// as the starting coordinates of a given DUN file.
+type Config struct {
+	dict ini.Dict
+}
 
-// Init loads an ini file which provides relevant information required for
-// parsing DUN files
-func Init() (err error) {
-	dict, err = ini.Load(IniPath)
+// Load loads the ini file located at iniPath, which provides relevant
+// information required for parsing DUN files.
+func Load(iniPath string) (conf *Config, err error) {
+	dict, err := ini.Load(iniPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return &Config{dict: dict}, nil
 }
 
 // DungeonNames returns a slice of dungeon names based on the ini file.
-func DungeonNames() (dungeonNames []string) {
-	for dungeonName := range dict {
+func (conf *Config) DungeonNames() (dungeonNames []string) {
+	for dungeonName := range conf.dict {
 		if dungeonName == "" || strings.HasSuffix(dungeonName, ".dun") {
 			continue
 		}
@@ -39,8 +39,8 @@ func DungeonNames() (dungeonNames []string) {
 }
 
 // GetColStart returns the starting col of a given DUN file.
-func GetColStart(dunName string) (colStart int, err error) {
-	colStart, found := dict.GetInt(dunName, "col_start")
+func (conf *Config) GetColStart(dunName string) (colStart int, err error) {
+	colStart, found := conf.dict.GetInt(dunName, "col_start")
 	if !found {
 		return 0, fmt.Errorf("col_start not found for %q.", dunName)
 	}
@@ -48,8 +48,8 @@ func GetColStart(dunName string) (colStart int, err error) {
 }
 
 // GetRowStart returns the starting row of a given DUN file.
-func GetRowStart(dunName string) (rowStart int, err error) {
-	rowStart, found := dict.GetInt(dunName, "row_start")
+func (conf *Config) GetRowStart(dunName string) (rowStart int, err error) {
+	rowStart, found := conf.dict.GetInt(dunName, "row_start")
 	if !found {
 		return 0, fmt.Errorf("row_start not found for %q.", dunName)
 	}
@@ -57,8 +57,8 @@ func GetRowStart(dunName string) (rowStart int, err error) {
 }
 
 // GetDunNames returns the DUN file names of a given dungeon map.
-func GetDunNames(dungeonName string) (dunNames []string, err error) {
-	rawDunNames, found := dict.GetString(dungeonName, "duns")
+func (conf *Config) GetDunNames(dungeonName string) (dunNames []string, err error) {
+	rawDunNames, found := conf.dict.GetString(dungeonName, "duns")
 	if !found {
 		return nil, fmt.Errorf("duns not found for %q.", dungeonName)
 	}
@@ -66,8 +66,8 @@ func GetDunNames(dungeonName string) (dunNames []string, err error) {
 }
 
 // GetColCount returns the number of cols of a given dungeon map.
-func GetColCount(dungeonName string) (colCount int, err error) {
-	colCount, found := dict.GetInt(dungeonName, "col_count")
+func (conf *Config) GetColCount(dungeonName string) (colCount int, err error) {
+	colCount, found := conf.dict.GetInt(dungeonName, "col_count")
 	if !found {
 		return 0, fmt.Errorf("col_count not found for %q.", dungeonName)
 	}
@@ -75,8 +75,8 @@ func GetColCount(dungeonName string) (colCount int, err error) {
 }
 
 // GetRowCount returns the number of rows of a given dungeon map.
-func GetRowCount(dungeonName string) (rowCount int, err error) {
-	rowCount, found := dict.GetInt(dungeonName, "row_count")
+func (conf *Config) GetRowCount(dungeonName string) (rowCount int, err error) {
+	rowCount, found := conf.dict.GetInt(dungeonName, "row_count")
 	if !found {
 		return 0, fmt.Errorf("row_count not found for %q.", dungeonName)
 	}
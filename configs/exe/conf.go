@@ -0,0 +1,93 @@
+package exe
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/mewbak/goini"
+)
+
+// A Config provides the locations of the data tables for each version of the
+// executable. The sections of the ini file are named after the versions, and
+// contain the following keys:
+//
+//    # timestamp is the TimeDateStamp of the PE file header.
+//    timestamp = 0x12345678
+//    # The virtual address and optional record count of each table.
+//    objects = 0x4A1234
+//    objects_count = 100
+type Config struct {
+	dict ini.Dict
+}
+
+// LoadConfig loads the ini file located at iniPath, which provides the
+// locations of the data tables.
+func LoadConfig(iniPath string) (conf *Config, err error) {
+	dict, err := ini.Load(iniPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{dict: dict}, nil
+}
+
+// Version returns the version of the executable, based on the timestamp of its
+// PE file header.
+func (conf *Config) Version(f *File) (version string, found bool) {
+	var versions []string
+	for version := range conf.dict {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	for _, version := range versions {
+		timestamp, ok := conf.getUint32(version, "timestamp")
+		if ok && timestamp == f.FileHeader.TimeDateStamp {
+			return version, true
+		}
+	}
+	return "", false
+}
+
+// GetAddr returns the virtual address of a given table.
+func (conf *Config) GetAddr(version string, table *Table) (va uint32, found bool) {
+	return conf.getUint32(version, table.Name)
+}
+
+// GetCount returns the number of records of a given table, or -1 if not
+// present.
+func (conf *Config) GetCount(version string, table *Table) (count int) {
+	count, found := conf.dict.GetInt(version, table.Name+"_count")
+	if !found {
+		return -1
+	}
+	return count
+}
+
+// getUint32 returns the integer value of the key in the given section, which
+// may be specified in hexadecimal.
+func (conf *Config) getUint32(section, key string) (x uint32, found bool) {
+	s, found := conf.dict.GetString(section, key)
+	if !found {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(n), true
+}
+
+// Locate returns the virtual address and record count of the table, as
+// provided by the ini file for the given version, or located based on the
+// anchor of the table (see File.Locate) with a record count of -1.
+func (conf *Config) Locate(f *File, version string, table *Table) (va uint32, count int, err error) {
+	va, found := conf.GetAddr(version, table)
+	if found {
+		return va, conf.GetCount(version, table), nil
+	}
+	va, err = f.Locate(table)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%v; specify its address in the exe ini file", err)
+	}
+	return va, -1, nil
+}
@@ -0,0 +1,122 @@
+// Package exe implements functionality for extracting the data tables of the
+// game, such as the monster, object, item and spell tables, from a PE
+// executable (e.g. Diablo.exe).
+//
+// The tables are arrays of C structures located in the data sections of the
+// executable. Their locations differ between versions of the game, and are
+// either provided by an ini file (see Config) or located by searching for
+// pointers to the strings of their first records.
+package exe
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// A File is a PE executable, which provides access to its contents by virtual
+// address.
+type File struct {
+	*pe.File
+	// imageBase is the preferred virtual address of the image.
+	imageBase uint32
+	// sections contains the raw data of each section.
+	sections []section
+}
+
+// section is a section of a PE executable.
+type section struct {
+	// va is the virtual address of the section.
+	va uint32
+	// data is the raw data of the section.
+	data []byte
+}
+
+// Open opens the PE executable located at exePath.
+func Open(exePath string) (f *File, err error) {
+	pf, err := pe.Open(exePath)
+	if err != nil {
+		return nil, err
+	}
+	opt, ok := pf.OptionalHeader.(*pe.OptionalHeader32)
+	if !ok {
+		pf.Close()
+		return nil, fmt.Errorf("exe.Open: %q is not a 32-bit executable", exePath)
+	}
+	f = &File{File: pf, imageBase: opt.ImageBase}
+	for _, s := range pf.Sections {
+		data, err := s.Data()
+		if err != nil {
+			pf.Close()
+			return nil, err
+		}
+		f.sections = append(f.sections, section{va: f.imageBase + s.VirtualAddress, data: data})
+	}
+	return f, nil
+}
+
+// Read returns n bytes located at the virtual address va.
+func (f *File) Read(va uint32, n int) (buf []byte, err error) {
+	for _, s := range f.sections {
+		if va < s.va || va-s.va >= uint32(len(s.data)) {
+			continue
+		}
+		off := int(va - s.va)
+		if off+n > len(s.data) {
+			return nil, fmt.Errorf("exe.File.Read: %d bytes at 0x%08X exceed section", n, va)
+		}
+		return s.data[off : off+n], nil
+	}
+	return nil, fmt.Errorf("exe.File.Read: invalid virtual address 0x%08X", va)
+}
+
+// ReadString returns the NULL-terminated string located at the virtual address
+// va.
+func (f *File) ReadString(va uint32) (s string, err error) {
+	for _, sec := range f.sections {
+		if va < sec.va || va-sec.va >= uint32(len(sec.data)) {
+			continue
+		}
+		data := sec.data[va-sec.va:]
+		end := bytes.IndexByte(data, 0)
+		if end == -1 {
+			return "", errors.New("exe.File.ReadString: unterminated string")
+		}
+		return string(data[:end]), nil
+	}
+	return "", fmt.Errorf("exe.File.ReadString: invalid virtual address 0x%08X", va)
+}
+
+// findString returns the virtual addresses of each occurrence of the NULL-
+// terminated string s, which is preceded by a NULL byte.
+func (f *File) findString(s string) (vas []uint32) {
+	pattern := []byte("\x00" + s + "\x00")
+	for _, sec := range f.sections {
+		for off := 0; ; {
+			i := bytes.Index(sec.data[off:], pattern)
+			if i == -1 {
+				break
+			}
+			vas = append(vas, sec.va+uint32(off+i+1))
+			off += i + 1
+		}
+	}
+	return vas
+}
+
+// findPointer returns the virtual addresses of each 4 byte aligned occurrence of
+// a pointer to va.
+func (f *File) findPointer(va uint32) (vas []uint32) {
+	var pattern [4]byte
+	binary.LittleEndian.PutUint32(pattern[:], va)
+	for _, sec := range f.sections {
+		for off := 0; off+4 <= len(sec.data); off += 4 {
+			if bytes.Equal(sec.data[off:off+4], pattern[:]) {
+				vas = append(vas, sec.va+uint32(off))
+			}
+		}
+	}
+	return vas
+}
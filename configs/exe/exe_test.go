@@ -0,0 +1,106 @@
+package exe
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// The virtual address of the test section.
+const testVA = 0x401000
+
+// newTestFile returns a File of a single section containing data.
+func newTestFile(data []byte) *File {
+	return &File{sections: []section{{va: testVA, data: data}}}
+}
+
+func TestLocate(t *testing.T) {
+	data := make([]byte, 0x1000)
+	put := func(off int, s string) { copy(data[off:], s+"\x00") }
+	put(0x10, "Gold")
+	put(0x20, "Short Sword")
+	put(0x30, "Firebolt")
+	put(0x40, "Healing")
+	// Items at 0x100; Gold, Short Sword and the terminating record.
+	const items = 0x100
+	binary.LittleEndian.PutUint32(data[items+8:], 4)
+	binary.LittleEndian.PutUint32(data[items+16:], testVA+0x10)
+	binary.LittleEndian.PutUint32(data[items+76+8:], 64)
+	binary.LittleEndian.PutUint32(data[items+76+16:], testVA+0x20)
+	// Spells at 0x200; an unused record, Firebolt and Healing.
+	const spells = 0x200
+	binary.LittleEndian.PutUint32(data[spells+56+4:], testVA+0x30)
+	binary.LittleEndian.PutUint32(data[spells+112+4:], testVA+0x40)
+
+	f := newTestFile(data)
+	va, err := f.Locate(Items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if va != testVA+items {
+		t.Fatalf("items address mismatch; expected 0x%08X, got 0x%08X", testVA+items, va)
+	}
+	recs, err := f.Decode(Items, va, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 || recs[0]["curs"] != 4 || recs[1]["name"] != "Short Sword" {
+		t.Fatalf("items mismatch; got %v", recs)
+	}
+	va, err = f.Locate(Spells)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if va != testVA+spells {
+		t.Fatalf("spells address mismatch; expected 0x%08X, got 0x%08X", testVA+spells, va)
+	}
+}
+
+// putObject stores an object record at data[off:].
+func putObject(data []byte, off, fileIndex, frameCount int) {
+	rec := data[off : off+Objects.Size]
+	rec[0] = 1
+	rec[1] = byte(fileIndex)
+	rec[2], rec[3] = 1, 4
+	binary.LittleEndian.PutUint32(rec[8:], 1)
+	binary.LittleEndian.PutUint32(rec[12:], 1)
+	binary.LittleEndian.PutUint32(rec[16:], uint32(frameCount))
+	binary.LittleEndian.PutUint32(rec[20:], 64)
+	binary.LittleEndian.PutUint32(rec[24:], 1)
+}
+
+func TestLocateObjects(t *testing.T) {
+	data := make([]byte, 0x1000)
+	// A short run of object records, which is not the object table.
+	for i := 0; i < 3; i++ {
+		putObject(data, 0x40+i*Objects.Size, i, 10)
+	}
+	data[0x40+3*Objects.Size] = 0xFF
+	// The object table at 0x200; 40 records and the terminating record.
+	const objects = 0x200
+	for i := 0; i < 40; i++ {
+		putObject(data, objects+i*Objects.Size, i%8, 26)
+	}
+	data[objects+40*Objects.Size] = 0xFF
+
+	f := newTestFile(data)
+	va, err := f.Locate(Objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if va != testVA+objects {
+		t.Fatalf("objects address mismatch; expected 0x%08X, got 0x%08X", testVA+objects, va)
+	}
+	recs, err := f.Decode(Objects, va, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 40 || recs[9]["file_index"] != 1 || recs[0]["frame_count"] != 26 {
+		t.Fatalf("objects mismatch; got %d records", len(recs))
+	}
+
+	// Without the terminating record, the object table is not located.
+	data[objects+40*Objects.Size] = 0
+	if _, err := f.Locate(Objects); err == nil {
+		t.Fatal("expected error for missing terminating record")
+	}
+}
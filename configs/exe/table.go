@@ -0,0 +1,200 @@
+package exe
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// A Table describes the layout of a data table of the game.
+type Table struct {
+	// Name is the name of the table, which is used as the name of its ini file
+	// and its keys in the exe ini file.
+	Name string
+	// Size is the size of each record in bytes.
+	Size int
+	// Fields contains the fields of each record.
+	Fields []Field
+	// Anchor is the name of a string field, and AnchorValue the value of the
+	// field in record AnchorIndex of the table; used to locate the table.
+	Anchor      string
+	AnchorValue string
+	AnchorIndex int
+	// Valid reports whether the raw record is a plausible record of the table;
+	// used to locate tables without an anchor, by searching for the longest run
+	// of at least Min valid records which ends with the terminating record.
+	Valid func(rec []byte) bool
+	Min   int
+	// End reports whether the raw record marks the end of the table. The table
+	// also ends at the first invalid record.
+	End func(rec []byte) bool
+	// Max is the maximum number of records of the table.
+	Max int
+}
+
+// A Field is a field of the records of a table.
+type Field struct {
+	// Name is the name of the field, which is used as its ini key.
+	Name string
+	// Offset is the offset of the field within each record.
+	Offset int
+	// Kind is the kind of the field.
+	Kind Kind
+}
+
+// Kind specifies the type of a field.
+type Kind int
+
+// Field kinds.
+const (
+	// Int8 is a signed 8-bit integer.
+	Int8 Kind = iota
+	// Uint8 is an unsigned 8-bit integer.
+	Uint8
+	// Uint16 is an unsigned 16-bit integer.
+	Uint16
+	// Int32 is a signed 32-bit integer.
+	Int32
+	// String is a pointer to a NULL-terminated string; 0 for no string.
+	String
+)
+
+// size returns the size of a field of the given kind in bytes.
+func (kind Kind) size() int {
+	switch kind {
+	case Int8, Uint8:
+		return 1
+	case Uint16:
+		return 2
+	}
+	return 4
+}
+
+// A Record is a decoded record of a table; it maps from field names to values,
+// which are either int or string.
+type Record map[string]interface{}
+
+// Decode decodes count records of the table located at the virtual address
+// va. A count of -1 decodes records until the end of the table.
+func (f *File) Decode(table *Table, va uint32, count int) (recs []Record, err error) {
+	n := count
+	if count == -1 {
+		n = table.Max
+	}
+	for i := 0; i < n; i++ {
+		buf, err := f.Read(va+uint32(i*table.Size), table.Size)
+		if err == nil {
+			if count == -1 && table.End != nil && table.End(buf) {
+				break
+			}
+			var rec Record
+			rec, err = f.decodeRecord(table, buf)
+			if err == nil {
+				recs = append(recs, rec)
+				continue
+			}
+		}
+		if count == -1 {
+			break
+		}
+		return nil, fmt.Errorf("exe.File.Decode: invalid record %d of %s: %v", i, table.Name, err)
+	}
+	return recs, nil
+}
+
+// decodeRecord decodes the fields of a record contained within buf.
+func (f *File) decodeRecord(table *Table, buf []byte) (rec Record, err error) {
+	rec = make(Record)
+	for _, field := range table.Fields {
+		data := buf[field.Offset : field.Offset+field.Kind.size()]
+		switch field.Kind {
+		case Int8:
+			rec[field.Name] = int(int8(data[0]))
+		case Uint8:
+			rec[field.Name] = int(data[0])
+		case Uint16:
+			rec[field.Name] = int(binary.LittleEndian.Uint16(data))
+		case Int32:
+			rec[field.Name] = int(int32(binary.LittleEndian.Uint32(data)))
+		case String:
+			ptr := binary.LittleEndian.Uint32(data)
+			if ptr == 0 {
+				rec[field.Name] = ""
+				continue
+			}
+			s, err := f.ReadString(ptr)
+			if err != nil {
+				return nil, err
+			}
+			if !isPrint(s) {
+				return nil, fmt.Errorf("non-printable string %q of field %s", s, field.Name)
+			}
+			rec[field.Name] = s
+		}
+	}
+	return rec, nil
+}
+
+// Locate locates the table, based on the anchor of the table; the first
+// candidate whose records up to and including the record following the anchor
+// decode successfully is used. Tables without an anchor are located based on
+// the structure of their records (see Table.Valid).
+func (f *File) Locate(table *Table) (va uint32, err error) {
+	if table.Anchor == "" && table.Valid != nil {
+		return f.locateRun(table)
+	}
+	var anchor *Field
+	for i := range table.Fields {
+		if table.Fields[i].Name == table.Anchor {
+			anchor = &table.Fields[i]
+		}
+	}
+	if anchor == nil {
+		return 0, fmt.Errorf("exe.File.Locate: no anchor for %s", table.Name)
+	}
+	for _, strVA := range f.findString(table.AnchorValue) {
+		for _, ptrVA := range f.findPointer(strVA) {
+			va := ptrVA - uint32(anchor.Offset+table.AnchorIndex*table.Size)
+			_, err := f.Decode(table, va, table.AnchorIndex+2)
+			if err != nil {
+				continue
+			}
+			return va, nil
+		}
+	}
+	return 0, fmt.Errorf("exe.File.Locate: unable to locate %s", table.Name)
+}
+
+// locateRun locates the table, based on the longest 4 byte aligned run of
+// valid records which ends with the terminating record.
+func (f *File) locateRun(table *Table) (va uint32, err error) {
+	bestCount := 0
+	for _, sec := range f.sections {
+		for off := 0; off+table.Size <= len(sec.data); off += 4 {
+			n := 0
+			end := off
+			for ; end+table.Size <= len(sec.data) && n < table.Max; end += table.Size {
+				rec := sec.data[end : end+table.Size]
+				if table.End(rec) || !table.Valid(rec) {
+					break
+				}
+				n++
+			}
+			if n < table.Min || n <= bestCount || end+table.Size > len(sec.data) || !table.End(sec.data[end:end+table.Size]) {
+				continue
+			}
+			va, bestCount = sec.va+uint32(off), n
+			// The following candidates are contained within the run.
+			off = end - 4
+		}
+	}
+	if bestCount == 0 {
+		return 0, fmt.Errorf("exe.File.Locate: unable to locate %s", table.Name)
+	}
+	return va, nil
+}
+
+// isPrint reports whether s only contains printable ASCII characters.
+func isPrint(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return r < 0x20 || r > 0x7E }) == -1
+}
@@ -0,0 +1,195 @@
+package exe
+
+import "encoding/binary"
+
+// The layouts of the tables are based on the structures of Diablo 1.09, as
+// reconstructed by the Devilution project.
+
+// Monsters is the monster table (MonsterData), which ends at the first record
+// without a name.
+var Monsters = &Table{
+	Name: "monsters",
+	Size: 128,
+	Fields: []Field{
+		{Name: "width", Offset: 0, Kind: Int32},
+		{Name: "image", Offset: 4, Kind: Int32},
+		{Name: "graphic", Offset: 8, Kind: String},
+		{Name: "has_special", Offset: 12, Kind: Int32},
+		{Name: "sound", Offset: 16, Kind: String},
+		{Name: "sound_special", Offset: 20, Kind: Int32},
+		{Name: "has_trn", Offset: 24, Kind: Int32},
+		{Name: "trn", Offset: 28, Kind: String},
+		{Name: "frames_stand", Offset: 32, Kind: Int32},
+		{Name: "frames_walk", Offset: 36, Kind: Int32},
+		{Name: "frames_attack", Offset: 40, Kind: Int32},
+		{Name: "frames_hit", Offset: 44, Kind: Int32},
+		{Name: "frames_death", Offset: 48, Kind: Int32},
+		{Name: "frames_special", Offset: 52, Kind: Int32},
+		{Name: "rate_stand", Offset: 56, Kind: Int32},
+		{Name: "rate_walk", Offset: 60, Kind: Int32},
+		{Name: "rate_attack", Offset: 64, Kind: Int32},
+		{Name: "rate_hit", Offset: 68, Kind: Int32},
+		{Name: "rate_death", Offset: 72, Kind: Int32},
+		{Name: "rate_special", Offset: 76, Kind: Int32},
+		{Name: "name", Offset: 80, Kind: String},
+		{Name: "min_dlvl", Offset: 84, Kind: Int8},
+		{Name: "max_dlvl", Offset: 85, Kind: Int8},
+		{Name: "level", Offset: 86, Kind: Int8},
+		{Name: "min_hp", Offset: 88, Kind: Int32},
+		{Name: "max_hp", Offset: 92, Kind: Int32},
+		{Name: "ai", Offset: 96, Kind: Int8},
+		{Name: "flags", Offset: 100, Kind: Int32},
+		{Name: "intelligence", Offset: 104, Kind: Uint8},
+		{Name: "hit", Offset: 105, Kind: Uint8},
+		{Name: "attack_frame", Offset: 106, Kind: Uint8},
+		{Name: "min_damage", Offset: 107, Kind: Uint8},
+		{Name: "max_damage", Offset: 108, Kind: Uint8},
+		{Name: "hit2", Offset: 109, Kind: Uint8},
+		{Name: "attack_frame2", Offset: 110, Kind: Uint8},
+		{Name: "min_damage2", Offset: 111, Kind: Uint8},
+		{Name: "max_damage2", Offset: 112, Kind: Uint8},
+		{Name: "armor_class", Offset: 113, Kind: Uint8},
+		{Name: "class", Offset: 114, Kind: Int8},
+		{Name: "resistance", Offset: 116, Kind: Uint16},
+		{Name: "resistance_hell", Offset: 118, Kind: Uint16},
+		{Name: "treasure", Offset: 120, Kind: Uint16},
+		{Name: "selection", Offset: 122, Kind: Int8},
+		{Name: "exp", Offset: 124, Kind: Uint16},
+	},
+	Anchor:      "name",
+	AnchorValue: "Zombie",
+	End:         func(rec []byte) bool { return rec[80]|rec[81]|rec[82]|rec[83] == 0 },
+	Max:         256,
+}
+
+// ObjectFiles is the table of object graphics (ObjMasterLoadList), which is
+// indexed by the file_index field of the object table.
+var ObjectFiles = &Table{
+	Name: "object_files",
+	Size: 4,
+	Fields: []Field{
+		{Name: "file", Offset: 0, Kind: String},
+	},
+	Anchor:      "file",
+	AnchorValue: "L1Braz",
+	End:         func(rec []byte) bool { return rec[0]|rec[1]|rec[2]|rec[3] == 0 },
+	Max:         256,
+}
+
+// Objects is the object table (AllObjects), which ends with a record whose
+// load field is -1. The table contains no strings, and is therefore located
+// based on the structure of its records, unless located by the exe ini file.
+var Objects = &Table{
+	Name: "objects",
+	Size: 44,
+	Fields: []Field{
+		{Name: "load", Offset: 0, Kind: Int8},
+		{Name: "file_index", Offset: 1, Kind: Int8},
+		{Name: "min_dlvl", Offset: 2, Kind: Int8},
+		{Name: "max_dlvl", Offset: 3, Kind: Int8},
+		{Name: "dtype", Offset: 4, Kind: Int8},
+		{Name: "theme", Offset: 5, Kind: Int8},
+		{Name: "quest", Offset: 6, Kind: Int8},
+		{Name: "anim_flag", Offset: 8, Kind: Int32},
+		{Name: "ticks_per_frame", Offset: 12, Kind: Int32},
+		{Name: "frame_count", Offset: 16, Kind: Int32},
+		{Name: "width", Offset: 20, Kind: Int32},
+		{Name: "solid", Offset: 24, Kind: Int32},
+		{Name: "missile", Offset: 28, Kind: Int32},
+		{Name: "light", Offset: 32, Kind: Int32},
+		{Name: "break", Offset: 36, Kind: Int8},
+		{Name: "selection", Offset: 37, Kind: Int8},
+		{Name: "trap", Offset: 40, Kind: Int32},
+	},
+	Valid: func(rec []byte) bool {
+		// The anim_flag, solid, missile, light and trap fields are booleans.
+		for _, off := range []int{8, 24, 28, 32, 40} {
+			if binary.LittleEndian.Uint32(rec[off:]) > 1 {
+				return false
+			}
+		}
+		// The padding of the structure is zero.
+		if rec[7] != 0 || rec[38] != 0 || rec[39] != 0 {
+			return false
+		}
+		fileIndex, minDlvl, maxDlvl := int8(rec[1]), int8(rec[2]), int8(rec[3])
+		width := int32(binary.LittleEndian.Uint32(rec[20:]))
+		return fileIndex >= 0 && minDlvl >= 0 && minDlvl <= 24 && maxDlvl >= 0 && maxDlvl <= 24 && width > 0 && width <= 512
+	},
+	Min: 32,
+	End: func(rec []byte) bool { return rec[0] == 0xFF },
+	Max: 256,
+}
+
+// Items is the base item table (AllItemsList), which ends with a record without
+// a name. The name and curs fields are compatible with the items ini file of
+// the itemconf package.
+var Items = &Table{
+	Name: "items",
+	Size: 76,
+	Fields: []Field{
+		{Name: "drop", Offset: 0, Kind: Int32},
+		{Name: "class", Offset: 4, Kind: Int8},
+		{Name: "location", Offset: 5, Kind: Int8},
+		{Name: "curs", Offset: 8, Kind: Int32},
+		{Name: "type", Offset: 12, Kind: Int8},
+		{Name: "unique_type", Offset: 13, Kind: Int8},
+		{Name: "name", Offset: 16, Kind: String},
+		{Name: "short_name", Offset: 20, Kind: String},
+		{Name: "min_mlvl", Offset: 24, Kind: Int8},
+		{Name: "durability", Offset: 28, Kind: Int32},
+		{Name: "min_damage", Offset: 32, Kind: Int32},
+		{Name: "max_damage", Offset: 36, Kind: Int32},
+		{Name: "min_armor", Offset: 40, Kind: Int32},
+		{Name: "max_armor", Offset: 44, Kind: Int32},
+		{Name: "min_str", Offset: 48, Kind: Int8},
+		{Name: "min_mag", Offset: 49, Kind: Int8},
+		{Name: "min_dex", Offset: 50, Kind: Int8},
+		{Name: "flags", Offset: 52, Kind: Int32},
+		{Name: "misc", Offset: 56, Kind: Int32},
+		{Name: "spell", Offset: 60, Kind: Int32},
+		{Name: "usable", Offset: 64, Kind: Int32},
+		{Name: "value", Offset: 68, Kind: Int32},
+		{Name: "max_value", Offset: 72, Kind: Int32},
+	},
+	Anchor:      "name",
+	AnchorValue: "Gold",
+	End:         func(rec []byte) bool { return rec[16]|rec[17]|rec[18]|rec[19] == 0 },
+	Max:         512,
+}
+
+// Spells is the spell table (spelldata). The first record is unused, and the
+// table is therefore located using its second record.
+var Spells = &Table{
+	Name: "spells",
+	Size: 56,
+	Fields: []Field{
+		{Name: "id", Offset: 0, Kind: Uint8},
+		{Name: "mana_cost", Offset: 1, Kind: Uint8},
+		{Name: "type", Offset: 2, Kind: Uint8},
+		{Name: "name", Offset: 4, Kind: String},
+		{Name: "skill_name", Offset: 8, Kind: String},
+		{Name: "book_level", Offset: 12, Kind: Int32},
+		{Name: "staff_level", Offset: 16, Kind: Int32},
+		{Name: "targeted", Offset: 20, Kind: Int32},
+		{Name: "town", Offset: 24, Kind: Int32},
+		{Name: "min_int", Offset: 28, Kind: Int32},
+		{Name: "sfx", Offset: 32, Kind: Uint8},
+		{Name: "missile", Offset: 33, Kind: Uint8},
+		{Name: "missile2", Offset: 34, Kind: Uint8},
+		{Name: "missile3", Offset: 35, Kind: Uint8},
+		{Name: "mana_adj", Offset: 36, Kind: Uint8},
+		{Name: "min_mana", Offset: 37, Kind: Uint8},
+		{Name: "staff_min", Offset: 40, Kind: Int32},
+		{Name: "staff_max", Offset: 44, Kind: Int32},
+		{Name: "book_cost", Offset: 48, Kind: Int32},
+		{Name: "staff_cost", Offset: 52, Kind: Int32},
+	},
+	Anchor:      "name",
+	AnchorValue: "Firebolt",
+	AnchorIndex: 1,
+	Max:         37,
+}
+
+// Tables contains the data tables of the game, in the order of extraction.
+var Tables = []*Table{Monsters, ObjectFiles, Objects, Items, Spells}
@@ -9,7 +9,7 @@
 //
 // Pillar format:
 //    // blocks contains 10 blocks for l1.min, l2.min and l3.min and 16 blocks
-//    // for l4.min and town.min.
+//    // for l4.min and town.min (ref: BlockCount).
 //    //
 //    // ref: BlockRect (block arrangement illustration)
 //    blocks [blockCount]uint16
@@ -23,10 +23,10 @@ package min
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
-	"os"
-
-	"github.com/mewrnd/blizzconv/mpq"
+	"io/fs"
+	"path"
 )
 
 // Pillar contains 10 to 16 blocks, each corresponding to a frame in a CEL image
@@ -46,23 +46,21 @@ type Block struct {
 }
 
 // Parse parses a given MIN file and returns a slice of pillars, based on the
-// MIN format described above.
-func Parse(minName string) (pillars []Pillar, err error) {
-	minPath, err := mpq.GetPath(minName)
+// MIN format described above. The MIN file is located at relMinPath within
+// fsys.
+func Parse(fsys fs.FS, relMinPath string) (pillars []Pillar, err error) {
+	fr, err := fsys.Open(relMinPath)
 	if err != nil {
 		return nil, err
 	}
-	fr, err := os.Open(minPath)
+	defer fr.Close()
+	fi, err := fr.Stat()
 	if err != nil {
 		return nil, err
 	}
-	defer fr.Close()
-	var blockCount int
-	switch minName {
-	case "l1.min", "l2.min", "l3.min":
-		blockCount = 10
-	case "l4.min", "town.min":
-		blockCount = 16
+	blockCount := BlockCount(path.Base(relMinPath), fi.Size())
+	if blockCount == 0 {
+		return nil, fmt.Errorf("min.Parse: unable to determine block count of %q", relMinPath)
 	}
 	tmp := make([]uint16, blockCount)
 	for {
@@ -87,3 +85,62 @@ func Parse(minName string) (pillars []Pillar, err error) {
 	}
 	return pillars, nil
 }
+
+// BlockCount returns the number of blocks per pillar of the MIN file with the
+// given name and size in bytes. The block count of other MIN files (e.g. of
+// modded levels) is derived from the file size, and 0 is returned if the size
+// fits either block count.
+func BlockCount(minName string, size int64) int {
+	switch minName {
+	case "l1.min", "l2.min", "l3.min", "l5.min", "l6.min":
+		return 10
+	case "l4.min", "town.min":
+		return 16
+	}
+	fits10 := size%(2*10) == 0
+	fits16 := size%(2*16) == 0
+	switch {
+	case fits10 && !fits16:
+		return 10
+	case fits16 && !fits10:
+		return 16
+	}
+	return 0
+}
+
+// FrameTypes returns a map from frameNum to the Type of the blocks which refer
+// to the frame, based on the given pillars. The map specifies which CEL decode
+// algorithm should be used to decode each frame of the CEL image level file.
+func FrameTypes(pillars []Pillar) map[int]int {
+	frameTypes := make(map[int]int)
+	for _, pillar := range pillars {
+		for _, block := range pillar.Blocks {
+			if !block.IsValid {
+				continue
+			}
+			frameTypes[block.FrameNum] = block.Type
+		}
+	}
+	return frameTypes
+}
+
+// GetFrameTypes returns a map from frameNum to frame type for the CEL image
+// level file located at relCelPath within fsys, based on the MIN file of the
+// same name in the same directory (e.g. levels/l1data/l1.min for
+// levels/l1data/l1.cel). A nil map is returned if no such MIN file exists, or
+// if its block count cannot be determined (ref: BlockCount).
+func GetFrameTypes(fsys fs.FS, relCelPath string) (frameTypes map[int]int, err error) {
+	relMinPath := relCelPath[:len(relCelPath)-len(path.Ext(relCelPath))] + ".min"
+	fi, err := fs.Stat(fsys, relMinPath)
+	if err != nil {
+		return nil, nil
+	}
+	if BlockCount(path.Base(relMinPath), fi.Size()) == 0 {
+		return nil, nil
+	}
+	pillars, err := Parse(fsys, relMinPath)
+	if err != nil {
+		return nil, err
+	}
+	return FrameTypes(pillars), nil
+}
@@ -0,0 +1,55 @@
+package min
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestBlockCount(t *testing.T) {
+	golden := []struct {
+		minName string
+		size    int64
+		want    int
+	}{
+		{minName: "l1.min", size: 160, want: 10},
+		{minName: "town.min", size: 160, want: 16},
+		{minName: "l5.min", size: 160, want: 10},
+		{minName: "mod.min", size: 60, want: 10},
+		{minName: "mod.min", size: 96, want: 16},
+		{minName: "mod.min", size: 160, want: 0},
+	}
+	for _, g := range golden {
+		got := BlockCount(g.minName, g.size)
+		if got != g.want {
+			t.Errorf("%q of %d bytes: block count mismatch; expected %d, got %d", g.minName, g.size, g.want, got)
+		}
+	}
+}
+
+func TestGetFrameTypes(t *testing.T) {
+	data := make([]byte, 2*10)
+	// Frame 4 of type 2.
+	data[0], data[1] = 0x05, 0x20
+	// Frame 0 of type 1.
+	data[2], data[3] = 0x01, 0x10
+	fsys := fstest.MapFS{
+		"levels/l1data/l1.min":  {Data: data},
+		"levels/mod/mod.min":    {Data: data},
+		"levels/mod/mod160.min": {Data: make([]byte, 160)},
+	}
+	for _, relCelPath := range []string{"levels/l1data/l1.cel", "levels/mod/mod.cel"} {
+		frameTypes, err := GetFrameTypes(fsys, relCelPath)
+		if err != nil {
+			t.Fatalf("%q: %v", relCelPath, err)
+		}
+		if len(frameTypes) != 2 || frameTypes[4] != 2 || frameTypes[0] != 1 {
+			t.Fatalf("%q: frame types mismatch; got %v", relCelPath, frameTypes)
+		}
+	}
+	for _, relCelPath := range []string{"levels/l2data/l2.cel", "levels/mod/mod160.cel"} {
+		frameTypes, err := GetFrameTypes(fsys, relCelPath)
+		if err != nil || frameTypes != nil {
+			t.Fatalf("%q: expected no frame types, got %v (%v)", relCelPath, frameTypes, err)
+		}
+	}
+}
@@ -25,9 +25,7 @@ package sol
 import (
 	"encoding/binary"
 	"io"
-	"os"
-
-	"github.com/mewrnd/blizzconv/mpq"
+	"io/fs"
 )
 
 // Solid defines the solid properties of a pillar.
@@ -43,13 +41,10 @@ type Solid struct {
 }
 
 // Parse parses a given SOL file and returns a slice of solids, based on the
-// SOL format described above.
-func Parse(solName string) (solids []Solid, err error) {
-	solPath, err := mpq.GetPath(solName)
-	if err != nil {
-		return nil, err
-	}
-	fr, err := os.Open(solPath)
+// SOL format described above. The SOL file is located at relSolPath within
+// fsys.
+func Parse(fsys fs.FS, relSolPath string) (solids []Solid, err error) {
+	fr, err := fsys.Open(relSolPath)
 	if err != nil {
 		return nil, err
 	}
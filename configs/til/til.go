@@ -19,9 +19,7 @@ package til
 import (
 	"encoding/binary"
 	"io"
-	"os"
-
-	"github.com/mewrnd/blizzconv/mpq"
+	"io/fs"
 )
 
 // Square is constructed of four pillars (top, right, left and bottom).
@@ -35,13 +33,10 @@ type Square struct {
 }
 
 // Parse parses a given TIL file and returns a slice of squares, based on the
-// TIL format described above.
-func Parse(tilName string) (squares []Square, err error) {
-	tilPath, err := mpq.GetPath(tilName)
-	if err != nil {
-		return nil, err
-	}
-	fr, err := os.Open(tilPath)
+// TIL format described above. The TIL file is located at relTilPath within
+// fsys.
+func Parse(fsys fs.FS, relTilPath string) (squares []Square, err error) {
+	fr, err := fsys.Open(relTilPath)
 	if err != nil {
 		return nil, err
 	}
@@ -1,4 +1,4 @@
-// Package cel implements a CEL image decoder.
+// Package cel implements a CEL image decoder and encoder.
 //
 // There are many similarities between CEL and GIF images. Both can contain
 // multiple frames and use palettes. Below is a description of the CEL image
@@ -24,11 +24,10 @@
 package cel
 
 import (
-	"encoding/binary"
-	"fmt"
 	"image"
 	"image/color"
-	"os"
+	"io/fs"
+	"path"
 
 	"github.com/mewrnd/blizzconv/images/imgconf"
 	"github.com/mewrnd/blizzconv/mpq"
@@ -48,34 +47,39 @@ type Config struct {
 	FrameHeight map[int]int
 	// The palette used for decoding.
 	Pal color.Palette
+	// The size of each frame header in bytes, which is ignored while decoding.
+	HeaderSize int
+	// A map from frameNum to frame type. It's used to select the decoding
+	// function of each frame in CEL image level files, as specified by the MIN
+	// blocks which refer to the frame (ref: min.FrameTypes).
+	FrameTypes map[int]int
 }
 
 // DecodeAll returns the sequential frames of a CEL image based on a given conf.
+// The CEL image is located at relCelPath within fsys.
 //
-// Note: The absolute path of celName is resolved using mpq.GetPath.
-func DecodeAll(celName string, conf *Config) (imgs []image.Image, err error) {
-	// Get frame contents.
-	frames, err := GetFrames(celName)
+// The frames are paletted images (*image.Paletted or *MaskedPaletted), whose
+// transparent pixels use a reserved palette index or a mask (see FrameWriter).
+// The frames may be recolored using other palettes without decoding them again
+// (see Recolor).
+func DecodeAll(fsys fs.FS, relCelPath string, conf *Config) (imgs []image.Image, err error) {
+	// Open CEL file.
+	f, err := mpq.OpenFile(fsys, relCelPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d, err := NewDecoder(f, path.Base(relCelPath), conf)
 	if err != nil {
 		return nil, err
 	}
 
 	// Decode frames.
-	for frameNum, frame := range frames {
-		width, ok := conf.FrameWidth[frameNum]
-		if !ok {
-			// Use default frame width.
-			width = conf.Width
-		}
-		height, ok := conf.FrameHeight[frameNum]
-		if !ok {
-			// Use default frame height.
-			height = conf.Height
+	for frameNum := 0; frameNum < d.FrameCount(); frameNum++ {
+		img, err := d.DecodeFrame(frameNum)
+		if err != nil {
+			return nil, err
 		}
-
-		// Decode frame.
-		decodeFrame := GetFrameDecoder(celName, frame, frameNum)
-		img := decodeFrame(frame, width, height, conf.Pal)
 		imgs = append(imgs, img)
 	}
 
@@ -83,78 +87,54 @@ func DecodeAll(celName string, conf *Config) (imgs []image.Image, err error) {
 }
 
 // GetFrames returns a slice of frames, whose content has been retrieved based
-// on the CEL format described above.
+// on the CEL format described above. The CEL image is located at relCelPath
+// within fsys, and the first headerSize bytes of each frame are ignored.
 //
-// Note: The absolute path of celName is resolved using mpq.GetPath.
-func GetFrames(celName string) (frames [][]byte, err error) {
+// Use a Decoder to access individual frames without reading every frame.
+func GetFrames(fsys fs.FS, relCelPath string, headerSize int) (frames [][]byte, err error) {
 	// Open CEL file.
-	celPath, err := mpq.GetPath(celName)
-	if err != nil {
-		return nil, err
-	}
-	f, err := os.Open(celPath)
+	f, err := mpq.OpenFile(fsys, relCelPath)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
-
-	// Read frame count.
-	var frameCount uint32
-	err = binary.Read(f, binary.LittleEndian, &frameCount)
+	d, err := NewDecoder(f, path.Base(relCelPath), &Config{HeaderSize: headerSize})
 	if err != nil {
-		return nil, fmt.Errorf("cel.GetFrames: unable to read frame count for %q: %v", celName, err)
-	}
-
-	// Read frame offsets.
-	frameOffsets := make([]uint32, frameCount+1)
-	err = binary.Read(f, binary.LittleEndian, frameOffsets)
-	if err != nil {
-		return nil, fmt.Errorf("cel.GetFrames: unable to read frame offsets for %q: %v", celName, err)
+		return nil, err
 	}
 
 	// Read frame contents.
-	frames = make([][]byte, frameCount)
+	frames = make([][]byte, d.FrameCount())
 	for frameNum := range frames {
-		// Ignore frame header.
-		headerSize := imgconf.GetHeaderSize(celName)
-		frameStart := int64(frameOffsets[frameNum]) + int64(headerSize)
-
-		// Read frame content.
-		frameEnd := int64(frameOffsets[frameNum+1])
-		frameSize := frameEnd - frameStart
-		frame := make([]byte, frameSize)
-		_, err = f.ReadAt(frame, frameStart)
+		frames[frameNum], err = d.Frame(frameNum)
 		if err != nil {
-			return nil, fmt.Errorf("cel.GetFrames: unable to read frame content for %q: %v", celName, err)
+			return nil, err
 		}
-		frames[frameNum] = frame
 	}
 
 	return frames, nil
 }
 
-// GetConf returns a conf containing the relevant image information.
-//
-// Note: The absolute path of celName is resolved using mpq.GetPath and
-// relPalPath is relative to mpq.ExtractPath.
-func GetConf(celName, relPalPath string) (conf *Config, err error) {
-	width, err := imgconf.GetWidth(celName)
+// GetConf returns a conf containing the relevant image information, as
+// provided by imgConf. The PAL file is located at relPalPath within fsys.
+func GetConf(fsys fs.FS, imgConf *imgconf.Config, celName, relPalPath string) (conf *Config, err error) {
+	width, err := imgConf.GetWidth(celName)
 	if err != nil {
 		return nil, err
 	}
-	height, err := imgconf.GetHeight(celName)
+	height, err := imgConf.GetHeight(celName)
 	if err != nil {
 		return nil, err
 	}
-	frameWidth, err := imgconf.GetFrameWidth(celName)
+	frameWidth, err := imgConf.GetFrameWidth(celName)
 	if err != nil {
 		return nil, err
 	}
-	frameHeight, err := imgconf.GetFrameHeight(celName)
+	frameHeight, err := imgConf.GetFrameHeight(celName)
 	if err != nil {
 		return nil, err
 	}
-	pal, err := GetPal(relPalPath)
+	pal, err := GetPal(fsys, relPalPath)
 	if err != nil {
 		return nil, err
 	}
@@ -164,6 +144,7 @@ func GetConf(celName, relPalPath string) (conf *Config, err error) {
 		FrameWidth:  frameWidth,
 		FrameHeight: frameHeight,
 		Pal:         pal,
+		HeaderSize:  imgConf.GetHeaderSize(celName),
 	}
 	return conf, nil
 }
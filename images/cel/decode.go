@@ -1,71 +1,124 @@
 package cel
 
 import (
+	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
 )
 
-// GetFrameDecoder returns the appropriate function for decoding the frame.
-func GetFrameDecoder(celName string, frame []byte, frameNum int) func(frame []byte, width int, height int, pal color.Palette) image.Image {
-	frameSize := len(frame)
-	switch celName {
-	case "l1.cel", "l2.cel", "l3.cel", "l4.cel", "town.cel":
-		// Some regular (type 1) CEL images just happen to have a frame size of
-		// exactly 0x220, 0x320 or 0x400. Therefore the isType* functions are
-		// required to figure out the appropriate decoding function.
-		switch frameSize {
-		case 0x400:
-			if isType0(celName, frameNum) {
-				return DecodeFrameType0
-			}
-		case 0x220:
-			if isType2or4(frame) {
-				return DecodeFrameType2
-			} else if isType3or5(frame) {
-				return DecodeFrameType3
-			}
-		case 0x320:
-			if isType2or4(frame) {
-				return DecodeFrameType4
-			} else if isType3or5(frame) {
-				return DecodeFrameType5
-			}
+// A FrameDecoder decodes a frame of the given dimensions using a palette.
+type FrameDecoder func(frame []byte, width int, height int, pal color.Palette) image.Image
+
+// frameDecoders maps from frame types to frame decoders.
+var frameDecoders = []FrameDecoder{
+	DecodeFrameType0,
+	DecodeFrameType1,
+	DecodeFrameType2,
+	DecodeFrameType3,
+	DecodeFrameType4,
+	DecodeFrameType5,
+}
+
+// GetFrameDecoder returns the appropriate function for decoding the frame. The
+// frame types of level CEL images are specified by frameTypes, which maps from
+// frameNum to the Type of the MIN blocks that refer to the frame (ref:
+// min.FrameTypes). Images of frame types are level CEL images, as are the CEL
+// images of the original levels (e.g. l1.cel).
+//
+// The frame type of level frames which aren't present in frameTypes is
+// determined based on the frame size and content. An error is returned if the
+// frame type is ambiguous, e.g. for regular (type 1) frames which happen to
+// have a frame size of exactly 0x400 bytes, as these can only be told apart
+// from plain 32x32 frames (type 0) using the MIN blocks.
+func GetFrameDecoder(celName string, frame []byte, frameNum int, frameTypes map[int]int) (decodeFrame FrameDecoder, err error) {
+	if frameType, ok := frameTypes[frameNum]; ok {
+		if frameType < 0 || frameType >= len(frameDecoders) {
+			return nil, fmt.Errorf("cel.GetFrameDecoder: invalid frame type (%d) of frame %d of %q", frameType, frameNum, celName)
 		}
+		return frameDecoders[frameType], nil
+	}
+	if frameTypes == nil && !isLevel(celName) {
+		// Regular frame (type 1).
+		return DecodeFrameType1, nil
+	}
+	frameType, err := getLevelFrameType(frame)
+	if err != nil {
+		return nil, fmt.Errorf("cel.GetFrameDecoder: %v of frame %d of %q", err, frameNum, celName)
 	}
-	// Regular frame (type 1).
-	return DecodeFrameType1
+	return frameDecoders[frameType], nil
 }
 
-// isType0 returns true if the image is a plain 32x32.
-//
-// ref: DecodeFrameType0
-func isType0(celName string, frameNum int) bool {
-	// The following frames are of type 1, thus return false.
-	switch celName {
-	case "l1.cel":
-		switch frameNum {
-		case 148, 159, 181, 186, 188:
-			return false
+// getLevelFrameType returns the frame type of the level frame, based on its
+// frame size and content.
+func getLevelFrameType(frame []byte) (frameType int, err error) {
+	// Some regular (type 1) level frames just happen to have a frame size of
+	// exactly 0x220, 0x320 or 0x400. Therefore the isType* functions are
+	// required to figure out the appropriate frame type.
+	var candidates []int
+	if isType1(frame, levelFrameWidth, levelFrameHeight) {
+		candidates = append(candidates, 1)
+	}
+	switch len(frame) {
+	case 0x400:
+		candidates = append(candidates, 0)
+	case 0x220:
+		if isType2or4(frame) {
+			candidates = append(candidates, 2)
+		} else if isType3or5(frame) {
+			candidates = append(candidates, 3)
 		}
-	case "l2.cel":
-		switch frameNum {
-		case 47, 1397, 1399, 1411:
-			return false
+	case 0x320:
+		if isType2or4(frame) {
+			candidates = append(candidates, 4)
+		} else if isType3or5(frame) {
+			candidates = append(candidates, 5)
 		}
-	case "l4.cel":
-		switch frameNum {
-		case 336, 639:
-			return false
+	}
+	switch len(candidates) {
+	case 0:
+		return 0, fmt.Errorf("unknown frame type")
+	case 1:
+		return candidates[0], nil
+	}
+	return 0, fmt.Errorf("ambiguous frame type; frame type of MIN file required")
+}
+
+// The width and height of level frames in pixels.
+const (
+	levelFrameWidth  = 32
+	levelFrameHeight = 32
+)
+
+// isLevel returns true if the CEL image is the level CEL image of an original
+// level.
+func isLevel(celName string) bool {
+	switch celName {
+	case "l1.cel", "l2.cel", "l3.cel", "l4.cel", "l5.cel", "l6.cel", "town.cel":
+		return true
+	}
+	return false
+}
+
+// isType1 returns true if the frame is a valid regular frame of the given
+// dimensions; i.e. its chunks specify exactly one pixel per pixel of the image.
+//
+// ref: DecodeFrameType1
+func isType1(frame []byte, width, height int) bool {
+	pixelCount := 0
+	for pos := 0; pos < len(frame); {
+		chunkSize := int(int8(frame[pos]))
+		pos++
+		if chunkSize < 0 {
+			pixelCount -= chunkSize
+		} else {
+			pixelCount += chunkSize
+			pos += chunkSize
 		}
-	case "town.cel":
-		switch frameNum {
-		case 2328, 2367, 2593:
+		if pos > len(frame) || pixelCount > width*height {
 			return false
 		}
 	}
-
-	return true
+	return pixelCount == width*height
 }
 
 // isType2or4 returns true if the image is a triangle or a trapezoid pointing to
@@ -126,41 +179,22 @@ func isType3or5(frame []byte) bool {
 //
 // Type1 corresponds to a regular CEL frame image of the specified dimensions.
 func DecodeFrameType1(frame []byte, width int, height int, pal color.Palette) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
-	setPixel := GetPixelSetter(width, height)
+	fw := NewFrameWriter(width, height, pal)
 	for pos := 0; pos < len(frame); {
 		chunkSize := int(int8(frame[pos]))
 		pos++
 		if chunkSize < 0 {
 			// Transparent pixels.
 			for i := 0; i > chunkSize; i-- {
-				setPixel(img, color.Transparent)
+				fw.SetTransparent()
 			}
 		} else {
 			// Regular pixels.
 			for i := 0; i < chunkSize; i++ {
-				setPixel(img, pal[frame[pos]])
+				fw.SetIndex(frame[pos])
 				pos++
 			}
 		}
 	}
-	return img
-}
-
-// GetPixelSetter returns a function that can be invoced to incrementally set
-// pixels; starting in the lower left corner, going from left to right, and then
-// row by row from the bottom to the top of the image.
-func GetPixelSetter(width, height int) func(dst draw.Image, c color.Color) {
-	var x, y int
-	y = height - 1
-	setPixel := func(dst draw.Image, c color.Color) {
-		dst.Set(x, y, c)
-		if x == width-1 {
-			x = 0
-			y--
-		} else {
-			x++
-		}
-	}
-	return setPixel
+	return fw.Image()
 }
@@ -0,0 +1,54 @@
+package cel
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestGetFrameDecoder(t *testing.T) {
+	// A regular frame of exactly 0x400 bytes; 7 chunks of 127 pixels and one
+	// chunk of 126 pixels, followed by 9 transparent pixels.
+	var regular []byte
+	for i := 0; i < 7; i++ {
+		regular = append(regular, 127)
+		regular = append(regular, bytes.Repeat([]byte{1}, 127)...)
+	}
+	regular = append(regular, 126)
+	regular = append(regular, bytes.Repeat([]byte{1}, 126)...)
+	regular = append(regular, 0xF7)
+	plain := bytes.Repeat([]byte{0}, 0x400)
+	golden := []struct {
+		celName    string
+		frame      []byte
+		frameTypes map[int]int
+		want       FrameDecoder
+	}{
+		{celName: "l1.cel", frame: plain, want: DecodeFrameType0},
+		{celName: "l1.cel", frame: regular, frameTypes: map[int]int{0: 1}, want: DecodeFrameType1},
+		{celName: "l1.cel", frame: plain, frameTypes: map[int]int{0: 0}, want: DecodeFrameType0},
+		{celName: "mod.cel", frame: make([]byte, 0x220), frameTypes: map[int]int{0: 3}, want: DecodeFrameType3},
+		{celName: "mod.cel", frame: plain, frameTypes: map[int]int{1: 1}, want: DecodeFrameType0},
+		{celName: "mod.cel", frame: regular, want: DecodeFrameType1},
+		// Ambiguous frame type.
+		{celName: "l1.cel", frame: regular},
+		// Invalid frame type.
+		{celName: "l1.cel", frame: plain, frameTypes: map[int]int{0: 9}},
+	}
+	for i, g := range golden {
+		got, err := GetFrameDecoder(g.celName, g.frame, 0, g.frameTypes)
+		if g.want == nil {
+			if err == nil {
+				t.Errorf("i=%d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("i=%d: %v", i, err)
+			continue
+		}
+		if reflect.ValueOf(got).Pointer() != reflect.ValueOf(g.want).Pointer() {
+			t.Errorf("i=%d: frame decoder mismatch", i)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package cel
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+)
+
+// maxFrameCount is the maximum number of frames of a CEL image, which guards
+// against allocating the frame offsets of corrupt images.
+const maxFrameCount = 1 << 20
+
+// A Decoder provides random access to the frames of a CEL image, based on the
+// CEL format described above. The frame offsets are read once, and each frame
+// is read and decoded on demand.
+type Decoder struct {
+	// r provides access to the contents of the CEL image.
+	r io.ReaderAt
+	// celName is the name of the CEL image, which is used to locate the frame
+	// decoders of level CEL images.
+	celName string
+	// conf specifies the frame dimensions and palette of the image.
+	conf *Config
+	// frameOffsets contains the offsets to each frame, followed by the end
+	// offset of the last frame.
+	frameOffsets []uint32
+}
+
+// NewDecoder returns a new decoder of the CEL image read from r, based on a
+// given conf. The name of the CEL image (e.g. l1.cel) is used to locate the
+// frame decoders of level CEL images.
+func NewDecoder(r io.ReaderAt, celName string, conf *Config) (d *Decoder, err error) {
+	// Read frame count.
+	var buf [4]byte
+	_, err = r.ReadAt(buf[:], 0)
+	if err != nil {
+		return nil, fmt.Errorf("cel.NewDecoder: unable to read frame count for %q: %v", celName, err)
+	}
+	frameCount := binary.LittleEndian.Uint32(buf[:])
+	if frameCount > maxFrameCount {
+		return nil, fmt.Errorf("cel.NewDecoder: invalid frame count (%d) for %q", frameCount, celName)
+	}
+
+	// Read frame offsets.
+	rawFrameOffsets := make([]byte, 4*(frameCount+1))
+	_, err = r.ReadAt(rawFrameOffsets, 4)
+	if err != nil {
+		return nil, fmt.Errorf("cel.NewDecoder: unable to read frame offsets for %q: %v", celName, err)
+	}
+	frameOffsets := make([]uint32, frameCount+1)
+	for i := range frameOffsets {
+		frameOffsets[i] = binary.LittleEndian.Uint32(rawFrameOffsets[4*i:])
+		if i > 0 && frameOffsets[i] < frameOffsets[i-1] {
+			return nil, fmt.Errorf("cel.NewDecoder: invalid offset of frame %d for %q", i, celName)
+		}
+	}
+	d = &Decoder{
+		r:            r,
+		celName:      celName,
+		conf:         conf,
+		frameOffsets: frameOffsets,
+	}
+	return d, nil
+}
+
+// FrameCount returns the number of frames of the image.
+func (d *Decoder) FrameCount() int {
+	return len(d.frameOffsets) - 1
+}
+
+// FrameBounds returns the bounds of the given frame, or an empty rectangle if
+// the frame doesn't exist.
+func (d *Decoder) FrameBounds(frameNum int) image.Rectangle {
+	if frameNum < 0 || frameNum >= d.FrameCount() {
+		return image.Rectangle{}
+	}
+	width, ok := d.conf.FrameWidth[frameNum]
+	if !ok {
+		// Use default frame width.
+		width = d.conf.Width
+	}
+	height, ok := d.conf.FrameHeight[frameNum]
+	if !ok {
+		// Use default frame height.
+		height = d.conf.Height
+	}
+	return image.Rect(0, 0, width, height)
+}
+
+// Frame returns the content of the given frame, excluding its frame header.
+func (d *Decoder) Frame(frameNum int) (frame []byte, err error) {
+	if frameNum < 0 || frameNum >= d.FrameCount() {
+		return nil, fmt.Errorf("cel.Decoder.Frame: invalid frame number %d of %q (%d frames)", frameNum, d.celName, d.FrameCount())
+	}
+	// Ignore frame header.
+	frameStart := int64(d.frameOffsets[frameNum]) + int64(d.conf.HeaderSize)
+	frameEnd := int64(d.frameOffsets[frameNum+1])
+	if frameStart > frameEnd {
+		return nil, fmt.Errorf("cel.Decoder.Frame: frame %d of %q smaller than its header", frameNum, d.celName)
+	}
+	frame = make([]byte, frameEnd-frameStart)
+	_, err = d.r.ReadAt(frame, frameStart)
+	if err != nil {
+		return nil, fmt.Errorf("cel.Decoder.Frame: unable to read content of frame %d for %q: %v", frameNum, d.celName, err)
+	}
+	return frame, nil
+}
+
+// DecodeFrame reads and decodes the given frame.
+func (d *Decoder) DecodeFrame(frameNum int) (img image.Image, err error) {
+	frame, err := d.Frame(frameNum)
+	if err != nil {
+		return nil, err
+	}
+	bounds := d.FrameBounds(frameNum)
+	decodeFrame, err := GetFrameDecoder(d.celName, frame, frameNum, d.conf.FrameTypes)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFrame(frame, bounds.Dx(), bounds.Dy(), d.conf.Pal), nil
+}
@@ -0,0 +1,397 @@
+package cel
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// Encode writes the frames of imgs to w as a CEL image, based on a given conf.
+// Each frame is encoded as a regular (type 1) frame using the palette of conf,
+// and is preceded by a frame header of conf.HeaderSize bytes.
+func Encode(w io.Writer, imgs []image.Image, conf *Config) (err error) {
+	var frames [][]byte
+	for frameNum, img := range imgs {
+		frame, lineOffsets, err := encodeFrameType1(img, conf.Pal)
+		if err != nil {
+			return fmt.Errorf("cel.Encode: unable to encode frame %d: %v", frameNum, err)
+		}
+		if conf.HeaderSize > 0 {
+			frame = append(EncodeFrameHeader(conf.HeaderSize, lineOffsets), frame...)
+		}
+		frames = append(frames, frame)
+	}
+	return WriteFrames(w, frames)
+}
+
+// EncodeFrameHeader returns a frame header of headerSize bytes, based on the
+// offsets to the start of each line within the frame. The frame header contains
+// headerSize/2 little endian uint16 offsets; the first is the size of the
+// header and the following are the offsets (relative to the start of the
+// header) to every 32nd line of the frame, or 0 if the frame has fewer lines.
+func EncodeFrameHeader(headerSize int, lineOffsets []int) (header []byte) {
+	header = make([]byte, headerSize)
+	for i := 0; 2*i+2 <= headerSize; i++ {
+		var offset int
+		switch {
+		case i == 0:
+			offset = headerSize
+		case 32*i < len(lineOffsets):
+			offset = headerSize + lineOffsets[32*i]
+		}
+		binary.LittleEndian.PutUint16(header[2*i:], uint16(offset))
+	}
+	return header
+}
+
+// EncodeLevel writes the frames of imgs to w as a level CEL image (e.g.
+// l1.cel), using the palette pal, and returns the frame type of each frame.
+// Frames present in frameTypes (ref: min.FrameTypes) are encoded using their
+// frame type, as the frame type of level frames is specified by the MIN blocks
+// that refer to the frame. Other frames are encoded using EncodeLevelFrame,
+// and the returned frame types may be used to update the MIN blocks.
+func EncodeLevel(w io.Writer, imgs []image.Image, pal color.Palette, frameTypes map[int]int) (types []int, err error) {
+	var frames [][]byte
+	for frameNum, img := range imgs {
+		var frame []byte
+		frameType, ok := frameTypes[frameNum]
+		if ok {
+			frame, err = EncodeLevelFrameType(img, pal, frameType)
+		} else {
+			frame, frameType, err = EncodeLevelFrame(img, pal)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cel.EncodeLevel: unable to encode frame %d: %v", frameNum, err)
+		}
+		frames = append(frames, frame)
+		types = append(types, frameType)
+	}
+	err = WriteFrames(w, frames)
+	if err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+// WriteFrames writes the frames to w, based on the CEL format described above.
+// The content of each frame, including its optional header, is written as is.
+func WriteFrames(w io.Writer, frames [][]byte) (err error) {
+	frameOffsets := make([]uint32, len(frames)+1)
+	frameOffsets[0] = uint32(4 + 4*len(frameOffsets))
+	for frameNum, frame := range frames {
+		frameOffsets[frameNum+1] = frameOffsets[frameNum] + uint32(len(frame))
+	}
+	err = binary.Write(w, binary.LittleEndian, uint32(len(frames)))
+	if err != nil {
+		return err
+	}
+	err = binary.Write(w, binary.LittleEndian, frameOffsets)
+	if err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		_, err = w.Write(frame)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A FrameEncoder encodes an image using a palette.
+type FrameEncoder func(img image.Image, pal color.Palette) (frame []byte, err error)
+
+// frameEncoders maps from frame types to frame encoders.
+var frameEncoders = []FrameEncoder{
+	EncodeFrameType0,
+	EncodeFrameType1,
+	EncodeFrameType2,
+	EncodeFrameType3,
+	EncodeFrameType4,
+	EncodeFrameType5,
+}
+
+// EncodeLevelFrame encodes a 32x32 level frame using the most compact frame type
+// which is able to represent the image, and returns the frame type; type 0 for
+// images without transparent pixels, type 2, 3, 4 or 5 for images whose
+// transparent pixels correspond to the triangle and trapezoid layouts of the
+// respective frame type, and type 1 for all other images.
+//
+// An error is returned if the frame type of the encoded frame cannot be
+// identified from its frame size and content (e.g. regular frames of exactly
+// 0x400 bytes), in which case the frame type must be specified by the MIN
+// blocks (ref: EncodeLevelFrameType).
+func EncodeLevelFrame(img image.Image, pal color.Palette) (frame []byte, frameType int, err error) {
+	if err := checkLevelBounds(img); err != nil {
+		return nil, 0, err
+	}
+	for _, t := range []int{0, 2, 3, 4, 5, 1} {
+		frame, err = frameEncoders[t](img, pal)
+		if err == nil {
+			frameType = t
+			break
+		}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if t, err := getLevelFrameType(frame); err != nil || t != frameType {
+		return nil, 0, fmt.Errorf("frame type %d of encoded frame not identifiable; frame type of MIN file required", frameType)
+	}
+	return frame, frameType, nil
+}
+
+// EncodeLevelFrameType encodes a level frame using the given frame type, as
+// specified by the MIN blocks that refer to the frame.
+func EncodeLevelFrameType(img image.Image, pal color.Palette, frameType int) (frame []byte, err error) {
+	if frameType < 0 || frameType >= len(frameEncoders) {
+		return nil, fmt.Errorf("invalid frame type (%d)", frameType)
+	}
+	return frameEncoders[frameType](img, pal)
+}
+
+// EncodeFrameType0 encodes a plain 32x32 image, with no transparency.
+//
+// ref: DecodeFrameType0
+func EncodeFrameType0(img image.Image, pal color.Palette) (frame []byte, err error) {
+	if err := checkLevelBounds(img); err != nil {
+		return nil, err
+	}
+	getPixel := GetPixelGetter(img, pal)
+	for i := 0; i < 32*32; i++ {
+		index, transparent := getPixel()
+		if transparent {
+			return nil, fmt.Errorf("transparent pixel in type 0 frame")
+		}
+		frame = append(frame, index)
+	}
+	return frame, nil
+}
+
+// EncodeFrameType1 encodes a regular image using run-length encoding. Each line
+// is split into runs of regular pixels (at most 127) and transparent pixels (at
+// most 128), and no run spans multiple lines.
+//
+// ref: DecodeFrameType1
+func EncodeFrameType1(img image.Image, pal color.Palette) (frame []byte, err error) {
+	frame, _, err = encodeFrameType1(img, pal)
+	return frame, err
+}
+
+// encodeFrameType1 encodes a regular image using run-length encoding, and
+// returns the offset to the start of each line within the frame.
+func encodeFrameType1(img image.Image, pal color.Palette) (frame []byte, lineOffsets []int, err error) {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	getPixel := GetPixelGetter(img, pal)
+	for y := 0; y < bounds.Dy(); y++ {
+		lineOffsets = append(lineOffsets, len(frame))
+		var run []byte
+		transparentCount := 0
+		flush := func() {
+			for len(run) > 0 {
+				n := len(run)
+				if n > 127 {
+					n = 127
+				}
+				frame = append(frame, byte(n))
+				frame = append(frame, run[:n]...)
+				run = run[n:]
+			}
+			for transparentCount > 0 {
+				n := transparentCount
+				if n > 128 {
+					n = 128
+				}
+				frame = append(frame, byte(int8(-n)))
+				transparentCount -= n
+			}
+		}
+		for x := 0; x < width; x++ {
+			index, transparent := getPixel()
+			if transparent {
+				if len(run) > 0 {
+					flush()
+				}
+				transparentCount++
+				continue
+			}
+			if transparentCount > 0 {
+				flush()
+			}
+			run = append(run, index)
+		}
+		flush()
+	}
+	return frame, lineOffsets, nil
+}
+
+// EncodeFrameType2 encodes a 32x32 image of a left facing triangle.
+//
+// ref: DecodeFrameType2
+func EncodeFrameType2(img image.Image, pal color.Palette) (frame []byte, err error) {
+	decodeCounts := []int{0, 4, 4, 8, 8, 12, 12, 16, 16, 20, 20, 24, 24, 28, 28, 32, 32, 32, 28, 28, 24, 24, 20, 20, 16, 16, 12, 12, 8, 8, 4, 4}
+	zeroCounts := make([]int, len(decodeCounts))
+	for lineNum := range zeroCounts {
+		if lineNum%2 == 1 {
+			zeroCounts[lineNum] = 2
+		}
+	}
+	return encodeLevelLines(img, pal, decodeCounts, zeroCounts, true)
+}
+
+// EncodeFrameType3 encodes a 32x32 image of a right facing triangle.
+//
+// ref: DecodeFrameType3
+func EncodeFrameType3(img image.Image, pal color.Palette) (frame []byte, err error) {
+	decodeCounts := []int{0, 4, 4, 8, 8, 12, 12, 16, 16, 20, 20, 24, 24, 28, 28, 32, 32, 32, 28, 28, 24, 24, 20, 20, 16, 16, 12, 12, 8, 8, 4, 4}
+	zeroCounts := make([]int, len(decodeCounts))
+	for lineNum := range zeroCounts {
+		if lineNum%2 == 1 {
+			zeroCounts[lineNum] = 2
+		}
+	}
+	return encodeLevelLines(img, pal, decodeCounts, zeroCounts, false)
+}
+
+// EncodeFrameType4 encodes a 32x32 image of a left facing trapezoid.
+//
+// ref: DecodeFrameType4
+func EncodeFrameType4(img image.Image, pal color.Palette) (frame []byte, err error) {
+	decodeCounts := []int{4, 4, 8, 8, 12, 12, 16, 16, 20, 20, 24, 24, 28, 28, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32}
+	zeroCounts := make([]int, len(decodeCounts))
+	for lineNum := 0; lineNum < 16; lineNum += 2 {
+		zeroCounts[lineNum] = 2
+	}
+	return encodeLevelLines(img, pal, decodeCounts, zeroCounts, true)
+}
+
+// EncodeFrameType5 encodes a 32x32 image of a right facing trapezoid.
+//
+// ref: DecodeFrameType5
+func EncodeFrameType5(img image.Image, pal color.Palette) (frame []byte, err error) {
+	decodeCounts := []int{4, 4, 8, 8, 12, 12, 16, 16, 20, 20, 24, 24, 28, 28, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32}
+	zeroCounts := make([]int, len(decodeCounts))
+	for lineNum := 0; lineNum < 16; lineNum += 2 {
+		zeroCounts[lineNum] = 2
+	}
+	return encodeLevelLines(img, pal, decodeCounts, zeroCounts, false)
+}
+
+// encodeLevelLines encodes a 32x32 image one line at the time, where
+// decodeCounts and zeroCounts specify the number of explicit pixels and
+// explicit transparent pixels of each line. The explicit pixels are located at
+// the right end of each line if left is true, and at the left end otherwise.
+// The image must be transparent exactly where the layout is transparent.
+//
+// ref: decodeLineTransparencyLeft and decodeLineTransparencyRight
+func encodeLevelLines(img image.Image, pal color.Palette, decodeCounts, zeroCounts []int, left bool) (frame []byte, err error) {
+	if err := checkLevelBounds(img); err != nil {
+		return nil, err
+	}
+	getPixel := GetPixelGetter(img, pal)
+	for lineNum, decodeCount := range decodeCounts {
+		zeroCount := zeroCounts[lineNum]
+		regularCount := decodeCount - zeroCount
+		regularStart := 0
+		if left {
+			regularStart = 32 - regularCount
+		}
+		var regular []byte
+		for x := 0; x < 32; x++ {
+			index, transparent := getPixel()
+			isRegular := x >= regularStart && x < regularStart+regularCount
+			if isRegular == transparent {
+				return nil, fmt.Errorf("pixel (%d, %d) does not match frame layout", x, 31-lineNum)
+			}
+			if isRegular {
+				regular = append(regular, index)
+			}
+		}
+		// Explicit transparent pixels (zeroes) precede the regular pixels of
+		// left facing lines, and follow the regular pixels of right facing
+		// lines.
+		zeroes := make([]byte, zeroCount)
+		if left {
+			frame = append(frame, zeroes...)
+			frame = append(frame, regular...)
+		} else {
+			frame = append(frame, regular...)
+			frame = append(frame, zeroes...)
+		}
+	}
+	return frame, nil
+}
+
+// checkLevelBounds returns an error if the image is not of the 32x32 dimensions
+// of level frames.
+func checkLevelBounds(img image.Image) error {
+	bounds := img.Bounds()
+	if bounds.Dx() != 32 || bounds.Dy() != 32 {
+		return fmt.Errorf("invalid level frame dimensions %dx%d", bounds.Dx(), bounds.Dy())
+	}
+	return nil
+}
+
+// GetPixelGetter returns a function that can be invoked to incrementally get
+// the palette indices of the pixels of img, in the order of FrameWriter;
+// starting in the lower left corner, going from left to right, and then row by
+// row from the bottom to the top of the image. Fully transparent pixels are
+// reported as transparent, and the palette index of other pixels is the index
+// of the closest color of pal; paletted images which use pal (e.g. decoded
+// frames) are encoded using their palette indices as is.
+func GetPixelGetter(img image.Image, pal color.Palette) func() (index uint8, transparent bool) {
+	bounds := img.Bounds()
+	x, y := bounds.Min.X, bounds.Max.Y-1
+	var paletted *image.Paletted
+	switch src := img.(type) {
+	case *image.Paletted:
+		paletted = src
+	case *MaskedPaletted:
+		paletted = src.Paletted
+	}
+	if paletted != nil && !equalPal(paletted.Palette, pal) {
+		paletted = nil
+	}
+	getPixel := func() (index uint8, transparent bool) {
+		c := img.At(x, y)
+		_, _, _, a := c.RGBA()
+		switch {
+		case a == 0:
+			transparent = true
+		case paletted != nil:
+			index = paletted.ColorIndexAt(x, y)
+		default:
+			index = uint8(pal.Index(c))
+		}
+		if x == bounds.Max.X-1 {
+			x = bounds.Min.X
+			y--
+		} else {
+			x++
+		}
+		return index, transparent
+	}
+	return getPixel
+}
+
+// equalPal reports whether the palette a contains the same colors as pal,
+// ignoring the transparent colors of a.
+func equalPal(a, pal color.Palette) bool {
+	if len(a) != len(pal) {
+		return false
+	}
+	for i := range a {
+		r1, g1, b1, a1 := a[i].RGBA()
+		if a1 == 0 {
+			continue
+		}
+		r2, g2, b2, a2 := pal[i].RGBA()
+		if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+			return false
+		}
+	}
+	return true
+}
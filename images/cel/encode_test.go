@@ -0,0 +1,232 @@
+package cel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+	"testing/fstest"
+)
+
+// sameImage reports an error if the images differ; the color of transparent
+// pixels is ignored.
+func sameImage(t *testing.T, want, got image.Image) {
+	t.Helper()
+	if want.Bounds().Size() != got.Bounds().Size() {
+		t.Fatalf("size mismatch; expected %v, got %v", want.Bounds().Size(), got.Bounds().Size())
+	}
+	for y := 0; y < want.Bounds().Dy(); y++ {
+		for x := 0; x < want.Bounds().Dx(); x++ {
+			c1 := color.RGBA64Model.Convert(want.At(want.Bounds().Min.X+x, want.Bounds().Min.Y+y))
+			c2 := color.RGBA64Model.Convert(got.At(got.Bounds().Min.X+x, got.Bounds().Min.Y+y))
+			if c1.(color.RGBA64).A == 0 && c2.(color.RGBA64).A == 0 {
+				continue
+			}
+			if c1 != c2 {
+				t.Fatalf("pixel mismatch at (%d, %d); expected %v, got %v", x, y, c1, c2)
+			}
+		}
+	}
+}
+
+func TestEncodeFrameType1(t *testing.T) {
+	pal := testPal()
+	img := image.NewRGBA(image.Rect(0, 0, 300, 70))
+	for y := 0; y < 70; y++ {
+		for x := 0; x < 300; x++ {
+			if (x/50+y)%3 == 0 {
+				continue
+			}
+			img.Set(x, y, pal[(x*y)%255])
+		}
+	}
+	frame, err := EncodeFrameType1(img, pal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameImage(t, img, DecodeFrameType1(frame, 300, 70, pal))
+}
+
+// levelImage returns a 32x32 image whose transparent pixels correspond to the
+// layout of the given frame type.
+func levelImage(frameType int, pal color.Palette) image.Image {
+	// Decode a frame of the given frame type, which only contains palette
+	// index 1, to locate the transparent pixels.
+	sizes := []int{0x400, 0, 0x220, 0x220, 0x320, 0x320}
+	raw := bytes.Repeat([]byte{1}, sizes[frameType])
+	var zeros []int
+	switch frameType {
+	case 2, 4:
+		zeros = []int{0, 1, 8, 9, 24, 25, 48, 49, 80, 81, 120, 121, 168, 169, 224, 225}
+	case 3, 5:
+		zeros = []int{2, 3, 14, 15, 34, 35, 62, 63, 98, 99, 142, 143, 194, 195, 254, 255}
+	}
+	for _, i := range zeros {
+		raw[i] = 0
+	}
+	layout := frameDecoders[frameType](raw, levelFrameWidth, levelFrameHeight, pal)
+	img := image.NewRGBA(image.Rect(0, 0, levelFrameWidth, levelFrameHeight))
+	for y := 0; y < levelFrameHeight; y++ {
+		for x := 0; x < levelFrameWidth; x++ {
+			if _, _, _, a := layout.At(x, y).RGBA(); a != 0 {
+				img.Set(x, y, pal[(x+3*y)%200+5])
+			}
+		}
+	}
+	return img
+}
+
+func TestEncodeLevelFrame(t *testing.T) {
+	pal := testPal()
+	sizes := []int{0x400, 0, 0x220, 0x220, 0x320, 0x320}
+	for _, frameType := range []int{0, 2, 3, 4, 5} {
+		img := levelImage(frameType, pal)
+		frame, gotType, err := EncodeLevelFrame(img, pal)
+		if err != nil {
+			t.Errorf("frame type %d: %v", frameType, err)
+			continue
+		}
+		if gotType != frameType {
+			t.Errorf("frame type mismatch; expected %d, got %d", frameType, gotType)
+			continue
+		}
+		if len(frame) != sizes[frameType] {
+			t.Errorf("frame type %d: frame size mismatch; expected 0x%X, got 0x%X", frameType, sizes[frameType], len(frame))
+			continue
+		}
+		sameImage(t, img, frameDecoders[frameType](frame, levelFrameWidth, levelFrameHeight, pal))
+	}
+
+	// Regular frames.
+	img := levelImage(2, pal)
+	img.(*image.RGBA).Set(16, 16, color.RGBA{})
+	frame, frameType, err := EncodeLevelFrame(img, pal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frameType != 1 {
+		t.Fatalf("frame type mismatch; expected 1, got %d", frameType)
+	}
+	sameImage(t, img, DecodeFrameType1(frame, levelFrameWidth, levelFrameHeight, pal))
+}
+
+func TestEncodeLevelFrameAmbiguous(t *testing.T) {
+	// A regular frame whose encoding is exactly 0x400 bytes; 31 opaque lines of
+	// 33 bytes each, and a transparent top line of 1 byte.
+	pal := testPal()
+	img := image.NewRGBA(image.Rect(0, 0, levelFrameWidth, levelFrameHeight))
+	for y := 1; y < levelFrameHeight; y++ {
+		for x := 0; x < levelFrameWidth; x++ {
+			img.Set(x, y, pal[1])
+		}
+	}
+	if _, _, err := EncodeLevelFrame(img, pal); err == nil {
+		t.Fatal("expected error for ambiguous frame type")
+	}
+	frame, err := EncodeLevelFrameType(img, pal, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frame) != 0x400 {
+		t.Fatalf("frame size mismatch; expected 0x400, got 0x%X", len(frame))
+	}
+	sameImage(t, img, DecodeFrameType1(frame, levelFrameWidth, levelFrameHeight, pal))
+}
+
+func TestEncodeLevel(t *testing.T) {
+	pal := testPal()
+	imgs := []image.Image{levelImage(0, pal), levelImage(3, pal), levelImage(4, pal)}
+	// The MIN blocks specify a regular frame type for the last frame.
+	frameTypes := map[int]int{2: 1}
+	var buf bytes.Buffer
+	types, err := EncodeLevel(&buf, imgs, pal, frameTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{0, 3, 1}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("frame types mismatch; expected %v, got %v", want, types)
+		}
+	}
+	fsys := fstest.MapFS{"l1.cel": {Data: buf.Bytes()}}
+	conf := &Config{Width: levelFrameWidth, Height: levelFrameHeight, Pal: pal, FrameTypes: map[int]int{0: 0, 1: 3, 2: 1}}
+	got, err := DecodeAll(fsys, "l1.cel", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range imgs {
+		sameImage(t, imgs[i], got[i])
+	}
+}
+
+func TestEncodeMaskedPaletted(t *testing.T) {
+	// Frames which use every palette index are decoded as *MaskedPaletted and
+	// must be encoded losslessly.
+	pal := testPal()
+	fw := NewFrameWriter(300, 1, pal)
+	for i := 0; i < 256; i++ {
+		fw.SetIndex(uint8(i))
+	}
+	for i := 256; i < 300; i++ {
+		fw.SetTransparent()
+	}
+	img := fw.Image()
+	if _, ok := img.(*MaskedPaletted); !ok {
+		t.Fatalf("image type mismatch; expected *MaskedPaletted, got %T", img)
+	}
+	frame, err := EncodeFrameType1(img, pal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := DecodeFrameType1(frame, 300, 1, pal)
+	sameImage(t, img, got)
+	for i := 256; i < 300; i++ {
+		if _, _, _, a := got.At(i, 0).RGBA(); a != 0 {
+			t.Fatalf("pixel at x=%d is opaque", i)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	pal := testPal()
+	var imgs []image.Image
+	for i := 0; i < 3; i++ {
+		img := image.NewPaletted(image.Rect(0, 0, 20, 70+i), pal)
+		for y := 0; y < 70+i; y++ {
+			for x := 5; x < 20; x++ {
+				img.SetColorIndex(x, y, uint8(x+y+i))
+			}
+		}
+		imgs = append(imgs, img)
+	}
+	var buf bytes.Buffer
+	conf := &Config{Width: 20, Height: 70, FrameHeight: map[int]int{1: 71, 2: 72}, Pal: pal, HeaderSize: 10}
+	if err := Encode(&buf, imgs, conf); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"angel.cel": {Data: buf.Bytes()}}
+	got, err := DecodeAll(fsys, "angel.cel", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(imgs) {
+		t.Fatalf("frame count mismatch; expected %d, got %d", len(imgs), len(got))
+	}
+	for i := range got {
+		sameImage(t, imgs[i], got[i])
+	}
+
+	// The frame header contains the header size, followed by the offsets to
+	// line 32 and 64; each line contains 21 bytes (palette index 0 is opaque).
+	data := buf.Bytes()
+	start := binary.LittleEndian.Uint32(data[4:])
+	header := data[start : start+10]
+	want := []uint16{10, 10 + 32*21, 10 + 64*21, 0, 0}
+	for i := range want {
+		if got := binary.LittleEndian.Uint16(header[2*i:]); got != want[i] {
+			t.Fatalf("frame header mismatch; expected %v, got % X", want, header)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package cel
 import (
 	"image"
 	"image/color"
-	"image/draw"
 )
 
 // DecodeFrameType0 returns an image after decoding the frame in the following
@@ -16,12 +15,11 @@ import (
 //
 // Type0 corresponds to a plain 32x32 images, with no transparency.
 func DecodeFrameType0(frame []byte, width int, height int, pal color.Palette) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
-	setPixel := GetPixelSetter(width, height)
+	fw := NewFrameWriter(width, height, pal)
 	for _, b := range frame {
-		setPixel(img, pal[b])
+		fw.SetIndex(b)
 	}
-	return img
+	return fw.Image()
 }
 
 // DecodeFrameType2 returns an image after decoding the frame in the following
@@ -76,8 +74,7 @@ func DecodeFrameType0(frame []byte, width int, height int, pal color.Palette) im
 //
 // Type2 corresponds to a 32x32 images of a left facing triangle.
 func DecodeFrameType2(frame []byte, width int, height int, pal color.Palette) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
-	setPixel := GetPixelSetter(width, height)
+	fw := NewFrameWriter(width, height, pal)
 	decodeCounts := []int{0, 4, 4, 8, 8, 12, 12, 16, 16, 20, 20, 24, 24, 28, 28, 32, 32, 32, 28, 28, 24, 24, 20, 20, 16, 16, 12, 12, 8, 8, 4, 4}
 	for lineNum, decodeCount := range decodeCounts {
 		zeroCount := 0
@@ -85,10 +82,10 @@ func DecodeFrameType2(frame []byte, width int, height int, pal color.Palette) im
 			zeroCount = 2
 		}
 		regularCount := decodeCount - zeroCount
-		decodeLineTransparencyLeft(img, setPixel, frame, regularCount, zeroCount, pal)
+		decodeLineTransparencyLeft(fw, frame, regularCount, zeroCount)
 		frame = frame[decodeCount:]
 	}
-	return img
+	return fw.Image()
 }
 
 // DecodeFrameType3 returns an image after decoding the frame in the following
@@ -143,8 +140,7 @@ func DecodeFrameType2(frame []byte, width int, height int, pal color.Palette) im
 //
 // Type3 corresponds to a 32x32 images of a right facing triangle.
 func DecodeFrameType3(frame []byte, width int, height int, pal color.Palette) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
-	setPixel := GetPixelSetter(width, height)
+	fw := NewFrameWriter(width, height, pal)
 	decodeCounts := []int{0, 4, 4, 8, 8, 12, 12, 16, 16, 20, 20, 24, 24, 28, 28, 32, 32, 32, 28, 28, 24, 24, 20, 20, 16, 16, 12, 12, 8, 8, 4, 4}
 	for lineNum, decodeCount := range decodeCounts {
 		zeroCount := 0
@@ -152,10 +148,10 @@ func DecodeFrameType3(frame []byte, width int, height int, pal color.Palette) im
 			zeroCount = 2
 		}
 		regularCount := decodeCount - zeroCount
-		decodeLineTransparencyRight(img, setPixel, frame, regularCount, zeroCount, pal)
+		decodeLineTransparencyRight(fw, frame, regularCount, zeroCount)
 		frame = frame[decodeCount:]
 	}
-	return img
+	return fw.Image()
 }
 
 // DecodeFrameType4 returns an image after decoding the frame in the following
@@ -210,8 +206,7 @@ func DecodeFrameType3(frame []byte, width int, height int, pal color.Palette) im
 //
 // Type4 corresponds to a 32x32 images of a left facing trapezoid.
 func DecodeFrameType4(frame []byte, width int, height int, pal color.Palette) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
-	setPixel := GetPixelSetter(width, height)
+	fw := NewFrameWriter(width, height, pal)
 	decodeCounts := []int{4, 4, 8, 8, 12, 12, 16, 16, 20, 20, 24, 24, 28, 28, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32}
 	for lineNum, decodeCount := range decodeCounts {
 		zeroCount := 0
@@ -220,10 +215,10 @@ func DecodeFrameType4(frame []byte, width int, height int, pal color.Palette) im
 			zeroCount = 2
 		}
 		regularCount := decodeCount - zeroCount
-		decodeLineTransparencyLeft(img, setPixel, frame, regularCount, zeroCount, pal)
+		decodeLineTransparencyLeft(fw, frame, regularCount, zeroCount)
 		frame = frame[decodeCount:]
 	}
-	return img
+	return fw.Image()
 }
 
 // DecodeFrameType5 returns an image after decoding the frame in the following
@@ -278,8 +273,7 @@ func DecodeFrameType4(frame []byte, width int, height int, pal color.Palette) im
 //
 // Type5 corresponds to a 32x32 images of a right facing trapezoid.
 func DecodeFrameType5(frame []byte, width int, height int, pal color.Palette) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
-	setPixel := GetPixelSetter(width, height)
+	fw := NewFrameWriter(width, height, pal)
 	decodeCounts := []int{4, 4, 8, 8, 12, 12, 16, 16, 20, 20, 24, 24, 28, 28, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32}
 	for lineNum, decodeCount := range decodeCounts {
 		zeroCount := 0
@@ -288,31 +282,31 @@ func DecodeFrameType5(frame []byte, width int, height int, pal color.Palette) im
 			zeroCount = 2
 		}
 		regularCount := decodeCount - zeroCount
-		decodeLineTransparencyRight(img, setPixel, frame, regularCount, zeroCount, pal)
+		decodeLineTransparencyRight(fw, frame, regularCount, zeroCount)
 		frame = frame[decodeCount:]
 	}
-	return img
+	return fw.Image()
 }
 
 // decodeLineTransparencyLeft decodes a line of the frame, where regularCount
 // represent the number of explicit regular pixels, zeroCount the number of
 // explicit transparent pixels and the rest of the line is implicitly
 // transparent. Each line is assumed to have a width of 32 pixels.
-func decodeLineTransparencyLeft(dst draw.Image, setPixel func(dst draw.Image, c color.Color), frame []byte, regularCount, zeroCount int, pal color.Palette) {
+func decodeLineTransparencyLeft(fw *FrameWriter, frame []byte, regularCount, zeroCount int) {
 	// Total number of explicit pixels.
 	decodeCount := zeroCount + regularCount
 
 	// Implicit transparent pixels.
 	for i := decodeCount; i < 32; i++ {
-		setPixel(dst, color.Transparent)
+		fw.SetTransparent()
 	}
 	// Explicit transparent pixels (zeroes).
 	for i := 0; i < zeroCount; i++ {
-		setPixel(dst, color.Transparent)
+		fw.SetTransparent()
 	}
 	// Explicit regular pixels.
 	for i := zeroCount; i < decodeCount; i++ {
-		setPixel(dst, pal[frame[i]])
+		fw.SetIndex(frame[i])
 	}
 }
 
@@ -320,22 +314,22 @@ func decodeLineTransparencyLeft(dst draw.Image, setPixel func(dst draw.Image, c
 // represent the number of explicit regular pixels, zeroCount the number of
 // explicit transparent pixels and the rest of the line is implicitly
 // transparent. Each line is assumed to have a width of 32 pixels.
-func decodeLineTransparencyRight(dst draw.Image, setPixel func(dst draw.Image, c color.Color), frame []byte, regularCount, zeroCount int, pal color.Palette) {
+func decodeLineTransparencyRight(fw *FrameWriter, frame []byte, regularCount, zeroCount int) {
 	// Total number of explicit pixels.
 	decodeCount := zeroCount + regularCount
 
 	// Explicit regular pixels.
 	for i := 0; i < regularCount; i++ {
-		setPixel(dst, pal[frame[i]])
+		fw.SetIndex(frame[i])
 	}
 
 	// Explicit transparent pixels (zeroes).
 	for i := 0; i < zeroCount; i++ {
-		setPixel(dst, color.Transparent)
+		fw.SetTransparent()
 	}
 
 	// Implicit transparent pixels.
 	for i := decodeCount; i < 32; i++ {
-		setPixel(dst, color.Transparent)
+		fw.SetTransparent()
 	}
 }
@@ -1,11 +1,14 @@
 package cel
 
 import (
+	"bytes"
 	"fmt"
 	"image/color"
-	"io/ioutil"
+	"image/gif"
+	"io/fs"
+	"path"
 
-	"github.com/mewrnd/blizzconv/mpq"
+	"github.com/mewrnd/blizzconv/images/pcx"
 )
 
 // GetPal parses the provided PAL file and returns it as a color.Palette. Below
@@ -19,13 +22,27 @@ import (
 //    g byte   // green
 //    b byte   // blue
 //
-// Note: The absolute path of relPalPath is relative to mpq.ExtractPath.
-func GetPal(relPalPath string) (pal color.Palette, err error) {
-	palPath := mpq.AbsPath(relPalPath)
-	buf, err := ioutil.ReadFile(palPath)
+// The PAL file is located at relPalPath within fsys. The embedded palette of
+// PCX and GIF images is used if relPalPath has the ".pcx" or ".gif" extension.
+func GetPal(fsys fs.FS, relPalPath string) (pal color.Palette, err error) {
+	buf, err := fs.ReadFile(fsys, relPalPath)
 	if err != nil {
 		return nil, err
 	}
+	if path.Ext(relPalPath) == ".pcx" {
+		pal, err = pcx.DecodePal(bytes.NewReader(buf))
+		if err != nil {
+			return nil, fmt.Errorf("cel.GetPal: unable to decode palette of %q: %v", relPalPath, err)
+		}
+		return pal, nil
+	}
+	if path.Ext(relPalPath) == ".gif" {
+		pal, err = getGIFPal(buf)
+		if err != nil {
+			return nil, fmt.Errorf("cel.GetPal: unable to decode palette of %q: %v", relPalPath, err)
+		}
+		return pal, nil
+	}
 	if len(buf) != 256*3 {
 		return nil, fmt.Errorf("cel.GetPal: invalid pal size (%d) for %q", len(buf), relPalPath)
 	}
@@ -41,3 +58,30 @@ func GetPal(relPalPath string) (pal color.Palette, err error) {
 	}
 	return pal, nil
 }
+
+// getGIFPal returns the palette of the GIF image contained within buf; the
+// global color table, or the local color table of the first frame. Palettes of
+// less than 256 colors are padded with black.
+func getGIFPal(buf []byte) (pal color.Palette, err error) {
+	anim, err := gif.DecodeAll(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	src, ok := anim.Config.ColorModel.(color.Palette)
+	if !ok || len(src) == 0 {
+		if len(anim.Image) == 0 {
+			return nil, fmt.Errorf("no palette present")
+		}
+		src = anim.Image[0].Palette
+	}
+	pal = make(color.Palette, 256)
+	for i := range pal {
+		pal[i] = color.RGBA{A: 0xFF}
+		if i < len(src) {
+			// Transparency is ignored.
+			r, g, b, _ := src[i].RGBA()
+			pal[i] = color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 0xFF}
+		}
+	}
+	return pal, nil
+}
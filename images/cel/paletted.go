@@ -0,0 +1,212 @@
+package cel
+
+import (
+	"image"
+	"image/color"
+)
+
+// A FrameWriter incrementally sets the pixels of a paletted frame image;
+// starting in the lower left corner, going from left to right, and then row by
+// row from the bottom to the top of the image. Pixels which are never set are
+// transparent.
+type FrameWriter struct {
+	// img is the frame image.
+	img *image.Paletted
+	// pal is the palette of the frame.
+	pal color.Palette
+	// opaque reports whether each pixel of img has been set to a palette index.
+	opaque []bool
+	// x and y specify the location of the next pixel.
+	x, y int
+}
+
+// NewFrameWriter returns a new frame writer of a frame with the given
+// dimensions and palette. Palettes of less than 256 colors are padded with
+// black.
+func NewFrameWriter(width, height int, pal color.Palette) *FrameWriter {
+	if len(pal) < 256 {
+		padded := make(color.Palette, 256)
+		for i := range padded {
+			padded[i] = color.RGBA{A: 0xFF}
+		}
+		copy(padded, pal)
+		pal = padded
+	}
+	fw := &FrameWriter{
+		img:    image.NewPaletted(image.Rect(0, 0, width, height), pal),
+		pal:    pal,
+		opaque: make([]bool, width*height),
+		y:      height - 1,
+	}
+	return fw
+}
+
+// SetIndex sets the next pixel to the given palette index.
+func (fw *FrameWriter) SetIndex(index uint8) {
+	if fw.y >= 0 {
+		i := fw.img.PixOffset(fw.x, fw.y)
+		fw.img.Pix[i] = index
+		fw.opaque[i] = true
+	}
+	fw.next()
+}
+
+// SetTransparent sets the next pixel to transparent.
+func (fw *FrameWriter) SetTransparent() {
+	fw.next()
+}
+
+// next advances the location of the next pixel.
+func (fw *FrameWriter) next() {
+	if fw.x == fw.img.Rect.Dx()-1 {
+		fw.x = 0
+		fw.y--
+	} else {
+		fw.x++
+	}
+}
+
+// Image returns the frame image. The transparent pixels of the frame use a
+// reserved palette index, which is the last palette index not used by the
+// frame, and whose color is transparent. Should the frame use every palette
+// index, no palette index is reserved and the frame is returned as a
+// MaskedPaletted image instead.
+func (fw *FrameWriter) Image() image.Image {
+	var used [256]bool
+	transparent := false
+	for i, opaque := range fw.opaque {
+		if opaque {
+			used[fw.img.Pix[i]] = true
+		} else {
+			transparent = true
+		}
+	}
+	if !transparent {
+		return fw.img
+	}
+	reserved := -1
+	for index := 255; index >= 0; index-- {
+		if !used[index] {
+			reserved = index
+			break
+		}
+	}
+	if reserved == -1 {
+		return &MaskedPaletted{Paletted: fw.img, Mask: fw.opaque}
+	}
+	pal := make(color.Palette, len(fw.pal))
+	copy(pal, fw.pal)
+	pal[reserved] = color.RGBA{}
+	fw.img.Palette = pal
+	for i, opaque := range fw.opaque {
+		if !opaque {
+			fw.img.Pix[i] = uint8(reserved)
+		}
+	}
+	return fw.img
+}
+
+// A MaskedPaletted is a paletted frame image whose transparent pixels are
+// specified by a mask, as the frame uses every palette index and no palette
+// index is left to be reserved for transparent pixels.
+type MaskedPaletted struct {
+	*image.Paletted
+	// Mask reports whether each pixel is opaque, and is indexed by PixOffset.
+	Mask []bool
+}
+
+// At returns the color of the pixel at (x, y).
+func (p *MaskedPaletted) At(x, y int) color.Color {
+	if !p.IsOpaqueAt(x, y) {
+		return color.RGBA{}
+	}
+	return p.Paletted.At(x, y)
+}
+
+// RGBA64At returns the color of the pixel at (x, y).
+func (p *MaskedPaletted) RGBA64At(x, y int) color.RGBA64 {
+	if !p.IsOpaqueAt(x, y) {
+		return color.RGBA64{}
+	}
+	return p.Paletted.RGBA64At(x, y)
+}
+
+// IsOpaqueAt reports whether the pixel at (x, y) is opaque.
+func (p *MaskedPaletted) IsOpaqueAt(x, y int) bool {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return false
+	}
+	return p.Mask[p.PixOffset(x, y)]
+}
+
+// Opaque reports whether every pixel of the image is opaque.
+func (p *MaskedPaletted) Opaque() bool {
+	for y := p.Rect.Min.Y; y < p.Rect.Max.Y; y++ {
+		for x := p.Rect.Min.X; x < p.Rect.Max.X; x++ {
+			if !p.IsOpaqueAt(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares pixels with the original image.
+func (p *MaskedPaletted) SubImage(r image.Rectangle) image.Image {
+	sub := p.Paletted.SubImage(r).(*image.Paletted)
+	// The pixels of sub start at the offset of r.Min within the pixels of p.
+	return &MaskedPaletted{Paletted: sub, Mask: p.Mask[len(p.Pix)-len(sub.Pix):]}
+}
+
+// Recolor returns a copy of the paletted image img which uses the palette pal
+// instead; e.g. another level palette or a palette converted by a TRN file. The
+// pixels of img are shared by the copy, and the transparent palette indices and
+// the mask (see MaskedPaletted) of img remain transparent. Palettes of less
+// than 256 colors are padded with black. Images which aren't paletted are
+// returned as is.
+func Recolor(img image.Image, pal color.Palette) image.Image {
+	switch src := img.(type) {
+	case *image.Paletted:
+		return recolor(src, pal)
+	case *MaskedPaletted:
+		return &MaskedPaletted{Paletted: recolor(src.Paletted, pal), Mask: src.Mask}
+	}
+	return img
+}
+
+// recolor returns a copy of the paletted image src which uses the palette pal
+// instead. Palettes of less than 256 colors are padded with black.
+func recolor(src *image.Paletted, pal color.Palette) *image.Paletted {
+	dst := *src
+	n := len(pal)
+	if n < 256 {
+		n = 256
+	}
+	dst.Palette = make(color.Palette, n)
+	for i := len(pal); i < n; i++ {
+		dst.Palette[i] = color.RGBA{A: 0xFF}
+	}
+	copy(dst.Palette, pal)
+	for index, c := range src.Palette {
+		if index >= len(dst.Palette) {
+			break
+		}
+		if _, _, _, a := c.RGBA(); a == 0 {
+			dst.Palette[index] = color.RGBA{}
+		}
+	}
+	return &dst
+}
+
+// RecolorAll returns copies of the paletted images imgs which use the palette
+// pal instead.
+//
+// ref: Recolor
+func RecolorAll(imgs []image.Image, pal color.Palette) []image.Image {
+	dst := make([]image.Image, len(imgs))
+	for i, img := range imgs {
+		dst[i] = Recolor(img, pal)
+	}
+	return dst
+}
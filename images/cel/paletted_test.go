@@ -0,0 +1,126 @@
+package cel
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testPal returns an opaque palette of 256 distinct colors.
+func testPal() color.Palette {
+	pal := make(color.Palette, 256)
+	for i := range pal {
+		pal[i] = color.RGBA{R: uint8(i), G: uint8(255 - i), B: uint8(i * 7), A: 0xFF}
+	}
+	return pal
+}
+
+func TestFrameWriter(t *testing.T) {
+	pal := testPal()
+	fw := NewFrameWriter(3, 2, pal)
+	fw.SetIndex(7)
+	fw.SetTransparent()
+	fw.SetIndex(255)
+	// The last pixel of the top row is never set.
+	fw.SetIndex(9)
+	img, ok := fw.Image().(*image.Paletted)
+	if !ok {
+		t.Fatalf("image type mismatch; expected *image.Paletted, got %T", fw.Image())
+	}
+	// The bottom row is located at y=1.
+	if img.ColorIndexAt(0, 1) != 7 || img.ColorIndexAt(2, 1) != 255 || img.ColorIndexAt(0, 0) != 9 {
+		t.Fatal("palette indices mismatch")
+	}
+	// The last unused palette index is reserved for transparent pixels.
+	reserved := img.ColorIndexAt(1, 1)
+	if reserved != 254 || img.ColorIndexAt(1, 0) != reserved || img.ColorIndexAt(2, 0) != reserved {
+		t.Fatalf("reserved palette index mismatch; expected 254, got %d", reserved)
+	}
+	if _, _, _, a := img.At(1, 1).RGBA(); a != 0 {
+		t.Fatal("transparent pixel is opaque")
+	}
+}
+
+func TestFrameWriterEveryIndex(t *testing.T) {
+	pal := testPal()
+	fw := NewFrameWriter(257, 1, pal)
+	for i := 0; i < 256; i++ {
+		fw.SetIndex(uint8(i))
+	}
+	fw.SetTransparent()
+	img, ok := fw.Image().(*MaskedPaletted)
+	if !ok {
+		t.Fatalf("image type mismatch; expected *MaskedPaletted, got %T", fw.Image())
+	}
+	for i := 0; i < 256; i++ {
+		if img.ColorIndexAt(i, 0) != uint8(i) {
+			t.Fatalf("palette index mismatch at x=%d; expected %d, got %d", i, i, img.ColorIndexAt(i, 0))
+		}
+		if img.At(i, 0) != pal[i] {
+			t.Fatalf("color mismatch at x=%d", i)
+		}
+	}
+	if _, _, _, a := img.At(256, 0).RGBA(); a != 0 {
+		t.Fatal("transparent pixel is opaque")
+	}
+	sub := img.SubImage(image.Rect(200, 0, 257, 1))
+	if sub.At(220, 0) != pal[220] {
+		t.Fatal("sub-image color mismatch")
+	}
+	if _, _, _, a := sub.At(256, 0).RGBA(); a != 0 {
+		t.Fatal("sub-image transparent pixel is opaque")
+	}
+}
+
+func TestRecolor(t *testing.T) {
+	pal := testPal()
+	pal2 := make(color.Palette, 256)
+	for i := range pal2 {
+		pal2[i] = color.RGBA{B: uint8(i), A: 0xFF}
+	}
+	fw := NewFrameWriter(2, 1, pal)
+	fw.SetIndex(7)
+	fw.SetTransparent()
+	img := fw.Image()
+	dst := Recolor(img, pal2)
+	if dst.At(0, 0) != pal2[7] {
+		t.Fatal("recolored color mismatch")
+	}
+	if _, _, _, a := dst.At(1, 0).RGBA(); a != 0 {
+		t.Fatal("recolored transparent pixel is opaque")
+	}
+	if img.At(0, 0) != pal[7] {
+		t.Fatal("source image modified")
+	}
+
+	// Frames which use every palette index keep their mask.
+	fw = NewFrameWriter(257, 1, pal)
+	for i := 0; i < 256; i++ {
+		fw.SetIndex(uint8(i))
+	}
+	fw.SetTransparent()
+	dst = Recolor(fw.Image(), pal2)
+	if dst.At(220, 0) != pal2[220] {
+		t.Fatal("recolored color mismatch")
+	}
+	if _, _, _, a := dst.At(256, 0).RGBA(); a != 0 {
+		t.Fatal("recolored transparent pixel is opaque")
+	}
+}
+
+func TestRecolorShortPalette(t *testing.T) {
+	fw := NewFrameWriter(2, 1, testPal())
+	fw.SetIndex(200)
+	fw.SetTransparent()
+	dst := Recolor(fw.Image(), color.Palette{color.RGBA{R: 0xFF, A: 0xFF}})
+	p := dst.(*image.Paletted)
+	if len(p.Palette) != 256 {
+		t.Fatalf("palette length mismatch; expected 256, got %d", len(p.Palette))
+	}
+	if dst.At(0, 0) != (color.RGBA{A: 0xFF}) {
+		t.Fatalf("padded color mismatch; got %v", dst.At(0, 0))
+	}
+	if _, _, _, a := dst.At(1, 0).RGBA(); a != 0 {
+		t.Fatal("recolored transparent pixel is opaque")
+	}
+}
@@ -1,4 +1,4 @@
-// Package cl2 implements a CL2 image decoder.
+// Package cl2 implements a CL2 image decoder and encoder.
 //
 // The CL2 format is the second version of the CEL format. It uses run-length
 // encoding to decrease the size of images. Other than this addition, the format
@@ -7,40 +7,39 @@ package cl2
 
 import (
 	"image"
+	"io/fs"
 	"path"
 
 	"github.com/mewrnd/blizzconv/images/cel"
+	"github.com/mewrnd/blizzconv/mpq"
 )
 
 // DecodeAll returns the sequential frames of a CEL or CL2 image based on a
-// given conf.
-func DecodeAll(imgName string, conf *cel.Config) (imgs []image.Image, err error) {
+// given conf. The image is located at relImgPath within fsys. The frames are
+// paletted images, as described by cel.DecodeAll.
+func DecodeAll(fsys fs.FS, relImgPath string, conf *cel.Config) (imgs []image.Image, err error) {
 	// Decode CEL version 1 images using the cel package.
-	if path.Ext(imgName) == ".cel" {
-		return cel.DecodeAll(imgName, conf)
+	if path.Ext(relImgPath) == ".cel" {
+		return cel.DecodeAll(fsys, relImgPath, conf)
 	}
 
-	// Get frame contents.
-	frames, err := cel.GetFrames(imgName)
+	// Open CL2 file.
+	f, err := mpq.OpenFile(fsys, relImgPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d, err := NewDecoder(f, path.Base(relImgPath), conf)
 	if err != nil {
 		return nil, err
 	}
 
 	// Decode frames.
-	for frameNum, frame := range frames {
-		width, ok := conf.FrameWidth[frameNum]
-		if !ok {
-			// Use default frame width.
-			width = conf.Width
+	for frameNum := 0; frameNum < d.FrameCount(); frameNum++ {
+		img, err := d.DecodeFrame(frameNum)
+		if err != nil {
+			return nil, err
 		}
-		height, ok := conf.FrameHeight[frameNum]
-		if !ok {
-			// Use default frame height.
-			height = conf.Height
-		}
-
-		// Decode frame.
-		img := DecodeFrameType6(frame, width, height, conf.Pal)
 		imgs = append(imgs, img)
 	}
 
@@ -0,0 +1,42 @@
+package cl2
+
+import (
+	"image"
+	"io"
+
+	"github.com/mewrnd/blizzconv/images/cel"
+)
+
+// A Decoder provides random access to the frames of a CL2 image. The CL2 format
+// shares the frame offset table of the CEL format, so frames are located as
+// described by cel.Decoder and decoded using DecodeFrameType6.
+type Decoder struct {
+	*cel.Decoder
+	// conf specifies the frame dimensions and palette of the image.
+	conf *cel.Config
+}
+
+// NewDecoder returns a new decoder of the CL2 image read from r, based on a
+// given conf. The name of the CL2 image (e.g. warrior.cl2) is used in error
+// messages.
+func NewDecoder(r io.ReaderAt, cl2Name string, conf *cel.Config) (d *Decoder, err error) {
+	celDecoder, err := cel.NewDecoder(r, cl2Name, conf)
+	if err != nil {
+		return nil, err
+	}
+	d = &Decoder{
+		Decoder: celDecoder,
+		conf:    conf,
+	}
+	return d, nil
+}
+
+// DecodeFrame reads and decodes the given frame.
+func (d *Decoder) DecodeFrame(frameNum int) (img image.Image, err error) {
+	frame, err := d.Frame(frameNum)
+	if err != nil {
+		return nil, err
+	}
+	bounds := d.FrameBounds(frameNum)
+	return DecodeFrameType6(frame, bounds.Dx(), bounds.Dy(), d.conf.Pal), nil
+}
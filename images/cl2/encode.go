@@ -0,0 +1,133 @@
+package cl2
+
+import (
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/mewrnd/blizzconv/images/cel"
+)
+
+// Encode writes the frames of imgs to w as a CL2 image, based on a given conf.
+// Each frame is encoded as a type 6 frame using the palette of conf, and is
+// preceded by a frame header of conf.HeaderSize bytes (10 for the CL2 images of
+// the game), which contains the offsets to every 32nd line of the frame.
+//
+// ref: cel.EncodeFrameHeader
+func Encode(w io.Writer, imgs []image.Image, conf *cel.Config) (err error) {
+	var frames [][]byte
+	for _, img := range imgs {
+		frame, lineOffsets := encodeFrameType6(img, conf.Pal)
+		if conf.HeaderSize > 0 {
+			frame = append(cel.EncodeFrameHeader(conf.HeaderSize, lineOffsets), frame...)
+		}
+		frames = append(frames, frame)
+	}
+	return cel.WriteFrames(w, frames)
+}
+
+// The maximum number of pixels of each chunk kind. The chunk size is stored as
+// an int8, which is negated by the game for regular pixels; -128 is therefore
+// avoided.
+const (
+	maxTransparent = 127
+	maxRegular     = 65
+	maxFill        = 127 - 65
+)
+
+// EncodeFrameType6 encodes an image using the run-length encoding of CL2 images.
+// Runs of regular pixels never span multiple lines, as required by the game.
+// Runs of transparent pixels may span multiple lines, but never span every
+// 32nd line, which is referenced by the frame header. The regular pixels of
+// each line are split into chunks of regular pixels and chunks of run-length
+// encoded pixels so that the size of the line is minimal.
+//
+// ref: DecodeFrameType6
+func EncodeFrameType6(img image.Image, pal color.Palette) (frame []byte) {
+	frame, _ = encodeFrameType6(img, pal)
+	return frame
+}
+
+// encodeFrameType6 encodes an image using the run-length encoding of CL2
+// images, and returns the offset to the start of each line within the frame.
+// The offsets of lines which start within a run of transparent pixels refer to
+// the start of the run.
+func encodeFrameType6(img image.Image, pal color.Palette) (frame []byte, lineOffsets []int) {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	getPixel := cel.GetPixelGetter(img, pal)
+	transparentCount := 0
+	flushTransparent := func() {
+		for transparentCount > 0 {
+			n := transparentCount
+			if n > maxTransparent {
+				n = maxTransparent
+			}
+			frame = append(frame, byte(n))
+			transparentCount -= n
+		}
+	}
+	line := make([]byte, width)
+	opaque := make([]bool, width)
+	for y := 0; y < bounds.Dy(); y++ {
+		if y%32 == 0 {
+			flushTransparent()
+		}
+		lineOffsets = append(lineOffsets, len(frame))
+		for x := range line {
+			index, transparent := getPixel()
+			line[x], opaque[x] = index, !transparent
+		}
+		for x := 0; x < width; {
+			if !opaque[x] {
+				transparentCount++
+				x++
+				continue
+			}
+			end := x
+			for end < width && opaque[end] {
+				end++
+			}
+			flushTransparent()
+			frame = appendRegular(frame, line[x:end])
+			x = end
+		}
+	}
+	flushTransparent()
+	return frame, lineOffsets
+}
+
+// appendRegular appends the regular pixels of a line to the frame, using the
+// combination of regular chunks (1 byte plus 1 byte per pixel) and run-length
+// encoded chunks (2 bytes) of minimal size.
+func appendRegular(frame, pixels []byte) []byte {
+	n := len(pixels)
+	// cost[i] is the minimal size of pixels[i:], and chunkLen[i] and fill[i] the
+	// length and kind of the first chunk of that encoding.
+	cost := make([]int, n+1)
+	chunkLen := make([]int, n+1)
+	fill := make([]bool, n+1)
+	for i := n - 1; i >= 0; i-- {
+		cost[i] = -1
+		for l := 1; l <= maxRegular && i+l <= n; l++ {
+			if c := 1 + l + cost[i+l]; cost[i] == -1 || c < cost[i] {
+				cost[i], chunkLen[i], fill[i] = c, l, false
+			}
+		}
+		for l := 1; l <= maxFill && i+l <= n && pixels[i+l-1] == pixels[i]; l++ {
+			if c := 2 + cost[i+l]; c < cost[i] {
+				cost[i], chunkLen[i], fill[i] = c, l, true
+			}
+		}
+	}
+	for i := 0; i < n; i += chunkLen[i] {
+		l := chunkLen[i]
+		if fill[i] {
+			frame = append(frame, byte(int8(-(65 + l))), pixels[i])
+		} else {
+			frame = append(frame, byte(int8(-l)))
+			frame = append(frame, pixels[i:i+l]...)
+		}
+	}
+	return frame
+}
@@ -0,0 +1,130 @@
+package cl2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mewrnd/blizzconv/images/cel"
+)
+
+// testPal returns an opaque palette of 256 distinct colors.
+func testPal() color.Palette {
+	pal := make(color.Palette, 256)
+	for i := range pal {
+		pal[i] = color.RGBA{R: uint8(i), G: uint8(255 - i), B: uint8(i * 7), A: 0xFF}
+	}
+	return pal
+}
+
+// testImage returns a 150x100 image with transparent pixels, runs of equal
+// pixels and regular pixels.
+func testImage(seed int) image.Image {
+	pal := testPal()
+	img := image.NewRGBA(image.Rect(0, 0, 150, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 150; x++ {
+			switch {
+			case (x+y+seed)%37 < 10, y >= 30 && y < 70 && x < 140:
+				// Transparent pixel.
+			case x > 80:
+				img.Set(x, y, pal[7+seed])
+			default:
+				img.Set(x, y, pal[(x*y+seed)%200])
+			}
+		}
+	}
+	return img
+}
+
+// sameImage reports an error if the images differ; the color of transparent
+// pixels is ignored.
+func sameImage(t *testing.T, want, got image.Image) {
+	t.Helper()
+	if want.Bounds().Size() != got.Bounds().Size() {
+		t.Fatalf("size mismatch; expected %v, got %v", want.Bounds().Size(), got.Bounds().Size())
+	}
+	for y := 0; y < want.Bounds().Dy(); y++ {
+		for x := 0; x < want.Bounds().Dx(); x++ {
+			c1 := color.RGBA64Model.Convert(want.At(want.Bounds().Min.X+x, want.Bounds().Min.Y+y))
+			c2 := color.RGBA64Model.Convert(got.At(got.Bounds().Min.X+x, got.Bounds().Min.Y+y))
+			if c1.(color.RGBA64).A == 0 && c2.(color.RGBA64).A == 0 {
+				continue
+			}
+			if c1 != c2 {
+				t.Fatalf("pixel mismatch at (%d, %d); expected %v, got %v", x, y, c1, c2)
+			}
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	pal := testPal()
+	imgs := []image.Image{testImage(0), testImage(1)}
+	conf := &cel.Config{Width: 150, Height: 100, Pal: pal, HeaderSize: 10}
+	var buf bytes.Buffer
+	if err := Encode(&buf, imgs, conf); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"zombiea.cl2": {Data: buf.Bytes()}}
+	got, err := DecodeAll(fsys, "zombiea.cl2", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(imgs) {
+		t.Fatalf("frame count mismatch; expected %d, got %d", len(imgs), len(got))
+	}
+	for i := range imgs {
+		sameImage(t, imgs[i], got[i])
+	}
+
+	// Decoding from the offsets of the frame header yields the lines below
+	// every 32nd line.
+	data := buf.Bytes()
+	start := binary.LittleEndian.Uint32(data[4:])
+	end := binary.LittleEndian.Uint32(data[8:])
+	frame := data[start:end]
+	for i := 1; i*32 < 100; i++ {
+		offset := binary.LittleEndian.Uint16(frame[2*i:])
+		height := 100 - 32*i
+		sub := DecodeFrameType6(frame[offset:], 150, height, pal)
+		sameImage(t, imgs[0].(*image.RGBA).SubImage(image.Rect(0, 0, 150, height)), sub)
+	}
+
+	// Chunk sizes are within the limits of the game.
+	for i := 10; i < len(frame); {
+		n := int(int8(frame[i]))
+		i++
+		switch {
+		case n == 0 || n == -128:
+			t.Fatalf("invalid chunk size %d", n)
+		case n < 0 && -n <= maxRegular:
+			i += -n
+		case n < 0:
+			i++
+		}
+	}
+}
+
+func TestEncodeFrameType6(t *testing.T) {
+	pal := testPal()
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for _, x := range []int{1, 2, 3} {
+		img.Set(x, 0, pal[5])
+	}
+	img.Set(0, 1, pal[1])
+	img.Set(1, 1, pal[5])
+	img.Set(2, 1, pal[5])
+	img.Set(3, 1, pal[2])
+	// The bottom line (4 regular pixels) and the top line (1 transparent pixel,
+	// and a run of 3 equal pixels).
+	want := []byte{0xFC, 1, 5, 5, 2, 1, 0xBC, 5}
+	got := EncodeFrameType6(img, pal)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("frame mismatch; expected % X, got % X", want, got)
+	}
+	sameImage(t, img, DecodeFrameType6(got, 4, 2, pal))
+}
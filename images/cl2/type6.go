@@ -42,8 +42,7 @@ import (
 //
 // Type6 is the only type for CL2 images.
 func DecodeFrameType6(frame []byte, width int, height int, pal color.Palette) image.Image {
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
-	setPixel := cel.GetPixelSetter(width, height)
+	fw := cel.NewFrameWriter(width, height, pal)
 	pos := 0
 	for pos < len(frame) {
 		chunkSize := int(int8(frame[pos]))
@@ -51,26 +50,26 @@ func DecodeFrameType6(frame []byte, width int, height int, pal color.Palette) im
 		if chunkSize >= 0 {
 			// Transparent pixels.
 			for i := 0; i < chunkSize; i++ {
-				setPixel(img, color.Transparent)
+				fw.SetTransparent()
 			}
 		} else {
 			chunkSize = -chunkSize
 			if chunkSize <= 65 {
 				// Regular pixels.
 				for i := 0; i < chunkSize; i++ {
-					setPixel(img, pal[frame[pos]])
+					fw.SetIndex(frame[pos])
 					pos++
 				}
 			} else {
 				chunkSize -= 65
 				// Run-length encoded pixels.
-				c := pal[frame[pos]]
+				index := frame[pos]
 				for i := 0; i < chunkSize; i++ {
-					setPixel(img, c)
+					fw.SetIndex(index)
 				}
 				pos++
 			}
 		}
 	}
-	return img
+	return fw.Image()
 }
@@ -0,0 +1,137 @@
+// font_render is a tool for rendering text into png images, using the fonts of
+// the game.
+//
+// Usage:
+//
+//    font_render [OPTION]... [text]...
+//
+// Flags:
+//
+//    -font="font42g.pcx"
+//            Name of a PCX font (e.g. font16s.pcx) or CEL font (e.g. smaltext.cel).
+//    -imgini="cel.ini"
+//            Path to an ini file containing image information.
+//    -mpq=""
+//            Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of
+//            MPQ archives and directories in order of priority.
+//    -mpqdump="mpqdump/"
+//            Path to an extracted MPQ file.
+//    -mpqini="mpq.ini"
+//            Path to an ini file containing relative path information.
+//    -o="text.png"
+//            Output path.
+//
+// Each argument is rendered as a line of text. The glyph widths of PCX fonts are
+// read from the BIN file of the same size (e.g. font16.bin for font16s.pcx).
+// CEL fonts are rendered using the first palette of the image, as specified by
+// the ini file.
+//
+// Example:
+//
+//    $ font_render -mpq=diabdat.mpq -font=font30g.pcx -o=title.png "Single Player"
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/mewkiz/pkg/imgutil"
+	"github.com/mewrnd/blizzconv/images/font"
+	"github.com/mewrnd/blizzconv/images/imgconf"
+	"github.com/mewrnd/blizzconv/mpq"
+	xfont "golang.org/x/image/font"
+)
+
+// Flags specified on the command line.
+var (
+	fontName, outputPath                             string
+	imgIniPath, archivePath, extractPath, mpqIniPath string
+)
+
+func init() {
+	flag.Usage = usage
+	flag.StringVar(&fontName, "font", "font42g.pcx", "Name of a PCX font (e.g. font16s.pcx) or CEL font (e.g. smaltext.cel).")
+	flag.StringVar(&imgIniPath, "imgini", "cel.ini", "Path to an ini file containing image information.")
+	flag.StringVar(&archivePath, "mpq", "", "Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of MPQ archives and directories in order of priority.")
+	flag.StringVar(&extractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
+	flag.StringVar(&mpqIniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
+	flag.StringVar(&outputPath, "o", "text.png", "Output path.")
+	flag.Parse()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]... [text]...\n", os.Args[0])
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	archive, err := mpq.OpenArchive(extractPath, mpqIniPath, strings.Split(archivePath, ",")...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer archive.Close()
+	face, err := loadFace(archive)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = render(face, flag.Args())
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// loadFace loads the font specified by the -font flag.
+func loadFace(archive *mpq.Archive) (face *font.Face, err error) {
+	fsys := archive.FS()
+	relFontPath, err := archive.GetRelPath(fontName)
+	if err != nil {
+		return nil, err
+	}
+	switch path.Ext(fontName) {
+	case ".pcx":
+		// The glyph sheets of a font size share the BIN file of the size, e.g.
+		// font42g.pcx and font42y.pcx use font42.bin.
+		binName := strings.TrimRight(strings.TrimSuffix(fontName, ".pcx"), "abcdefghijklmnopqrstuvwxyz") + ".bin"
+		relBinPath, err := archive.GetRelPath(binName)
+		if err != nil {
+			return nil, err
+		}
+		return font.LoadPCX(fsys, relBinPath, relFontPath)
+	case ".cel":
+		imgConf, err := imgconf.Load(imgIniPath)
+		if err != nil {
+			return nil, err
+		}
+		relPalPath := imgConf.GetRelPalPathsFor(fsys, fontName, relFontPath)[0]
+		return font.LoadCEL(fsys, imgConf, fontName, relFontPath, relPalPath)
+	}
+	return nil, fmt.Errorf("unsupported font %q.", fontName)
+}
+
+// render renders each line of text using the font face, and stores the result
+// as a png image.
+func render(face *font.Face, lines []string) (err error) {
+	height := face.Metrics().Height.Ceil()
+	width := 0
+	for _, line := range lines {
+		if w := xfont.MeasureString(face, line).Ceil(); w > width {
+			width = w
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height*len(lines)))
+	for i, line := range lines {
+		face.DrawString(dst, image.Pt(0, (i+1)*height), line)
+	}
+	return imgutil.WriteFile(outputPath, dst)
+}
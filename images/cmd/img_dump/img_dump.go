@@ -1,26 +1,47 @@
-// img_dump is a tool for converting CEL and CL2 images into png images.
+// img_dump is a tool for converting CEL, CL2, PCX and GIF images into png
+// images.
 //
 // Usage:
 //
-//    img_dump [OPTION]... [name.cel|name.cl2]...
+//    img_dump [OPTION]... [name.cel|name.cl2|name.pcx|name.gif]...
 //
 // Flags:
 //
 //    -a
 //            Dump all image files.
-//    -imgini="cel.ini"
-//            Path to an ini file containing image information.
-//            Note: 'cl2.ini' will be used for files that have the '.cl2' extension.
+//    -cutscreens
+//            Dump all loading screens, with and without progress bar.
+//    -gif
+//            Dump all GIF images.
+//    -imgini="cel.ini,cl2.ini"
+//            Comma-separated list of ini files containing image information.
+//            Note: each image uses the ini file named after its extension (e.g.
+//            'cl2.ini' for '.cl2' files), or otherwise the first ini file.
+//    -mpq=""
+//            Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of
+//            MPQ archives and directories in order of priority.
 //    -mpqdump="mpqdump/"
 //            Path to an extracted MPQ file.
 //    -mpqini="mpq.ini"
 //            Path to an ini file containing relative path information.
+//    -pcx
+//            Dump all PCX images.
+//
+// Images without explicit palettes in the image ini file use the palette of the
+// same name and directory if present (e.g. 'gendata/cutl1d.pal').
+//
+// The loading screens are stored in the "_dump_/_cutscreens_/" directory; once
+// as is (e.g. cutl1d.png), and once with the progress bar fully drawn onto the
+// screen (e.g. cutl1d_progress.png).
 package main
 
 import (
 	"flag"
 	"fmt"
-	"image/color"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io/fs"
 	"log"
 	"os"
 	"path"
@@ -28,10 +49,12 @@ import (
 
 	"github.com/0xC3/progress/barcli"
 	"github.com/mewkiz/pkg/imgutil"
+	"github.com/mewrnd/blizzconv/configs/min"
 	"github.com/mewrnd/blizzconv/images/cel"
 	"github.com/mewrnd/blizzconv/images/cl2"
 	"github.com/mewrnd/blizzconv/images/imgarchive"
 	"github.com/mewrnd/blizzconv/images/imgconf"
+	"github.com/mewrnd/blizzconv/images/pcx"
 	"github.com/mewrnd/blizzconv/images/trn"
 	"github.com/mewrnd/blizzconv/mpq"
 )
@@ -39,21 +62,33 @@ import (
 // flagAll specifies if all CEL images should be dumped or not.
 var flagAll bool
 
+// flagCutscreens specifies if all loading screens should be dumped or not.
+var flagCutscreens bool
+
+// flagGIF specifies if all GIF images should be dumped or not.
+var flagGIF bool
+
+// flagPCX specifies if all PCX images should be dumped or not.
+var flagPCX bool
+
+// Paths specified by command line flags.
+var imgIniPath, archivePath, extractPath, mpqIniPath string
+
 func init() {
 	flag.Usage = usage
 	flag.BoolVar(&flagAll, "a", false, "Dump all image files.")
-	flag.StringVar(&imgconf.IniPath, "imgini", "cel.ini", "Path to an ini file containing image information.")
-	flag.StringVar(&mpq.ExtractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
-	flag.StringVar(&mpq.IniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
+	flag.BoolVar(&flagCutscreens, "cutscreens", false, "Dump all loading screens, with and without progress bar.")
+	flag.BoolVar(&flagGIF, "gif", false, "Dump all GIF images.")
+	flag.StringVar(&imgIniPath, "imgini", "cel.ini,cl2.ini", "Comma-separated list of ini files containing image information.")
+	flag.StringVar(&archivePath, "mpq", "", "Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of MPQ archives and directories in order of priority.")
+	flag.StringVar(&extractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
+	flag.StringVar(&mpqIniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
+	flag.BoolVar(&flagPCX, "pcx", false, "Dump all PCX images.")
 	flag.Parse()
-	err := mpq.Init()
-	if err != nil {
-		log.Fatalln(err)
-	}
 }
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]... [name.cel|name.cl2]...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]... [name.cel|name.cl2|name.pcx|name.gif]...\n", os.Args[0])
 	fmt.Fprintln(os.Stderr)
 	fmt.Fprintln(os.Stderr, "Flags:")
 	flag.PrintDefaults()
@@ -62,26 +97,80 @@ func usage() {
 // bar represents the progress bar.
 var bar *barcli.Bar
 
+var (
+	// archive provides access to the files of the MPQ archive.
+	archive *mpq.Archive
+	// fsys is the file system of the MPQ archive.
+	fsys fs.FS
+	// imgConfs provides image information, with one config per image ini file.
+	imgConfs []*imgconf.Config
+)
+
 func main() {
-	if flag.NArg() > 0 {
-		if path.Ext(flag.Arg(0)) == ".cl2" && imgconf.IniPath == "cel.ini" {
-			imgconf.IniPath = "cl2.ini"
-		}
-	}
-	err := imgconf.Init()
+	var err error
+	archive, err = mpq.OpenArchive(extractPath, mpqIniPath, strings.Split(archivePath, ",")...)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	if flagAll {
-		bar, err = barcli.New(imgconf.Len())
+	defer archive.Close()
+	fsys = archive.FS()
+	total := 0
+	for _, iniPath := range strings.Split(imgIniPath, ",") {
+		imgConf, err := imgconf.Load(iniPath)
 		if err != nil {
 			log.Fatalln(err)
 		}
-		// dump all images in the ini file.
-		err := imgconf.AllFunc(dump)
+		imgConfs = append(imgConfs, imgConf)
+		total += imgConf.Len()
+	}
+	if flagAll {
+		bar, err = barcli.New(total)
 		if err != nil {
 			log.Fatalln(err)
 		}
+		// dump all images in the ini files.
+		for _, imgConf := range imgConfs {
+			err := imgConf.AllFunc(func(imgName string) error {
+				return dump(imgConf, imgName)
+			})
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+		return
+	}
+	if flagCutscreens {
+		// dump all loading screens in the ini files.
+		for _, imgConf := range imgConfs {
+			err := imgConf.AllFunc(func(imgName string) error {
+				return cutscreenDump(imgConf, imgName)
+			})
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+		return
+	}
+	if flagPCX || flagGIF {
+		// dump all PCX and/or GIF images of the archive.
+		for _, imgName := range archive.Names() {
+			switch path.Ext(imgName) {
+			case ".pcx":
+				if !flagPCX {
+					continue
+				}
+			case ".gif":
+				if !flagGIF {
+					continue
+				}
+			default:
+				continue
+			}
+			err := dump(getImgConf(imgName), imgName)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
 		return
 	}
 	if flag.NArg() < 1 {
@@ -89,54 +178,87 @@ func main() {
 		os.Exit(1)
 	}
 	for _, imgName := range flag.Args() {
-		err := dump(imgName)
+		err := dump(getImgConf(imgName), imgName)
 		if err != nil {
 			log.Fatalln(err)
 		}
 	}
 }
 
+// getImgConf returns the image information of the given image, as provided by
+// the ini file named after the extension of the image (e.g. 'cl2.ini' for
+// 'foo.cl2'), or otherwise by the first ini file.
+func getImgConf(imgName string) *imgconf.Config {
+	iniName := strings.TrimPrefix(path.Ext(imgName), ".") + ".ini"
+	for i, iniPath := range strings.Split(imgIniPath, ",") {
+		if path.Base(iniPath) == iniName {
+			return imgConfs[i]
+		}
+	}
+	return imgConfs[0]
+}
+
 // dump extracts archived images if there are any, decodes image configs (pals)
 // and dumps the image's frames, once for each image config.
-func dump(imgName string) (err error) {
+func dump(imgConf *imgconf.Config, imgName string) (err error) {
 	if flagAll {
 		bar.Inc()
 	}
-	_, found := imgconf.GetImageCount(imgName)
+	relImgPath, err := archive.GetRelPath(imgName)
+	if err != nil {
+		return err
+	}
+	switch path.Ext(relImgPath) {
+	case ".pcx":
+		return dumpPCX(relImgPath)
+	case ".gif":
+		return dumpGIF(relImgPath)
+	}
+	_, found := imgConf.GetImageCount(imgName)
 	if found {
 		// extract archived images
-		err = imgarchive.Extract(imgName)
+		err = imgarchive.Extract(fsys, imgConf, relImgPath, archive.ExtractPath)
 		if err != nil {
 			return err
 		}
 		return nil
 	}
 
-	relPalPaths := imgconf.GetRelPalPaths(imgName)
+	relPalPaths := imgConf.GetRelPalPathsFor(fsys, imgName, relImgPath)
+	relTrnPaths := imgConf.GetRelTrnPaths(imgName)
+	// the MIN file of CEL image level files specifies the type of each frame.
+	frameTypes, err := min.GetFrameTypes(fsys, relImgPath)
+	if err != nil {
+		return err
+	}
+	var frames []image.Image
 	for _, relPalPath := range relPalPaths {
-		conf, err := cel.GetConf(imgName, relPalPath)
+		conf, err := cel.GetConf(fsys, imgConf, imgName, relPalPath)
 		if err != nil {
 			return err
 		}
+		conf.FrameTypes = frameTypes
+		if frames == nil {
+			// decode the image's frames once, and recolor them for each palette
+			// and color transition.
+			frames, err = cl2.DecodeAll(fsys, relImgPath, conf)
+			if err != nil {
+				return err
+			}
+		}
 		var palDir string
 		if len(relPalPaths) > 1 {
 			palDir = path.Base(relPalPath) + "/"
 		}
 
 		// dump the image's frames using conf (pal) with no color transitions.
-		err = dumpFrames(conf, palDir, "", imgName)
+		err = dumpFrames(cel.RecolorAll(frames, conf.Pal), palDir, "", relImgPath)
 		if err != nil {
 			return err
 		}
 
-		relTrnPaths := imgconf.GetRelTrnPaths(imgName)
-		var srcPal color.Palette
-		if len(relTrnPaths) > 0 {
-			srcPal = make(color.Palette, len(conf.Pal))
-			copy(srcPal, conf.Pal)
-		}
 		for _, relTrnPath := range relTrnPaths {
-			conf.Pal, err = trn.ConvertPal(srcPal, relTrnPath)
+			trnPal, err := trn.ConvertPal(fsys, conf.Pal, relTrnPath)
 			if err != nil {
 				return err
 			}
@@ -147,7 +269,7 @@ func dump(imgName string) (err error) {
 			}
 
 			// dump the image's frames using conf (pal) with color transitions.
-			err = dumpFrames(conf, palDir, trnDir, imgName)
+			err = dumpFrames(cel.RecolorAll(frames, trnPal), palDir, trnDir, relImgPath)
 			if err != nil {
 				return err
 			}
@@ -156,15 +278,11 @@ func dump(imgName string) (err error) {
 	return nil
 }
 
-// dumpFrames decodes an image's frames using a given image config (pal),
-// creates a dump directory and stores each frame as a new png image.
-func dumpFrames(conf *cel.Config, palDir, trnDir, imgName string) (err error) {
-	// decode frames using the given image config (pal)
-	imgs, err := cl2.DecodeAll(imgName, conf)
-	if err != nil {
-		return err
-	}
+// dumpFrames creates a dump directory and stores each of the image's frames as
+// a new png image. Paletted frames are stored as indexed png images.
+func dumpFrames(imgs []image.Image, palDir, trnDir, relImgPath string) (err error) {
 	// create dumpDir
+	imgDir, imgName := path.Split(relImgPath)
 	nameWithoutExt := imgName[:len(imgName)-len(path.Ext(imgName))]
 	var frameDir, pngName string
 	if len(imgs) > 1 {
@@ -174,7 +292,7 @@ func dumpFrames(conf *cel.Config, palDir, trnDir, imgName string) (err error) {
 	}
 	var dumpDir string
 	if len(imgs) > 0 {
-		dumpDir, err = createDumpDir(frameDir, palDir, trnDir, imgName)
+		dumpDir, err = createDumpDir(imgDir, frameDir, palDir, trnDir)
 		if err != nil {
 			return err
 		}
@@ -191,6 +309,109 @@ func dumpFrames(conf *cel.Config, palDir, trnDir, imgName string) (err error) {
 	return nil
 }
 
+// cutscreenDump stores the loading screen imgName as a png image, both with and
+// without its progress bar. Images without a progress bar are ignored.
+func cutscreenDump(imgConf *imgconf.Config, imgName string) (err error) {
+	barRect, colorIndex, found, err := imgConf.GetProgressBar(imgName)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+	relImgPath, err := archive.GetRelPath(imgName)
+	if err != nil {
+		return err
+	}
+	relPalPath := imgConf.GetRelPalPathsFor(fsys, imgName, relImgPath)[0]
+	conf, err := cel.GetConf(fsys, imgConf, imgName, relPalPath)
+	if err != nil {
+		return err
+	}
+	imgs, err := cel.DecodeAll(fsys, relImgPath, conf)
+	if err != nil {
+		return err
+	}
+	if len(imgs) < 1 {
+		return fmt.Errorf("no frames in %q.", imgName)
+	}
+	if colorIndex < 0 || colorIndex >= len(conf.Pal) {
+		return fmt.Errorf("invalid progress_color %d for %q.", colorIndex, imgName)
+	}
+	dumpDir, err := createDumpDir("_cutscreens_/", "", "", "")
+	if err != nil {
+		return err
+	}
+	nameWithoutExt := imgName[:len(imgName)-len(path.Ext(imgName))]
+	screen := imgs[0]
+	err = imgutil.WriteFile(dumpDir+nameWithoutExt+".png", screen)
+	if err != nil {
+		return err
+	}
+	// draw the progress bar onto a copy of the loading screen.
+	bounds := screen.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), screen, bounds.Min, draw.Src)
+	fill := &image.Uniform{C: conf.Pal[colorIndex]}
+	draw.Draw(dst, barRect, fill, image.Point{}, draw.Src)
+	return imgutil.WriteFile(dumpDir+nameWithoutExt+"_progress.png", dst)
+}
+
+// dumpPCX decodes a PCX image and stores it as a png image.
+func dumpPCX(relImgPath string) (err error) {
+	f, err := fsys.Open(relImgPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	img, err := pcx.Decode(f)
+	if err != nil {
+		return fmt.Errorf("unable to decode %q: %v", relImgPath, err)
+	}
+	imgDir, imgName := path.Split(relImgPath)
+	dumpDir, err := createDumpDir(imgDir, "", "", "")
+	if err != nil {
+		return err
+	}
+	pngName := imgName[:len(imgName)-len(path.Ext(imgName))] + ".png"
+	return imgutil.WriteFile(dumpDir+pngName, img)
+}
+
+// dumpGIF decodes a GIF image and stores each of its frames as a png image.
+func dumpGIF(relImgPath string) (err error) {
+	f, err := fsys.Open(relImgPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	anim, err := gif.DecodeAll(f)
+	if err != nil {
+		return fmt.Errorf("unable to decode %q: %v", relImgPath, err)
+	}
+	imgDir, imgName := path.Split(relImgPath)
+	nameWithoutExt := imgName[:len(imgName)-len(path.Ext(imgName))]
+	var frameDir, pngName string
+	if len(anim.Image) > 1 {
+		frameDir = nameWithoutExt + "/"
+	} else {
+		pngName = nameWithoutExt + ".png"
+	}
+	dumpDir, err := createDumpDir(imgDir, frameDir, "", "")
+	if err != nil {
+		return err
+	}
+	for frameNum, img := range anim.Image {
+		if len(anim.Image) > 1 {
+			pngName = fmt.Sprintf("%s_%04d.png", nameWithoutExt, frameNum)
+		}
+		err := imgutil.WriteFile(dumpDir+pngName, img)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // dumpPrefix is the name of the dump directory.
 const dumpPrefix = "_dump_/"
 
@@ -248,12 +469,7 @@ const dumpPrefix = "_dump_/"
 //       _dump_/imgDir/name/pal_0002/trn_0001/name_0002.png
 //       _dump_/imgDir/name/pal_0002/trn_0002/name_0001.png
 //       _dump_/imgDir/name/pal_0002/trn_0002/name_0002.png
-func createDumpDir(frameDir, palDir, trnDir, imgName string) (dumpDir string, err error) {
-	imgPath, err := mpq.GetRelPath(imgName)
-	if err != nil {
-		return "", err
-	}
-	imgDir, _ := path.Split(imgPath)
+func createDumpDir(imgDir, frameDir, palDir, trnDir string) (dumpDir string, err error) {
 	dumpDir = path.Clean(dumpPrefix+imgDir+frameDir+palDir+trnDir) + "/"
 	// prevent directory traversal
 	if !strings.HasPrefix(dumpDir, dumpPrefix) {
@@ -9,6 +9,9 @@
 //    -celini="cel.ini"
 //            Path to an ini file containing image information.
 //            Note: 'cl2.ini' will be used for files that have the '.cl2' extension.
+//    -mpq=""
+//            Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of
+//            MPQ archives and directories in order of priority.
 //    -mpqdump="mpqdump/"
 //            Path to an extracted MPQ file.
 //    -mpqini="mpq.ini"
@@ -21,22 +24,23 @@ import (
 	"log"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/mewrnd/blizzconv/images/imgarchive"
 	"github.com/mewrnd/blizzconv/images/imgconf"
 	"github.com/mewrnd/blizzconv/mpq"
 )
 
+// Paths specified by command line flags.
+var imgIniPath, archivePath, extractPath, mpqIniPath string
+
 func init() {
 	flag.Usage = usage
-	flag.StringVar(&imgconf.IniPath, "celini", "cel.ini", "Path to an ini file containing image information.")
-	flag.StringVar(&mpq.ExtractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
-	flag.StringVar(&mpq.IniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
+	flag.StringVar(&imgIniPath, "celini", "cel.ini", "Path to an ini file containing image information.")
+	flag.StringVar(&archivePath, "mpq", "", "Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of MPQ archives and directories in order of priority.")
+	flag.StringVar(&extractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
+	flag.StringVar(&mpqIniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
 	flag.Parse()
-	err := mpq.Init()
-	if err != nil {
-		log.Fatalln(err)
-	}
 }
 
 func usage() {
@@ -51,17 +55,27 @@ func main() {
 		flag.Usage()
 		os.Exit(1)
 	}
+	archive, err := mpq.OpenArchive(extractPath, mpqIniPath, strings.Split(archivePath, ",")...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer archive.Close()
 	if flag.NArg() > 0 {
 		if path.Ext(flag.Arg(0)) == ".cl2" {
-			imgconf.IniPath = "cl2.ini"
+			imgIniPath = "cl2.ini"
 		}
 	}
-	err := imgconf.Init()
+	imgConf, err := imgconf.Load(imgIniPath)
 	if err != nil {
 		log.Fatalln(err)
 	}
+	fsys := archive.FS()
 	for _, imgName := range flag.Args() {
-		err = imgarchive.Extract(imgName)
+		relImgPath, err := archive.GetRelPath(imgName)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		err = imgarchive.Extract(fsys, imgConf, relImgPath, archive.ExtractPath)
 		if err != nil {
 			log.Fatalln(err)
 		}
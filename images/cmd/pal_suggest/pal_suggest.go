@@ -0,0 +1,249 @@
+// pal_suggest is a tool for suggesting the palettes of CEL and CL2 images, based
+// on the palette indices used by their frames.
+//
+// Usage:
+//
+//    pal_suggest [OPTION]... [name.cel|name.cl2]...
+//
+// Flags:
+//
+//    -a
+//            Analyze all images of the image ini file.
+//    -imgini="cel.ini,cl2.ini"
+//            Comma-separated list of ini files containing image information.
+//            Note: each image uses the ini file named after its extension (e.g.
+//            'cl2.ini' for '.cl2' files), or otherwise the first ini file.
+//    -mpq=""
+//            Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of
+//            MPQ archives and directories in order of priority.
+//    -mpqdump="mpqdump/"
+//            Path to an extracted MPQ file.
+//    -mpqini="mpq.ini"
+//            Path to an ini file containing relative path information.
+//
+// Each image is compared against every PAL file of the archive, and the most
+// plausible palettes are suggested (see the palusage package). Suggestions which
+// differ from the palettes currently used for the image are stored as an ini
+// patch of its image ini file in the "_dump_/_pals_/" directory (e.g.
+// _dump_/_pals_/cel.ini), whose pals keys may be merged into the image ini
+// file.
+//
+// Example:
+//
+//    $ pal_suggest -mpq=diabdat.mpq -imgini=cel.ini -a
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/0xC3/progress/barcli"
+	"github.com/mewrnd/blizzconv/configs/min"
+	"github.com/mewrnd/blizzconv/images/cel"
+	"github.com/mewrnd/blizzconv/images/imgconf"
+	"github.com/mewrnd/blizzconv/images/palusage"
+	"github.com/mewrnd/blizzconv/mpq"
+)
+
+// flagAll specifies if all images should be analyzed or not.
+var flagAll bool
+
+// Paths specified by command line flags.
+var imgIniPath, archivePath, extractPath, mpqIniPath string
+
+func init() {
+	flag.Usage = usage
+	flag.BoolVar(&flagAll, "a", false, "Analyze all images of the image ini file.")
+	flag.StringVar(&imgIniPath, "imgini", "cel.ini,cl2.ini", "Comma-separated list of ini files containing image information.")
+	flag.StringVar(&archivePath, "mpq", "", "Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of MPQ archives and directories in order of priority.")
+	flag.StringVar(&extractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
+	flag.StringVar(&mpqIniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
+	flag.Parse()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]... [name.cel|name.cl2]...\n", os.Args[0])
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+// bar represents the progress bar.
+var bar *barcli.Bar
+
+var (
+	// archive provides access to the files of the MPQ archive.
+	archive *mpq.Archive
+	// fsys is the file system of the MPQ archive.
+	fsys fs.FS
+	// imgInis contains the image ini files.
+	imgInis []*imgIni
+	// analyzer suggests the palettes of images.
+	analyzer *palusage.Analyzer
+)
+
+// An imgIni is an image ini file, and the ini patch of its suggested palettes.
+type imgIni struct {
+	// name is the base name of the ini file.
+	name string
+	// conf provides image information.
+	conf *imgconf.Config
+	// patch is the ini patch of the suggested palettes.
+	patch *bufio.Writer
+}
+
+func main() {
+	if !flagAll && flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	var err error
+	archive, err = mpq.OpenArchive(extractPath, mpqIniPath, strings.Split(archivePath, ",")...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer archive.Close()
+	fsys = archive.FS()
+	// Use each PAL file of the archive as a candidate palette.
+	var relPalPaths []string
+	for _, name := range archive.Names() {
+		if path.Ext(name) != ".pal" {
+			continue
+		}
+		relPalPath, err := archive.GetRelPath(name)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		relPalPaths = append(relPalPaths, relPalPath)
+	}
+	analyzer, err = palusage.NewAnalyzer(fsys, relPalPaths)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// create the ini patch of each image ini file.
+	dumpDir := dumpPrefix + "_pals_/"
+	err = os.MkdirAll(dumpDir, 0755)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	total := 0
+	for _, iniPath := range strings.Split(imgIniPath, ",") {
+		ini := &imgIni{name: path.Base(iniPath)}
+		ini.conf, err = imgconf.Load(iniPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fw, err := os.Create(dumpDir + ini.name)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer fw.Close()
+		ini.patch = bufio.NewWriter(fw)
+		fmt.Fprintf(ini.patch, "# Suggested palettes of %s, based on %d palettes.\n", ini.name, len(relPalPaths))
+		imgInis = append(imgInis, ini)
+		total += ini.conf.Len()
+	}
+
+	if flagAll {
+		bar, err = barcli.New(total)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		// analyze all images in the ini files.
+		for _, ini := range imgInis {
+			err = ini.conf.AllFunc(func(imgName string) error {
+				return suggest(ini, imgName)
+			})
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+	} else {
+		for _, imgName := range flag.Args() {
+			err = suggest(getImgIni(imgName), imgName)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+	}
+	for _, ini := range imgInis {
+		err = ini.patch.Flush()
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
+
+// getImgIni returns the image ini file of the given image; the ini file named
+// after the extension of the image (e.g. 'cl2.ini' for 'foo.cl2'), or otherwise
+// the first ini file.
+func getImgIni(imgName string) *imgIni {
+	iniName := strings.TrimPrefix(path.Ext(imgName), ".") + ".ini"
+	for _, ini := range imgInis {
+		if ini.name == iniName {
+			return ini
+		}
+	}
+	return imgInis[0]
+}
+
+// dumpPrefix is the name of the dump directory.
+const dumpPrefix = "_dump_/"
+
+// suggest analyzes the palette-index usage of the image, and adds the suggested
+// palettes to the ini patch if they differ from the palettes currently used for
+// the image.
+func suggest(ini *imgIni, imgName string) (err error) {
+	if flagAll {
+		bar.Inc()
+	}
+	imgConf := ini.conf
+	_, found := imgConf.GetImageCount(imgName)
+	if found {
+		// archived images are analyzed once extracted.
+		return nil
+	}
+	relImgPath, err := archive.GetRelPath(imgName)
+	if err != nil {
+		return err
+	}
+	curRelPalPaths := imgConf.GetRelPalPathsFor(fsys, imgName, relImgPath)
+	conf, err := cel.GetConf(fsys, imgConf, imgName, curRelPalPaths[0])
+	if err != nil {
+		return err
+	}
+	// the MIN file of CEL image level files specifies the type of each frame.
+	conf.FrameTypes, err = min.GetFrameTypes(fsys, relImgPath)
+	if err != nil {
+		return err
+	}
+	usage, err := palusage.GetUsage(fsys, relImgPath, conf)
+	if err != nil {
+		return err
+	}
+	relPalPaths, score := analyzer.Suggest(relImgPath, usage)
+	if len(relPalPaths) == 0 {
+		// the image only uses colors shared by all palettes.
+		return nil
+	}
+	for _, curRelPalPath := range curRelPalPaths {
+		for _, relPalPath := range relPalPaths {
+			if curRelPalPath == relPalPath {
+				// the image already uses a suggested palette.
+				return nil
+			}
+		}
+	}
+	fmt.Fprintf(ini.patch, "\n[%s]\n", imgName)
+	fmt.Fprintf(ini.patch, "#path=%s\n", relImgPath)
+	fmt.Fprintf(ini.patch, "#score=%d\n", score)
+	fmt.Fprintf(ini.patch, "pals=%s\n", strings.Join(relPalPaths, ","))
+	return nil
+}
@@ -0,0 +1,159 @@
+// png2cel is a tool for converting png images into CEL images, one frame per png
+// image.
+//
+// Usage:
+//
+//    png2cel [OPTION]... frame.png...
+//
+// Flags:
+//
+//    -header=0
+//            Size of the frame header in bytes (e.g. 10); see header_size of cel.ini.
+//    -level=false
+//            Encode the frames as level frames (e.g. l1.cel).
+//    -min=""
+//            Path to a MIN file (e.g. l1.min) whose blocks specify the frame type
+//            of level frames.
+//    -o="out.cel"
+//            Path to the output CEL image.
+//    -pal=""
+//            Path to a PAL file (e.g. town.pal), or a PCX or GIF image whose
+//            palette is used.
+//
+// The colors of each frame are mapped to the closest color of the palette, and
+// fully transparent pixels are encoded as transparent. Paletted png images
+// which use the palette are encoded using their palette indices as is.
+//
+// Level frames are encoded using the frame type specified by the blocks of the
+// MIN file, if any. Other level frames are encoded as plain, triangle or
+// trapezoid frames (type 0, 2, 3, 4 or 5) if their transparent pixels
+// correspond to the layout of the frame type, and as regular frames (type 1)
+// otherwise; the frame type of each such frame is printed, as it must match the
+// type of the MIN blocks that refer to the frame.
+//
+// Example:
+//
+//    $ png2cel -pal=town.pal -header=10 -o=angel.cel _dump_/objects/angel/angel_*.png
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/mewrnd/blizzconv/configs/min"
+	"github.com/mewrnd/blizzconv/images/cel"
+)
+
+// flagLevel specifies if the frames should be encoded as level frames or not.
+var flagLevel bool
+
+// headerSize specifies the size of the frame header in bytes.
+var headerSize int
+
+// Paths specified by command line flags.
+var outPath, minPath, palPath string
+
+func init() {
+	flag.Usage = usage
+	flag.IntVar(&headerSize, "header", 0, "Size of the frame header in bytes (e.g. 10); see header_size of cel.ini.")
+	flag.BoolVar(&flagLevel, "level", false, "Encode the frames as level frames (e.g. l1.cel).")
+	flag.StringVar(&minPath, "min", "", "Path to a MIN file (e.g. l1.min) whose blocks specify the frame type of level frames.")
+	flag.StringVar(&outPath, "o", "out.cel", "Path to the output CEL image.")
+	flag.StringVar(&palPath, "pal", "", "Path to a PAL file (e.g. town.pal), or a PCX or GIF image whose palette is used.")
+	flag.Parse()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]... frame.png...\n", os.Args[0])
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	if flag.NArg() < 1 || palPath == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	err := png2cel(flag.Args())
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// png2cel encodes the png images located at pngPaths as the frames of a CEL
+// image, and stores it at outPath.
+func png2cel(pngPaths []string) (err error) {
+	if flagLevel && headerSize != 0 {
+		return fmt.Errorf("level frames have no frame header.")
+	}
+	pal, err := cel.GetPal(os.DirFS(filepath.Dir(palPath)), filepath.Base(palPath))
+	if err != nil {
+		return err
+	}
+	var imgs []image.Image
+	for _, pngPath := range pngPaths {
+		img, err := readPNG(pngPath)
+		if err != nil {
+			return err
+		}
+		imgs = append(imgs, img)
+	}
+	fw, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer fw.Close()
+	if flagLevel {
+		return encodeLevel(fw, imgs, pal)
+	}
+	conf := &cel.Config{
+		Pal:        pal,
+		HeaderSize: headerSize,
+	}
+	return cel.Encode(fw, imgs, conf)
+}
+
+// encodeLevel encodes the images as the level frames of a CEL image, using the
+// frame types of the MIN file located at minPath, if any.
+func encodeLevel(w io.Writer, imgs []image.Image, pal color.Palette) (err error) {
+	var frameTypes map[int]int
+	if minPath != "" {
+		pillars, err := min.Parse(os.DirFS(filepath.Dir(minPath)), filepath.Base(minPath))
+		if err != nil {
+			return err
+		}
+		frameTypes = min.FrameTypes(pillars)
+	}
+	types, err := cel.EncodeLevel(w, imgs, pal, frameTypes)
+	if err != nil {
+		return err
+	}
+	for frameNum, frameType := range types {
+		if _, ok := frameTypes[frameNum]; !ok {
+			fmt.Printf("frame %d: type %d\n", frameNum, frameType)
+		}
+	}
+	return nil
+}
+
+// readPNG reads and decodes the png image located at pngPath.
+func readPNG(pngPath string) (img image.Image, err error) {
+	f, err := os.Open(pngPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err = image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode %q: %v", pngPath, err)
+	}
+	return img, nil
+}
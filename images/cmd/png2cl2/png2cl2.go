@@ -0,0 +1,140 @@
+// png2cl2 is a tool for converting png images into CL2 images and CL2 archives,
+// one frame per png image.
+//
+// Usage:
+//
+//    png2cl2 [OPTION]... dir...
+//
+// Flags:
+//
+//    -header=10
+//            Size of the frame header in bytes; see header_size of cl2.ini.
+//    -o="out.cl2"
+//            Path to the output CL2 image or archive.
+//    -pal=""
+//            Path to a PAL file (e.g. town.pal), or a PCX or GIF image whose
+//            palette is used.
+//
+// Each directory contains the png images of the frames of one CL2 image, which
+// are encoded in the order of their file names. A single directory is stored as
+// a CL2 image, and several directories as a CL2 archive with one image per
+// directory; e.g. the eight directions of a monster or player animation, in the
+// order of the game (south, south-west, west, north-west, north, north-east,
+// east and south-east).
+//
+// The colors of each frame are mapped to the closest color of the palette, and
+// fully transparent pixels are encoded as transparent. Paletted png images
+// which use the palette are encoded using their palette indices as is.
+//
+// Example:
+//
+//    $ png2cl2 -pal=town.pal -o=zombiew.cl2 _dump_/monsters/zombie/zombiew{0,1,2,3,4,5,6,7}
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	_ "image/png"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/mewrnd/blizzconv/images/cel"
+	"github.com/mewrnd/blizzconv/images/cl2"
+	"github.com/mewrnd/blizzconv/images/imgarchive"
+)
+
+// headerSize specifies the size of the frame header in bytes.
+var headerSize int
+
+// Paths specified by command line flags.
+var outPath, palPath string
+
+func init() {
+	flag.Usage = usage
+	flag.IntVar(&headerSize, "header", 10, "Size of the frame header in bytes; see header_size of cl2.ini.")
+	flag.StringVar(&outPath, "o", "out.cl2", "Path to the output CL2 image or archive.")
+	flag.StringVar(&palPath, "pal", "", "Path to a PAL file (e.g. town.pal), or a PCX or GIF image whose palette is used.")
+	flag.Parse()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]... dir...\n", os.Args[0])
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	if flag.NArg() < 1 || palPath == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	err := png2cl2(flag.Args())
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// png2cl2 encodes the png images of each directory as a CL2 image, and stores
+// the CL2 image or the CL2 archive of the images at outPath.
+func png2cl2(dirs []string) (err error) {
+	pal, err := cel.GetPal(os.DirFS(filepath.Dir(palPath)), filepath.Base(palPath))
+	if err != nil {
+		return err
+	}
+	conf := &cel.Config{
+		Pal:        pal,
+		HeaderSize: headerSize,
+	}
+	var images [][]byte
+	for _, dir := range dirs {
+		pngPaths, err := filepath.Glob(filepath.Join(dir, "*.png"))
+		if err != nil {
+			return err
+		}
+		if len(pngPaths) == 0 {
+			return fmt.Errorf("no png images in %q.", dir)
+		}
+		var imgs []image.Image
+		for _, pngPath := range pngPaths {
+			img, err := readPNG(pngPath)
+			if err != nil {
+				return err
+			}
+			imgs = append(imgs, img)
+		}
+		buf := new(bytes.Buffer)
+		err = cl2.Encode(buf, imgs, conf)
+		if err != nil {
+			return err
+		}
+		images = append(images, buf.Bytes())
+	}
+	fw, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer fw.Close()
+	if len(images) == 1 {
+		_, err = fw.Write(images[0])
+		return err
+	}
+	return imgarchive.PackCl2(fw, images)
+}
+
+// readPNG reads and decodes the png image located at pngPath.
+func readPNG(pngPath string) (img image.Image, err error) {
+	f, err := os.Open(pngPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err = image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode %q: %v", pngPath, err)
+	}
+	return img, nil
+}
@@ -0,0 +1,215 @@
+// Package font implements the bitmap fonts of Diablo, based on the glyph
+// sheets of PCX images (e.g. ui_art/font42g.pcx) and CEL images (e.g.
+// ctrlpan/smaltext.cel).
+//
+// The glyph sheet of a PCX font contains 256 glyphs, one for each character,
+// stacked vertically. The glyph widths are stored in a BIN file (e.g.
+// ui_art/font42.bin). Below is a description of the BIN format.
+//
+// BIN format:
+//    // spaceWidth is the width of characters without a glyph.
+//    spaceWidth uint8
+//    _          uint8
+//    // widths contains the width of each character; 0 for characters without a
+//    // glyph.
+//    widths     [256]uint8
+//
+// The frames of a CEL font are mapped to characters using the tables of the
+// game (see celFonts).
+package font
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io/fs"
+
+	"github.com/mewrnd/blizzconv/images/cel"
+	"github.com/mewrnd/blizzconv/images/imgconf"
+	"github.com/mewrnd/blizzconv/images/pcx"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// A Face is a bitmap font face. It implements the font.Face interface of
+// golang.org/x/image/font, using the alpha of each glyph as its mask. Use
+// DrawString to draw glyphs using their original colors.
+type Face struct {
+	// glyphs maps from characters to glyph images, or nil for characters
+	// without a glyph.
+	glyphs [256]image.Image
+	// masks maps from characters to the alpha of glyph images.
+	masks [256]*image.Alpha
+	// advances maps from characters to advance widths in pixels.
+	advances [256]int
+	// height is the height of each glyph in pixels.
+	height int
+}
+
+// pcxTransparent is the color index of transparent pixels in the glyph sheets
+// of PCX fonts.
+const pcxTransparent = 32
+
+// LoadPCX loads a font based on the glyph widths of a BIN file and the glyph
+// sheet of a PCX image, located at relBinPath and relPcxPath within fsys.
+func LoadPCX(fsys fs.FS, relBinPath, relPcxPath string) (face *Face, err error) {
+	widths, err := fs.ReadFile(fsys, relBinPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(widths) < 2+256 {
+		return nil, fmt.Errorf("font.LoadPCX: invalid size (%d) of %q", len(widths), relBinPath)
+	}
+	buf, err := fs.ReadFile(fsys, relPcxPath)
+	if err != nil {
+		return nil, err
+	}
+	img, err := pcx.Decode(bytes.NewReader(buf))
+	if err != nil {
+		return nil, fmt.Errorf("font.LoadPCX: unable to decode %q: %v", relPcxPath, err)
+	}
+	sheet := img.(*image.Paletted)
+	bounds := sheet.Bounds()
+	if bounds.Dy()%256 != 0 {
+		return nil, fmt.Errorf("font.LoadPCX: invalid height (%d) of glyph sheet %q", bounds.Dy(), relPcxPath)
+	}
+	pal := make(color.Palette, len(sheet.Palette))
+	copy(pal, sheet.Palette)
+	pal[pcxTransparent] = color.Transparent
+	sheet.Palette = pal
+
+	face = &Face{height: bounds.Dy() / 256}
+	for c := range face.glyphs {
+		w := int(widths[2+c])
+		if w == 0 {
+			face.advances[c] = int(widths[0])
+			continue
+		}
+		if w > bounds.Dx() {
+			w = bounds.Dx()
+		}
+		y := c * face.height
+		face.setGlyph(c, sheet.SubImage(image.Rect(0, y, w, y+face.height)), w)
+	}
+	return face, nil
+}
+
+// LoadCEL loads a font based on the frames of a CEL image, located at
+// relCelPath within fsys. The frames are decoded using the image information of
+// imgConf and the palette located at relPalPath.
+func LoadCEL(fsys fs.FS, imgConf *imgconf.Config, celName, relCelPath, relPalPath string) (face *Face, err error) {
+	celFont, ok := celFonts[celName]
+	if !ok {
+		return nil, fmt.Errorf("font.LoadCEL: no character mapping for %q", celName)
+	}
+	conf, err := cel.GetConf(fsys, imgConf, celName, relPalPath)
+	if err != nil {
+		return nil, err
+	}
+	frames, err := cel.DecodeAll(fsys, relCelPath, conf)
+	if err != nil {
+		return nil, err
+	}
+	face = &Face{height: conf.Height}
+	for c, frameNum := range celFont.frames {
+		advance := int(celFont.widths[frameNum]) + celFont.spacing
+		// Frame numbers start at 1; 0 specifies a space.
+		if frameNum == 0 || int(frameNum) > len(frames) {
+			face.advances[c] = advance
+			continue
+		}
+		face.setGlyph(c, frames[frameNum-1], advance)
+	}
+	return face, nil
+}
+
+// setGlyph sets the glyph image and advance width of the character c.
+func (face *Face) setGlyph(c int, glyph image.Image, advance int) {
+	bounds := glyph.Bounds()
+	mask := image.NewAlpha(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			_, _, _, a := glyph.At(x, y).RGBA()
+			mask.SetAlpha(x-bounds.Min.X, y-bounds.Min.Y, color.Alpha{A: uint8(a >> 8)})
+		}
+	}
+	face.glyphs[c] = glyph
+	face.masks[c] = mask
+	face.advances[c] = advance
+}
+
+// DrawString draws s onto dst using the original colors of the glyphs, and
+// returns the dot of the next character. The dot specifies the left end of the
+// baseline, which is located at the bottom of the glyphs.
+func (face *Face) DrawString(dst draw.Image, dot image.Point, s string) (next image.Point) {
+	for _, r := range s {
+		if r < 0 || r >= 256 {
+			continue
+		}
+		if glyph := face.glyphs[r]; glyph != nil {
+			bounds := glyph.Bounds()
+			dr := image.Rect(dot.X, dot.Y-face.height, dot.X+bounds.Dx(), dot.Y)
+			draw.Draw(dst, dr, glyph, bounds.Min, draw.Over)
+		}
+		dot.X += face.advances[r]
+	}
+	return dot
+}
+
+// Close implements the font.Face interface.
+func (face *Face) Close() error {
+	return nil
+}
+
+// Glyph implements the font.Face interface.
+func (face *Face) Glyph(dot fixed.Point26_6, r rune) (dr image.Rectangle, mask image.Image, maskp image.Point, advance fixed.Int26_6, ok bool) {
+	if r < 0 || r >= 256 {
+		return image.Rectangle{}, nil, image.Point{}, 0, false
+	}
+	advance = fixed.I(face.advances[r])
+	if face.masks[r] == nil {
+		return image.Rectangle{}, image.NewAlpha(image.Rectangle{}), image.Point{}, advance, true
+	}
+	x, y := dot.X.Round(), dot.Y.Round()
+	size := face.masks[r].Bounds().Size()
+	dr = image.Rect(x, y-face.height, x+size.X, y-face.height+size.Y)
+	return dr, face.masks[r], image.Point{}, advance, true
+}
+
+// GlyphBounds implements the font.Face interface.
+func (face *Face) GlyphBounds(r rune) (bounds fixed.Rectangle26_6, advance fixed.Int26_6, ok bool) {
+	if r < 0 || r >= 256 {
+		return fixed.Rectangle26_6{}, 0, false
+	}
+	advance = fixed.I(face.advances[r])
+	if face.masks[r] == nil {
+		return fixed.Rectangle26_6{}, advance, true
+	}
+	size := face.masks[r].Bounds().Size()
+	bounds = fixed.R(0, -face.height, size.X, size.Y-face.height)
+	return bounds, advance, true
+}
+
+// GlyphAdvance implements the font.Face interface.
+func (face *Face) GlyphAdvance(r rune) (advance fixed.Int26_6, ok bool) {
+	if r < 0 || r >= 256 {
+		return 0, false
+	}
+	return fixed.I(face.advances[r]), true
+}
+
+// Kern implements the font.Face interface. The fonts contain no kerning
+// information.
+func (face *Face) Kern(r0, r1 rune) fixed.Int26_6 {
+	return 0
+}
+
+// Metrics implements the font.Face interface.
+func (face *Face) Metrics() font.Metrics {
+	return font.Metrics{
+		Height: fixed.I(face.height),
+		Ascent: fixed.I(face.height),
+	}
+}
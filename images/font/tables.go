@@ -0,0 +1,84 @@
+package font
+
+// A celFont specifies the character mapping of a CEL font.
+type celFont struct {
+	// frames maps from ASCII characters to frame numbers, starting at 1. The
+	// frame number 0 specifies a space.
+	frames [128]uint8
+	// widths maps from frame numbers to glyph widths in pixels.
+	widths []uint8
+	// spacing is the number of pixels between consecutive glyphs.
+	spacing int
+}
+
+// celFonts maps from the names of CEL fonts to their character mappings, as
+// used by the game.
+var celFonts = map[string]*celFont{
+	// Font of the control panel and the automap.
+	"smaltext.cel": {
+		frames: [128]uint8{
+			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			0, 54, 44, 57, 58, 56, 55, 47, 40, 41, 59, 39, 50, 37, 51, 52,
+			36, 27, 28, 29, 30, 31, 32, 33, 34, 35, 48, 49, 60, 38, 61, 53,
+			62, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+			16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 42, 63, 43, 64, 65,
+			0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+			16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 40, 66, 41, 67, 0,
+		},
+		widths: []uint8{
+			8, 10, 7, 9, 8, 7, 6, 8, 8, 3,
+			3, 8, 6, 11, 9, 10, 6, 9, 9, 6,
+			9, 11, 10, 13, 10, 11, 7, 5, 7, 7,
+			8, 7, 7, 7, 7, 7, 10, 4, 5, 6,
+			3, 3, 4, 3, 6, 6, 3, 3, 3, 3,
+			3, 2, 7, 6, 3, 10, 10, 6, 6, 7,
+			4, 4, 9, 6, 6, 12, 3, 7,
+		},
+		spacing: 1,
+	},
+	// Font of quest dialogs.
+	"medtexts.cel": {
+		frames: [128]uint8{
+			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			0, 37, 49, 38, 0, 39, 40, 47, 42, 43, 41, 45, 52, 44, 53, 55,
+			36, 27, 28, 29, 30, 31, 32, 33, 34, 35, 51, 50, 48, 46, 49, 54,
+			0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+			16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 42, 0, 43, 0, 0,
+			0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+			16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 48, 0, 49, 0, 0,
+		},
+		widths: []uint8{
+			5, 15, 10, 13, 14, 10, 9, 13, 11, 5,
+			5, 11, 10, 16, 13, 16, 10, 15, 12, 10,
+			14, 17, 17, 22, 17, 16, 11, 5, 11, 11,
+			11, 10, 11, 11, 11, 11, 15, 5, 10, 18,
+			15, 8, 6, 6, 7, 10, 9, 6, 10, 10,
+			5, 5, 5, 5, 11, 12,
+		},
+		spacing: 2,
+	},
+	// Font of the game menu.
+	"bigtgold.cel": {
+		frames: [128]uint8{
+			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			0, 37, 49, 38, 0, 39, 40, 47, 42, 43, 41, 45, 52, 44, 53, 55,
+			36, 27, 28, 29, 30, 31, 32, 33, 34, 35, 51, 50, 0, 46, 0, 54,
+			0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+			16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 42, 0, 43, 0, 0,
+			0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+			16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 0, 0, 0, 0, 0,
+		},
+		widths: []uint8{
+			18, 33, 21, 26, 28, 19, 19, 26, 25, 11,
+			12, 25, 19, 34, 28, 32, 20, 32, 28, 20,
+			28, 36, 35, 46, 33, 33, 24, 11, 23, 22,
+			22, 21, 22, 21, 21, 21, 32, 10, 20, 36,
+			31, 17, 13, 12, 13, 18, 16, 11, 20, 21,
+			11, 10, 12, 11, 21, 23,
+		},
+		spacing: 2,
+	},
+}
@@ -3,7 +3,6 @@ package imgarchive
 import (
 	"encoding/binary"
 	"io"
-	"os"
 )
 
 // ExtractCel extracts CEL images, based on the CEL archive format described
@@ -18,7 +17,7 @@ import (
 //    // Note: the last image has only an implicit end offset, which is the end of the file.
 //    data           []byte
 //
-func ExtractCel(r *os.File, ws []*os.File) (err error) {
+func ExtractCel(r io.Reader, ws []io.Writer) (err error) {
 	imageCount := len(ws)
 	imageOffsets := make([]uint32, imageCount)
 	err = binary.Read(r, binary.LittleEndian, imageOffsets)
@@ -19,7 +19,7 @@ import (
 //    //    end:   headerOffsets[imageNum] + frameOffsets[frameCount]
 //    // Note: Both frameOffsets and frameCount are located in cl2Headers[imageNum].
 //    data           []byte
-func ExtractCl2(r *os.File, ws []*os.File) (err error) {
+func ExtractCl2(r io.ReadSeeker, ws []io.Writer) (err error) {
 	imageCount := len(ws)
 	headerOffsets := make([]uint32, imageCount)
 	err = binary.Read(r, binary.LittleEndian, headerOffsets)
@@ -67,3 +67,27 @@ func ExtractCl2(r *os.File, ws []*os.File) (err error) {
 	}
 	return nil
 }
+
+// PackCl2 packs CL2 images into a CL2 archive, based on the CL2 archive format
+// described above; e.g. the images of each direction of a monster or player
+// animation. Each CL2 image is stored as is, since the frame offsets of the CL2
+// header are relative to the start of the header.
+func PackCl2(w io.Writer, images [][]byte) (err error) {
+	headerOffsets := make([]uint32, len(images))
+	offset := uint32(4 * len(images))
+	for imageNum, image := range images {
+		headerOffsets[imageNum] = offset
+		offset += uint32(len(image))
+	}
+	err = binary.Write(w, binary.LittleEndian, headerOffsets)
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		_, err = w.Write(image)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package imgarchive
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+
+	"github.com/mewrnd/blizzconv/images/cel"
+	"github.com/mewrnd/blizzconv/images/cl2"
+)
+
+func TestPackCl2(t *testing.T) {
+	// Encode a CL2 image of two frames for each of the 8 directions.
+	pal := make(color.Palette, 256)
+	for i := range pal {
+		pal[i] = color.RGBA{R: uint8(i), A: 0xFF}
+	}
+	conf := &cel.Config{Width: 20, Height: 40, Pal: pal, HeaderSize: 10}
+	var images [][]byte
+	for dir := 0; dir < 8; dir++ {
+		var imgs []image.Image
+		for frameNum := 0; frameNum < 2; frameNum++ {
+			img := image.NewPaletted(image.Rect(0, 0, 20, 40), pal)
+			for y := 0; y < 40; y++ {
+				for x := dir; x < 20; x++ {
+					img.SetColorIndex(x, y, uint8(x*y+frameNum))
+				}
+			}
+			imgs = append(imgs, img)
+		}
+		buf := new(bytes.Buffer)
+		if err := cl2.Encode(buf, imgs, conf); err != nil {
+			t.Fatal(err)
+		}
+		images = append(images, buf.Bytes())
+	}
+
+	// Extracting the packed CL2 archive yields the original CL2 images.
+	archive := new(bytes.Buffer)
+	if err := PackCl2(archive, images); err != nil {
+		t.Fatal(err)
+	}
+	ws := make([]io.Writer, len(images))
+	bufs := make([]*bytes.Buffer, len(images))
+	for i := range ws {
+		bufs[i] = new(bytes.Buffer)
+		ws[i] = bufs[i]
+	}
+	if err := ExtractCl2(bytes.NewReader(archive.Bytes()), ws); err != nil {
+		t.Fatal(err)
+	}
+	for dir := range images {
+		if !bytes.Equal(bufs[dir].Bytes(), images[dir]) {
+			t.Errorf("CL2 image mismatch of direction %d", dir)
+		}
+	}
+}
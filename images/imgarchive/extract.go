@@ -1,8 +1,11 @@
-// Package imgarchive implements support for extracting CEL and CL2 archives.
+// Package imgarchive implements support for extracting CEL and CL2 archives,
+// and for packing CL2 archives.
 package imgarchive
 
 import (
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path"
 	"strings"
@@ -11,32 +14,37 @@ import (
 	"github.com/mewrnd/blizzconv/mpq"
 )
 
-// Extract extracts CEL and CL2 archives.
-func Extract(archiveName string) (err error) {
-	imageCount, found := imgconf.GetImageCount(archiveName)
+// Extract extracts CEL and CL2 archives. The archive is located at
+// relArchivePath within fsys, and the extracted images are stored at the same
+// relative path within dstDir. The number of archived images is provided by
+// imgConf.
+func Extract(fsys fs.FS, imgConf *imgconf.Config, relArchivePath, dstDir string) (err error) {
+	archiveName := path.Base(relArchivePath)
+	imageCount, found := imgConf.GetImageCount(archiveName)
 	if !found {
 		return fmt.Errorf("no archived images in %q.", archiveName)
 	}
-	archivePath, err := mpq.GetPath(archiveName)
-	if err != nil {
-		return err
-	}
-	fr, err := os.Open(archivePath)
+	fr, err := mpq.OpenFile(fsys, relArchivePath)
 	if err != nil {
 		return err
 	}
 	defer fr.Close()
+	archivePath := path.Join(dstDir, relArchivePath)
 	fws, err := createOutputImages(archivePath, imageCount)
 	if err != nil {
 		return err
 	}
 	defer closeFiles(fws)
+	ws := make([]io.Writer, len(fws))
+	for i, fw := range fws {
+		ws[i] = fw
+	}
 	ext := path.Ext(archiveName)
 	switch ext {
 	case ".cel":
-		err = ExtractCel(fr, fws)
+		err = ExtractCel(fr, ws)
 	case ".cl2":
-		err = ExtractCl2(fr, fws)
+		err = ExtractCl2(fr, ws)
 	default:
 		return fmt.Errorf("imgarchive.Extract: unknown extension: %q.", ext)
 	}
@@ -53,6 +61,11 @@ func createOutputImages(archivePath string, imageCount int) (fws []*os.File, err
 	if posExt == -1 {
 		return nil, fmt.Errorf("no extensions located for %q.", path.Base(archivePath))
 	}
+	// The directory may not exist when reading directly from an MPQ archive.
+	err = os.MkdirAll(path.Dir(archivePath), 0755)
+	if err != nil {
+		return nil, err
+	}
 	for imageNum := 0; imageNum < imageCount; imageNum++ {
 		imgPath := fmt.Sprintf("%s%d%s", archivePath[:posExt], imageNum, archivePath[posExt:])
 		w, err := os.Create(imgPath)
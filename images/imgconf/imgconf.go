@@ -7,6 +7,9 @@ package imgconf
 
 import (
 	"fmt"
+	"image"
+	"io/fs"
+	"path"
 	"sort"
 	"strconv"
 	"strings"
@@ -14,35 +17,35 @@ import (
 	"github.com/mewbak/goini"
 )
 
-// IniPath is the path to the 'cel.ini' or 'cl2.ini' file which provides CEL and
-// CL2 image information.
-var IniPath string
-
-var dict ini.Dict
+// A Config provides CEL and CL2 image information.
+type Config struct {
+	dict ini.Dict
+}
 
-// Init loads an ini file which provides CEL and CL2 image information.
-func Init() (err error) {
-	dict, err = ini.Load(IniPath)
+// Load loads the 'cel.ini' or 'cl2.ini' file located at iniPath, which
+// provides CEL and CL2 image information.
+func Load(iniPath string) (conf *Config, err error) {
+	dict, err := ini.Load(iniPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return &Config{dict: dict}, nil
 }
 
 // Len returns the number of images in the ini file.
-func Len() int {
-	_, ok := dict[""]
+func (conf *Config) Len() int {
+	_, ok := conf.dict[""]
 	if ok {
-		return len(dict) - 1
+		return len(conf.dict) - 1
 	}
-	return len(dict)
+	return len(conf.dict)
 }
 
 // AllFunc calls the function f with the parameter imgName once for each image
 // in the ini file.
-func AllFunc(f func(string) error) (err error) {
+func (conf *Config) AllFunc(f func(string) error) (err error) {
 	var imgNames []string
-	for imgName := range dict {
+	for imgName := range conf.dict {
 		if imgName == "" {
 			continue
 		}
@@ -59,8 +62,8 @@ func AllFunc(f func(string) error) (err error) {
 }
 
 // GetWidth returns the image width.
-func GetWidth(imgName string) (width int, err error) {
-	width, found := dict.GetInt(imgName, "width")
+func (conf *Config) GetWidth(imgName string) (width int, err error) {
+	width, found := conf.dict.GetInt(imgName, "width")
 	if !found {
 		return 0, fmt.Errorf("width not found for %q.", imgName)
 	}
@@ -68,8 +71,8 @@ func GetWidth(imgName string) (width int, err error) {
 }
 
 // GetHeight returns the image height.
-func GetHeight(imgName string) (height int, err error) {
-	height, found := dict.GetInt(imgName, "height")
+func (conf *Config) GetHeight(imgName string) (height int, err error) {
+	height, found := conf.dict.GetInt(imgName, "height")
 	if !found {
 		return 0, fmt.Errorf("height not found for %q.", imgName)
 	}
@@ -77,8 +80,8 @@ func GetHeight(imgName string) (height int, err error) {
 }
 
 // GetRelPalPaths returns the relative paths to the image palettes.
-func GetRelPalPaths(imgName string) (relPalPaths []string) {
-	rawRelPalPaths, found := dict.GetString(imgName, "pals")
+func (conf *Config) GetRelPalPaths(imgName string) (relPalPaths []string) {
+	rawRelPalPaths, found := conf.dict.GetString(imgName, "pals")
 	if !found {
 		// Default pal path:
 		//    'levels/towndata/town.pal'
@@ -87,19 +90,64 @@ func GetRelPalPaths(imgName string) (relPalPaths []string) {
 	return strings.Split(rawRelPalPaths, ",")
 }
 
+// GetRelPalPathsFor returns the relative paths to the palettes of the image
+// located at relImgPath within fsys. Images without explicit palettes are
+// paired with the palette of the same name and directory if present (e.g.
+// 'gendata/cutl1d.pal' for 'gendata/cutl1d.cel'), and otherwise use the default
+// palette.
+func (conf *Config) GetRelPalPathsFor(fsys fs.FS, imgName, relImgPath string) (relPalPaths []string) {
+	_, found := conf.dict.GetString(imgName, "pals")
+	if !found {
+		relPalPath := relImgPath[:len(relImgPath)-len(path.Ext(relImgPath))] + ".pal"
+		_, err := fs.Stat(fsys, relPalPath)
+		if err == nil {
+			return []string{relPalPath}
+		}
+	}
+	return conf.GetRelPalPaths(imgName)
+}
+
 // GetRelTrnPaths returns the relative paths to the image color transition
 // files.
-func GetRelTrnPaths(imgName string) (relTrnPaths []string) {
-	rawRelTrnPaths, found := dict.GetString(imgName, "trns")
+func (conf *Config) GetRelTrnPaths(imgName string) (relTrnPaths []string) {
+	rawRelTrnPaths, found := conf.dict.GetString(imgName, "trns")
 	if !found {
 		return nil
 	}
 	return strings.Split(rawRelTrnPaths, ",")
 }
 
+// GetProgressBar returns the location of the progress bar drawn onto the
+// loading screen, and the palette index of its color. Below is an example
+// progress_bar entry, which specifies the x, y, width and height of the bar:
+//    progress_bar=53,37,534,22
+func (conf *Config) GetProgressBar(imgName string) (rect image.Rectangle, colorIndex int, found bool, err error) {
+	rawProgressBar, found := conf.dict.GetString(imgName, "progress_bar")
+	if !found {
+		return image.Rectangle{}, 0, false, nil
+	}
+	rawVals := strings.Split(rawProgressBar, ",")
+	if len(rawVals) != 4 {
+		return image.Rectangle{}, 0, false, fmt.Errorf("invalid progress_bar %q for %q.", rawProgressBar, imgName)
+	}
+	var vals [4]int
+	for i, rawVal := range rawVals {
+		vals[i], err = strconv.Atoi(strings.TrimSpace(rawVal))
+		if err != nil {
+			return image.Rectangle{}, 0, false, err
+		}
+	}
+	colorIndex, ok := conf.dict.GetInt(imgName, "progress_color")
+	if !ok {
+		return image.Rectangle{}, 0, false, fmt.Errorf("progress_color not found for %q.", imgName)
+	}
+	rect = image.Rect(vals[0], vals[1], vals[0]+vals[2], vals[1]+vals[3])
+	return rect, colorIndex, true, nil
+}
+
 // GetHeaderSize returns the header size of the image.
-func GetHeaderSize(imgName string) (headerSize int) {
-	headerSize, found := dict.GetInt(imgName, "header_size")
+func (conf *Config) GetHeaderSize(imgName string) (headerSize int) {
+	headerSize, found := conf.dict.GetInt(imgName, "header_size")
 	if !found {
 		return 0
 	}
@@ -107,8 +155,8 @@ func GetHeaderSize(imgName string) (headerSize int) {
 }
 
 // GetImageCount returns the number of archived images within the archive.
-func GetImageCount(imgName string) (imageCount int, found bool) {
-	imageCount, found = dict.GetInt(imgName, "image_count")
+func (conf *Config) GetImageCount(imgName string) (imageCount int, found bool) {
+	imageCount, found = conf.dict.GetInt(imgName, "image_count")
 	if !found {
 		return 0, false
 	}
@@ -117,8 +165,8 @@ func GetImageCount(imgName string) (imageCount int, found bool) {
 
 // GetFrameWidth returns the width of the image's frames as a map from frameNum
 // (key) to frameWidth (val).
-func GetFrameWidth(imgName string) (frameWidth map[int]int, err error) {
-	rawFrameWidths, found := dict.GetString(imgName, "frame_widths")
+func (conf *Config) GetFrameWidth(imgName string) (frameWidth map[int]int, err error) {
+	rawFrameWidths, found := conf.dict.GetString(imgName, "frame_widths")
 	if !found {
 		return nil, nil
 	}
@@ -127,8 +175,8 @@ func GetFrameWidth(imgName string) (frameWidth map[int]int, err error) {
 
 // GetFrameHeight returns the height of the image's frames as a map from
 // frameNum (key) to frameHeight (val).
-func GetFrameHeight(imgName string) (frameHeight map[int]int, err error) {
-	rawFrameHeights, found := dict.GetString(imgName, "frame_heights")
+func (conf *Config) GetFrameHeight(imgName string) (frameHeight map[int]int, err error) {
+	rawFrameHeights, found := conf.dict.GetString(imgName, "frame_heights")
 	if !found {
 		return nil, nil
 	}
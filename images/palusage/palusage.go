@@ -0,0 +1,218 @@
+// Package palusage implements palette-index usage analysis of CEL and CL2
+// images, which is used to suggest the palettes of images.
+//
+// Neither the CEL nor the CL2 image format specifies the palette of an image.
+// The palette indices used by the frames of an image are therefore compared
+// against the reserved ranges of each palette; the level-specific colors (the
+// indices whose color differs from the color of most other palettes) and the
+// color cycling ranges. An image which uses the level-specific colors of a
+// palette is most plausibly colored by that palette, while an image which only
+// uses the colors shared by all palettes may be colored by any palette.
+package palusage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mewrnd/blizzconv/images/cel"
+	"github.com/mewrnd/blizzconv/images/cl2"
+	"github.com/mewrnd/blizzconv/mpq"
+)
+
+// Usage records the number of pixels of each palette index used by the frames
+// of an image.
+type Usage [256]int
+
+// GetUsage returns the palette-index usage of the frames of the CEL or CL2
+// image located at relImgPath within fsys, based on a given conf.
+func GetUsage(fsys fs.FS, relImgPath string, conf *cel.Config) (usage *Usage, err error) {
+	f, err := mpq.OpenFile(fsys, relImgPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var d frameDecoder
+	if path.Ext(relImgPath) == ".cl2" {
+		d, err = cl2.NewDecoder(f, path.Base(relImgPath), conf)
+	} else {
+		d, err = cel.NewDecoder(f, path.Base(relImgPath), conf)
+	}
+	if err != nil {
+		return nil, err
+	}
+	usage = new(Usage)
+	for frameNum := 0; frameNum < d.FrameCount(); frameNum++ {
+		img, err := d.DecodeFrame(frameNum)
+		if err != nil {
+			return nil, err
+		}
+		// The decoded frames are paletted, and transparent pixels either use a
+		// reserved palette index whose color is transparent, or a mask.
+		var p *image.Paletted
+		var mask []bool
+		switch img := img.(type) {
+		case *image.Paletted:
+			p = img
+		case *cel.MaskedPaletted:
+			p, mask = img.Paletted, img.Mask
+		default:
+			return nil, fmt.Errorf("palusage.GetUsage: unsupported frame image type %T of %q", img, relImgPath)
+		}
+		for i, index := range p.Pix {
+			if mask != nil && !mask[i] {
+				continue
+			}
+			if _, _, _, a := p.Palette[index].RGBA(); a == 0 {
+				continue
+			}
+			usage[index]++
+		}
+	}
+	return usage, nil
+}
+
+// A frameDecoder provides random access to the frames of a CEL or CL2 image.
+type frameDecoder interface {
+	// FrameCount returns the number of frames of the image.
+	FrameCount() int
+	// DecodeFrame reads and decodes the given frame.
+	DecodeFrame(frameNum int) (image.Image, error)
+}
+
+// CyclingRanges maps from palette directories to the inclusive range of
+// palette indices which are cycled by the game, to animate lava and water.
+var CyclingRanges = map[string][2]int{
+	"levels/l3data/": {1, 31},
+}
+
+// A Palette is a candidate palette of the analysis, and its reserved ranges.
+type Palette struct {
+	// RelPath is the relative path to the palette.
+	RelPath string
+	// specific reports whether the color of each palette index is
+	// level-specific.
+	specific [256]bool
+	// blank reports whether each palette index is unused (black), while most
+	// other palettes use it.
+	blank [256]bool
+	// cycling reports whether each palette index is cycled by the game.
+	cycling [256]bool
+}
+
+// An Analyzer suggests the palettes of images based on their palette-index
+// usage.
+type Analyzer struct {
+	// pals contains the candidate palettes, sorted by relative path.
+	pals []*Palette
+}
+
+// NewAnalyzer returns an analyzer of the candidate palettes located at
+// relPalPaths within fsys; the reserved ranges of each palette are determined
+// by comparing it against the other palettes.
+func NewAnalyzer(fsys fs.FS, relPalPaths []string) (a *Analyzer, err error) {
+	if len(relPalPaths) < 1 {
+		return nil, fmt.Errorf("palusage.NewAnalyzer: no palettes")
+	}
+	relPalPaths = append([]string(nil), relPalPaths...)
+	sort.Strings(relPalPaths)
+	pals := make([]color.Palette, len(relPalPaths))
+	for i, relPalPath := range relPalPaths {
+		pals[i], err = cel.GetPal(fsys, relPalPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	a = new(Analyzer)
+	black := color.RGBA{A: 0xFF}
+	for i, relPalPath := range relPalPaths {
+		p := &Palette{RelPath: relPalPath}
+		for index := 0; index < 256; index++ {
+			c := pals[i][index]
+			common := commonColor(pals, index)
+			p.specific[index] = c != common
+			p.blank[index] = c == black && common != black
+		}
+		for dir, r := range CyclingRanges {
+			if !strings.HasPrefix(relPalPath, dir) {
+				continue
+			}
+			for index := r[0]; index <= r[1]; index++ {
+				p.cycling[index] = true
+			}
+		}
+		a.pals = append(a.pals, p)
+	}
+	return a, nil
+}
+
+// commonColor returns the most common color of the given palette index across
+// the palettes.
+func commonColor(pals []color.Palette, index int) color.Color {
+	counts := make(map[color.Color]int)
+	var common color.Color
+	for _, pal := range pals {
+		c := pal[index]
+		counts[c]++
+		if common == nil || counts[c] > counts[common] {
+			common = c
+		}
+	}
+	return common
+}
+
+// Score returns the plausibility of the palette for an image of the given
+// palette-index usage. Each pixel of a level-specific color or a cycled color
+// of the palette adds one to the score, and each pixel of an unused color of
+// the palette subtracts one.
+func (p *Palette) Score(usage *Usage) (score int) {
+	for index, n := range usage {
+		if p.specific[index] || p.cycling[index] {
+			score += n
+		}
+		if p.blank[index] {
+			score -= n
+		}
+	}
+	return score
+}
+
+// Suggest returns the most plausible palettes for the image located at
+// relImgPath, based on its palette-index usage; all palettes of the highest
+// score are returned, and if any of those palettes are located in the
+// directory of the image, only those are returned. No palettes are returned if
+// no palette has a positive score, e.g. for images which only use the colors
+// shared by all palettes.
+func (a *Analyzer) Suggest(relImgPath string, usage *Usage) (relPalPaths []string, score int) {
+	var best []*Palette
+	for _, p := range a.pals {
+		s := p.Score(usage)
+		switch {
+		case best == nil || s > score:
+			best = []*Palette{p}
+			score = s
+		case s == score:
+			best = append(best, p)
+		}
+	}
+	if score <= 0 {
+		return nil, score
+	}
+	imgDir := path.Dir(relImgPath)
+	for _, p := range best {
+		if path.Dir(p.RelPath) == imgDir {
+			relPalPaths = append(relPalPaths, p.RelPath)
+		}
+	}
+	if len(relPalPaths) > 0 {
+		return relPalPaths, score
+	}
+	for _, p := range best {
+		relPalPaths = append(relPalPaths, p.RelPath)
+	}
+	return relPalPaths, score
+}
@@ -0,0 +1,178 @@
+// Package pcx implements a decoder for 8-bit paletted PCX images.
+//
+// The decoder is registered for use by image.Decode. Below is a description of
+// the PCX image format, as used by the menu art of Diablo. All integers are
+// stored in little endian.
+//
+// PCX format:
+//    header  Header
+//    // data contains the run-length encoded pixels of each line.
+//    data    []byte
+//    // magic is 0x0C.
+//    magic   uint8
+//    pal     [256]Color
+//
+// Color format:
+//    r byte   // red
+//    g byte   // green
+//    b byte   // blue
+//
+// Pixels are run-length encoded; a byte with the two most significant bits set
+// specifies that the next byte is repeated (b & 0x3F) times, and any other
+// byte is a single pixel.
+package pcx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+)
+
+func init() {
+	image.RegisterFormat("pcx", "\x0A?\x01\x08", Decode, DecodeConfig)
+}
+
+// Header is the header of a PCX image.
+type Header struct {
+	// Manufacturer is 0x0A.
+	Manufacturer uint8
+	Version      uint8
+	// Encoding is 1 (run-length encoding).
+	Encoding     uint8
+	BitsPerPixel uint8
+	XMin         uint16
+	YMin         uint16
+	XMax         uint16
+	YMax         uint16
+	HDPI         uint16
+	VDPI         uint16
+	// ColorMap contains the 16-color palette of 4-bit images.
+	ColorMap     [48]byte
+	_            uint8
+	Planes       uint8
+	BytesPerLine uint16
+	PaletteInfo  uint16
+	HScreenSize  uint16
+	VScreenSize  uint16
+	_            [54]byte
+}
+
+// headerSize is the size of the header in bytes.
+const headerSize = 128
+
+// palSize is the size of the trailing palette in bytes, including its magic.
+const palSize = 1 + 256*3
+
+// Decode decodes a PCX image from r and returns it as an *image.Paletted.
+func Decode(r io.Reader) (img image.Image, err error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	hdr, pal, err := parse(buf)
+	if err != nil {
+		return nil, err
+	}
+	w, h := int(hdr.XMax)-int(hdr.XMin)+1, int(hdr.YMax)-int(hdr.YMin)+1
+	data := buf[headerSize : len(buf)-palSize]
+	lineSize := int(hdr.BytesPerLine)
+
+	// Decode the run-length encoded lines, which may span line boundaries.
+	pix := make([]uint8, lineSize*h)
+	for i := 0; i < len(pix); {
+		if len(data) < 1 {
+			return nil, errors.New("pcx.Decode: pixel data truncated")
+		}
+		b := data[0]
+		data = data[1:]
+		n := 1
+		if b&0xC0 == 0xC0 {
+			if len(data) < 1 {
+				return nil, errors.New("pcx.Decode: pixel data truncated")
+			}
+			n = int(b & 0x3F)
+			b = data[0]
+			data = data[1:]
+		}
+		for ; n > 0 && i < len(pix); n-- {
+			pix[i] = b
+			i++
+		}
+	}
+	dst := image.NewPaletted(image.Rect(0, 0, w, h), pal)
+	for y := 0; y < h; y++ {
+		copy(dst.Pix[y*dst.Stride:y*dst.Stride+w], pix[y*lineSize:])
+	}
+	return dst, nil
+}
+
+// DecodeConfig returns the color model and dimensions of a PCX image without
+// decoding the entire image.
+func DecodeConfig(r io.Reader) (config image.Config, err error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	hdr, pal, err := parse(buf)
+	if err != nil {
+		return image.Config{}, err
+	}
+	config = image.Config{
+		ColorModel: pal,
+		Width:      int(hdr.XMax) - int(hdr.XMin) + 1,
+		Height:     int(hdr.YMax) - int(hdr.YMin) + 1,
+	}
+	return config, nil
+}
+
+// DecodePal decodes the palette of a PCX image from r.
+func DecodePal(r io.Reader) (pal color.Palette, err error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	_, pal, err = parse(buf)
+	return pal, err
+}
+
+// parse parses the header and trailing palette of the PCX image contained
+// within buf.
+func parse(buf []byte) (hdr *Header, pal color.Palette, err error) {
+	if len(buf) < headerSize+palSize {
+		return nil, nil, errors.New("pcx: image truncated")
+	}
+	hdr = new(Header)
+	err = binary.Read(bytes.NewReader(buf), binary.LittleEndian, hdr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if hdr.Manufacturer != 0x0A || hdr.Encoding != 1 {
+		return nil, nil, errors.New("pcx: invalid header")
+	}
+	if hdr.BitsPerPixel != 8 || hdr.Planes != 1 {
+		return nil, nil, fmt.Errorf("pcx: unsupported format; %d bits per pixel and %d planes", hdr.BitsPerPixel, hdr.Planes)
+	}
+	if hdr.XMax < hdr.XMin || hdr.YMax < hdr.YMin || int(hdr.BytesPerLine) < int(hdr.XMax)-int(hdr.XMin)+1 {
+		return nil, nil, errors.New("pcx: invalid dimensions")
+	}
+	data := buf[len(buf)-palSize:]
+	if data[0] != 0x0C {
+		return nil, nil, errors.New("pcx: palette missing")
+	}
+	data = data[1:]
+	pal = make(color.Palette, 256)
+	for i := range pal {
+		pal[i] = color.RGBA{
+			R: data[3*i],
+			G: data[3*i+1],
+			B: data[3*i+2],
+			A: 0xFF,
+		}
+	}
+	return hdr, pal, nil
+}
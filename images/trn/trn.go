@@ -12,18 +12,14 @@ package trn
 import (
 	"fmt"
 	"image/color"
-	"io/ioutil"
-
-	"github.com/mewrnd/blizzconv/mpq"
+	"io/fs"
 )
 
 // ConvertPal converts the src palette based on the provided TRN file and
-// returns it as a color.Palette.
-//
-// Note: The absolute path of relTrnPath is relative to mpq.ExtractPath.
-func ConvertPal(src color.Palette, relTrnPath string) (dst color.Palette, err error) {
-	trnPath := mpq.AbsPath(relTrnPath)
-	trn, err := ioutil.ReadFile(trnPath)
+// returns it as a color.Palette. The TRN file is located at relTrnPath within
+// fsys.
+func ConvertPal(fsys fs.FS, src color.Palette, relTrnPath string) (dst color.Palette, err error) {
+	trn, err := fs.ReadFile(fsys, relTrnPath)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,121 @@
+package mpq
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// The ADPCM compression of Storm encodes 16-bit PCM samples, one byte per
+// sample. Below is a description of the format of ADPCM compressed sectors.
+//
+// ADPCM format:
+//    _        uint8
+//    bitShift uint8
+//    // initialSamples contains the first sample of each channel.
+//    initialSamples [channelCount]int16
+//    // samples contains the encoded samples, which alternate between
+//    // channels. The values 0x80 through 0xFF are control values, which
+//    // adjust the step index (ref: decodeADPCM).
+//    samples []uint8
+
+// adpcmInitStepIndex is the initial index into adpcmStepSizes of each channel.
+const adpcmInitStepIndex = 0x2C
+
+// adpcmStepSizes contains the step sizes of the ADPCM compression.
+var adpcmStepSizes = [89]int{
+	7, 8, 9, 10, 11, 12, 13, 14, 16, 17, 19, 21, 23, 25, 28, 31, 34, 37, 41, 45,
+	50, 55, 60, 66, 73, 80, 88, 97, 107, 118, 130, 143, 157, 173, 190, 209, 230,
+	253, 279, 307, 337, 371, 408, 449, 494, 544, 598, 658, 724, 796, 876, 963,
+	1060, 1166, 1282, 1411, 1552, 1707, 1878, 2066, 2272, 2499, 2749, 3024, 3327,
+	3660, 4026, 4428, 4871, 5358, 5894, 6484, 7132, 7845, 8630, 9493, 10442,
+	11487, 12635, 13899, 15289, 16818, 18500, 20350, 22385, 24623, 27086, 29794,
+	32767,
+}
+
+// adpcmStepIndexDeltas maps from the lower five bits of encoded samples to step
+// index adjustments.
+var adpcmStepIndexDeltas = [32]int{
+	-1, 0, -1, 4, -1, 2, -1, 6, -1, 1, -1, 5, -1, 3, -1, 7,
+	-1, 1, -1, 5, -1, 3, -1, 7, -1, 2, -1, 4, -1, 6, -1, 8,
+}
+
+// decodeADPCM decodes the ADPCM compressed data of a sector with the given
+// number of channels, and returns the 16-bit PCM samples in little endian.
+func decodeADPCM(data []byte, channelCount int) (buf []byte, err error) {
+	if len(data) < 2+2*channelCount {
+		return nil, errors.New("ADPCM header truncated")
+	}
+	bitShift := uint(data[1])
+	data = data[2:]
+	var samples, stepIndexes [2]int
+	buf = make([]byte, 0, 2*(len(data)+channelCount))
+	putSample := func(sample int) {
+		buf = append(buf, byte(sample), byte(sample>>8))
+	}
+	for i := 0; i < channelCount; i++ {
+		samples[i] = int(int16(binary.LittleEndian.Uint16(data)))
+		stepIndexes[i] = adpcmInitStepIndex
+		putSample(samples[i])
+		data = data[2:]
+	}
+	channel := channelCount - 1
+	for _, enc := range data {
+		channel = (channel + 1) % channelCount
+		switch {
+		case enc == 0x80:
+			// Repeat the previous sample using a smaller step size.
+			if stepIndexes[channel] > 0 {
+				stepIndexes[channel]--
+			}
+			putSample(samples[channel])
+		case enc == 0x81:
+			// Increase the step size, and keep using the same channel for the
+			// next sample.
+			stepIndexes[channel] += 8
+			if stepIndexes[channel] > len(adpcmStepSizes)-1 {
+				stepIndexes[channel] = len(adpcmStepSizes) - 1
+			}
+			channel = (channel + 1) % channelCount
+		case enc == 0x82:
+			// No operation.
+		case enc > 0x82:
+			// Decrease the step size, and keep using the same channel for the
+			// next sample.
+			stepIndexes[channel] -= 8
+			if stepIndexes[channel] < 0 {
+				stepIndexes[channel] = 0
+			}
+			channel = (channel + 1) % channelCount
+		default:
+			stepSize := adpcmStepSizes[stepIndexes[channel]]
+			diff := stepSize >> bitShift
+			for bit := uint(0); bit < 6; bit++ {
+				if enc&(1<<bit) != 0 {
+					diff += stepSize >> bit
+				}
+			}
+			sample := samples[channel]
+			if enc&0x40 != 0 {
+				sample -= diff
+				if sample < -32768 {
+					sample = -32768
+				}
+			} else {
+				sample += diff
+				if sample > 32767 {
+					sample = 32767
+				}
+			}
+			samples[channel] = sample
+			putSample(sample)
+			stepIndex := stepIndexes[channel] + adpcmStepIndexDeltas[enc&0x1F]
+			if stepIndex < 0 {
+				stepIndex = 0
+			} else if stepIndex > len(adpcmStepSizes)-1 {
+				stepIndex = len(adpcmStepSizes) - 1
+			}
+			stepIndexes[channel] = stepIndex
+		}
+	}
+	return buf, nil
+}
@@ -0,0 +1,32 @@
+package mpq
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeADPCMControl(t *testing.T) {
+	// Mono data with an initial sample of 1000, followed by: a sample code
+	// with the initial step index, the no operation 0x82, a step index decrease
+	// of 8 (0x83), and a sample code using the decreased step index.
+	data := []byte{0, 0, 0xE8, 0x03, 0x00, 0x82, 0x83, 0x00}
+	buf, err := decodeADPCM(data, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var samples []int
+	for i := 0; i+1 < len(buf); i += 2 {
+		samples = append(samples, int(int16(binary.LittleEndian.Uint16(buf[i:]))))
+	}
+	// Step index 0x2C (step 494) adds 494; the delta of code 0 lowers the step
+	// index to 0x2B, and 0x83 lowers it to 0x23 (step 209).
+	want := []int{1000, 1494, 1703}
+	if len(samples) != len(want) {
+		t.Fatalf("samples mismatch; expected %v, got %v", want, samples)
+	}
+	for i := range want {
+		if samples[i] != want[i] {
+			t.Fatalf("samples mismatch; expected %v, got %v", want, samples)
+		}
+	}
+}
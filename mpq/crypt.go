@@ -0,0 +1,109 @@
+package mpq
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// Hash types used by hashString.
+const (
+	// hashTableIndex is used to locate the starting position of a file in the
+	// hash table.
+	hashTableIndex = 0
+	// hashNameA and hashNameB are used to verify the file name of a hash entry.
+	hashNameA = 1
+	hashNameB = 2
+	// hashFileKey is used to derive encryption keys.
+	hashFileKey = 3
+)
+
+// cryptTable is the 0x500 entry table used by both the hash and the encryption
+// algorithms of the MPQ format.
+var cryptTable = genCryptTable()
+
+// genCryptTable generates the contents of cryptTable.
+func genCryptTable() (table [0x500]uint32) {
+	seed := uint32(0x00100001)
+	for i := 0; i < 0x100; i++ {
+		for j := i; j < 0x500; j += 0x100 {
+			seed = (seed*125 + 3) % 0x2AAAAB
+			hi := (seed & 0xFFFF) << 16
+			seed = (seed*125 + 3) % 0x2AAAAB
+			lo := seed & 0xFFFF
+			table[j] = hi | lo
+		}
+	}
+	return table
+}
+
+// hashString returns the hash of s using the given hash type. The hash is case
+// insensitive and treats '/' and '\' as equal.
+func hashString(s string, hashType uint32) uint32 {
+	seed1 := uint32(0x7FED7FED)
+	seed2 := uint32(0xEEEEEEEE)
+	for i := 0; i < len(s); i++ {
+		c := uint32(upper(s[i]))
+		seed1 = cryptTable[hashType*0x100+c] ^ (seed1 + seed2)
+		seed2 = c + seed1 + seed2 + seed2<<5 + 3
+	}
+	return seed1
+}
+
+// HashName returns the hashes used to identify the named file in the hash
+// table of an MPQ archive (see HashEntry). The name is case insensitive and may
+// use either '/' or '\' as path separator.
+func HashName(name string) (nameA, nameB uint32) {
+	return hashString(name, hashNameA), hashString(name, hashNameB)
+}
+
+// upper returns the upper case version of c, as used by the hash algorithm.
+func upper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	if c == '/' {
+		return '\\'
+	}
+	return c
+}
+
+// fileKey returns the encryption key of a file. If fixKey is set, the key is
+// adjusted using the block offset and the uncompressed size of the file.
+func fileKey(name string, blockOffset, fileSize uint32, fixKey bool) uint32 {
+	// Only the base name contributes to the key.
+	name = strings.Replace(name, "/", `\`, -1)
+	if pos := strings.LastIndex(name, `\`); pos != -1 {
+		name = name[pos+1:]
+	}
+	key := hashString(name, hashFileKey)
+	if fixKey {
+		key = (key + blockOffset) ^ fileSize
+	}
+	return key
+}
+
+// decrypt decrypts buf in place using the provided key. Trailing bytes which do
+// not form a complete 32-bit word are left untouched.
+func decrypt(buf []byte, key uint32) {
+	seed := uint32(0xEEEEEEEE)
+	for i := 0; i+4 <= len(buf); i += 4 {
+		seed += cryptTable[0x400+key&0xFF]
+		x := binary.LittleEndian.Uint32(buf[i:]) ^ (key + seed)
+		key = (^key<<21 + 0x11111111) | key>>11
+		seed = x + seed + seed<<5 + 3
+		binary.LittleEndian.PutUint32(buf[i:], x)
+	}
+}
+
+// encrypt encrypts buf in place using the provided key. Trailing bytes which do
+// not form a complete 32-bit word are left untouched.
+func encrypt(buf []byte, key uint32) {
+	seed := uint32(0xEEEEEEEE)
+	for i := 0; i+4 <= len(buf); i += 4 {
+		seed += cryptTable[0x400+key&0xFF]
+		x := binary.LittleEndian.Uint32(buf[i:])
+		binary.LittleEndian.PutUint32(buf[i:], x^(key+seed))
+		key = (^key<<21 + 0x11111111) | key>>11
+		seed = x + seed + seed<<5 + 3
+	}
+}
@@ -0,0 +1,138 @@
+package mpq
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+)
+
+// File is an open file of an MPQ archive, which provides random access to its
+// contents.
+type File interface {
+	fs.File
+	io.ReaderAt
+	io.Seeker
+}
+
+// OpenFile opens the named file of fsys for reading. Files which do not
+// provide random access are read into memory.
+func OpenFile(fsys fs.FS, name string) (f File, err error) {
+	fr, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := fr.(File); ok {
+		return f, nil
+	}
+	defer fr.Close()
+	fi, err := fr.Stat()
+	if err != nil {
+		return nil, err
+	}
+	buf, err := ioutil.ReadAll(fr)
+	if err != nil {
+		return nil, err
+	}
+	return &file{Reader: bytes.NewReader(buf), info: fi}, nil
+}
+
+// A Layer is a source of files within an Overlay.
+type Layer struct {
+	// Name identifies the layer, e.g. the path to a directory or an MPQ archive.
+	Name string
+	// FS provides the files of the layer, named by their relative paths.
+	FS fs.FS
+}
+
+// An Overlay is an ordered stack of layers, in order of decreasing priority
+// (e.g. a directory of modded files, patch_rt.mpq, then DIABDAT.MPQ). Files
+// are opened from the first layer which contains them, in the same manner as
+// the game resolves files.
+type Overlay []Layer
+
+// Open implements the fs.FS interface.
+func (o Overlay) Open(name string) (f fs.File, err error) {
+	for _, layer := range o {
+		f, err = layer.FS.Open(name)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return f, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// Which returns the layers which contain the named file, in order of
+// decreasing priority. The file is opened from the first of these layers.
+func (o Overlay) Which(name string) (layers []Layer, err error) {
+	for _, layer := range o {
+		_, err = fs.Stat(layer.FS, name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+	if len(layers) == 0 {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return layers, nil
+}
+
+// file is an in-memory file of an MPQ archive.
+type file struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+// newFile returns a new in-memory file of the given name and contents.
+func newFile(buf []byte, name string) *file {
+	info := newFileInfo(name, int64(len(buf)))
+	return &file{Reader: bytes.NewReader(buf), info: info}
+}
+
+// Stat implements the fs.File interface.
+func (f *file) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// Close implements the fs.File interface.
+func (f *file) Close() error {
+	return nil
+}
+
+// fileInfo describes a file of an MPQ archive.
+type fileInfo struct {
+	name string
+	size int64
+}
+
+// newFileInfo returns a new fileInfo of the given name and size.
+func newFileInfo(name string, size int64) fileInfo {
+	name = path.Base(strings.Replace(name, `\`, "/", -1))
+	return fileInfo{name: name, size: size}
+}
+
+// Name returns the base name of the file.
+func (fi fileInfo) Name() string { return fi.name }
+
+// Size returns the uncompressed size of the file in bytes.
+func (fi fileInfo) Size() int64 { return fi.size }
+
+// Mode returns the file mode bits.
+func (fi fileInfo) Mode() fs.FileMode { return 0444 }
+
+// ModTime returns the modification time, which is not stored in MPQ archives.
+func (fi fileInfo) ModTime() time.Time { return time.Time{} }
+
+// IsDir reports whether the file is a directory.
+func (fi fileInfo) IsDir() bool { return false }
+
+// Sys returns the underlying data source (always nil).
+func (fi fileInfo) Sys() interface{} { return nil }
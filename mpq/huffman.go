@@ -0,0 +1,251 @@
+package mpq
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The Huffman compression of Storm is an adaptive Huffman coding, which Storm
+// applies to the ADPCM compressed sectors of WAV files. Below is a description
+// of the format of Huffman compressed sectors.
+//
+// Huffman format:
+//    // compressionType selects the initial weights of the tree.
+//    compressionType uint8
+//    // codes contains the Huffman codes of the decompressed bytes, which are
+//    // read one bit at the time, starting with the least significant bit of
+//    // each byte. The code of huffmanEnd terminates the data, and the code of
+//    // huffmanNewByte is followed by an 8-bit byte which isn't yet part of the
+//    // tree.
+//    codes []bit
+//
+// The tree is built from the initial weights of the compression type. A byte
+// read after huffmanNewByte is added to the tree by splitting the item of the
+// lowest weight. The weights of compression type 0 are incremented for each
+// decompressed byte, and the tree is rebalanced to keep the items sorted by
+// weight.
+
+// Special values of the Huffman tree.
+const (
+	// huffmanEnd terminates the Huffman compressed data.
+	huffmanEnd = 0x100
+	// huffmanNewByte is followed by a byte which isn't yet part of the tree.
+	huffmanNewByte = 0x101
+)
+
+// huffmanWeights maps from compression types to the initial weight of each byte
+// value; bytes of weight 0 are not part of the initial tree. The weights are
+// specific to Storm, and must match those used by Storm for compression.
+//
+// The weight tables of Storm are not yet included, as they have not been
+// verified against sectors compressed by Storm. Sectors of compression types
+// without a weight table are reported as errors.
+var huffmanWeights = map[int]*[256]uint8{}
+
+// A huffmanItem is an item of the Huffman tree. Items are stored in a list
+// sorted by weight in descending order, where the first item is the root of
+// the tree. The children of an item are adjacent in the list, and the higher
+// weight child precedes the lower weight child.
+type huffmanItem struct {
+	// prev and next are the adjacent items of the list.
+	prev, next *huffmanItem
+	// parent is the parent item, or nil for the root.
+	parent *huffmanItem
+	// childLo is the lower weight child, or nil for leaves. The higher weight
+	// child is childLo.prev.
+	childLo *huffmanItem
+	// value is the decompressed value of leaves.
+	value int
+	// weight is the weight of the item.
+	weight int
+}
+
+// A huffmanTree is an adaptive Huffman tree.
+type huffmanTree struct {
+	// head is the sentinel of the list of items.
+	head huffmanItem
+	// leaves maps from decompressed values to leaves.
+	leaves [0x102]*huffmanItem
+}
+
+// newHuffmanTree returns a new Huffman tree built from the given initial byte
+// weights.
+func newHuffmanTree(weights *[256]uint8) *huffmanTree {
+	t := new(huffmanTree)
+	t.head.prev = &t.head
+	t.head.next = &t.head
+	// Insert the leaves sorted by weight; leaves of equal weight are sorted by
+	// value.
+	for value, weight := range weights {
+		if weight == 0 {
+			continue
+		}
+		item := &huffmanItem{value: value, weight: int(weight)}
+		t.insertAfter(item, t.findHigherOrEqual(t.head.prev, item.weight))
+		t.leaves[value] = item
+	}
+	for _, value := range []int{huffmanEnd, huffmanNewByte} {
+		item := &huffmanItem{value: value, weight: 1}
+		t.insertAfter(item, t.head.prev)
+		t.leaves[value] = item
+	}
+	// Join the two items of lowest weight until only the root remains.
+	for childLo := t.head.prev; childLo != &t.head; {
+		childHi := childLo.prev
+		if childHi == &t.head {
+			break
+		}
+		parent := &huffmanItem{childLo: childLo, weight: childHi.weight + childLo.weight}
+		childLo.parent = parent
+		childHi.parent = parent
+		t.insertAfter(parent, t.findHigherOrEqual(childHi.prev, parent.weight))
+		childLo = childHi.prev
+	}
+	return t
+}
+
+// findHigherOrEqual returns the last item, starting at item and going towards
+// the front of the list, whose weight is higher than or equal to weight. The
+// list head is returned if no such item exists.
+func (t *huffmanTree) findHigherOrEqual(item *huffmanItem, weight int) *huffmanItem {
+	for ; item != &t.head; item = item.prev {
+		if item.weight >= weight {
+			return item
+		}
+	}
+	return &t.head
+}
+
+// insertAfter inserts item into the list after pos.
+func (t *huffmanTree) insertAfter(item, pos *huffmanItem) {
+	item.prev = pos
+	item.next = pos.next
+	pos.next.prev = item
+	pos.next = item
+}
+
+// remove removes item from the list.
+func (t *huffmanTree) remove(item *huffmanItem) {
+	item.prev.next = item.next
+	item.next.prev = item.prev
+}
+
+// incWeight increments the weight of item and of each of its ancestors. Items
+// whose weight exceeds the weight of preceding items are swapped with the first
+// of those items, to keep the list sorted by weight.
+func (t *huffmanTree) incWeight(item *huffmanItem) {
+	for ; item != nil; item = item.parent {
+		item.weight++
+		higher := t.findHigherOrEqual(item.prev, item.weight)
+		other := higher.next
+		if other == item {
+			continue
+		}
+		// Swap the positions of item and other within the list.
+		itemPrev := item.prev
+		t.remove(item)
+		t.insertAfter(item, higher)
+		if itemPrev != other {
+			t.remove(other)
+			t.insertAfter(other, itemPrev)
+		}
+		// Swap the parents of item and other.
+		itemParent, otherParent := item.parent, other.parent
+		itemLo := itemParent.childLo == item
+		otherLo := otherParent.childLo == other
+		if itemLo {
+			itemParent.childLo = other
+		}
+		if otherLo {
+			otherParent.childLo = item
+		}
+		item.parent, other.parent = otherParent, itemParent
+	}
+}
+
+// addValue adds the given value to the tree, by splitting the last item of
+// the list into the value of the last item and the new value.
+func (t *huffmanTree) addValue(value int) {
+	last := t.head.prev
+	childHi := &huffmanItem{parent: last, value: last.value, weight: last.weight}
+	t.insertAfter(childHi, t.head.prev)
+	t.leaves[last.value] = childHi
+	childLo := &huffmanItem{parent: last, value: value}
+	t.insertAfter(childLo, t.head.prev)
+	t.leaves[value] = childLo
+	last.childLo = childLo
+	t.incWeight(childLo)
+}
+
+// decodeHuffman decodes the Huffman compressed data of a sector.
+func decodeHuffman(data []byte) (buf []byte, err error) {
+	br := &huffmanBitReader{data: data}
+	compressionType, ok := br.readBits(8)
+	if !ok {
+		return nil, errors.New("Huffman compression type missing")
+	}
+	weights, ok := huffmanWeights[compressionType]
+	if !ok {
+		return nil, fmt.Errorf("weight table of Huffman compression type %d not included", compressionType)
+	}
+	t := newHuffmanTree(weights)
+	adaptive := compressionType == 0
+	for {
+		item := t.head.next
+		for item.childLo != nil {
+			bit, ok := br.readBits(1)
+			if !ok {
+				return nil, errors.New("Huffman data truncated")
+			}
+			if bit == 1 {
+				item = item.childLo.prev
+			} else {
+				item = item.childLo
+			}
+		}
+		value := item.value
+		switch value {
+		case huffmanEnd:
+			return buf, nil
+		case huffmanNewByte:
+			value, ok = br.readBits(8)
+			if !ok {
+				return nil, errors.New("Huffman data truncated")
+			}
+			if t.leaves[value] != nil {
+				return nil, fmt.Errorf("Huffman byte 0x%02X already present in tree", value)
+			}
+			t.addValue(value)
+			if !adaptive {
+				t.incWeight(t.leaves[value])
+			}
+		}
+		buf = append(buf, byte(value))
+		if adaptive {
+			t.incWeight(t.leaves[value])
+		}
+	}
+}
+
+// A huffmanBitReader reads bits from a byte slice, starting with the least
+// significant bit of each byte.
+type huffmanBitReader struct {
+	// data is the data being read.
+	data []byte
+	// pos is the bit position of the next bit.
+	pos int
+}
+
+// readBits reads n bits, where the first bit read is the least significant bit
+// of the result. The boolean return value reports whether enough bits remain.
+func (br *huffmanBitReader) readBits(n int) (bits int, ok bool) {
+	if br.pos+n > 8*len(br.data) {
+		return 0, false
+	}
+	for i := 0; i < n; i++ {
+		b := br.data[br.pos/8] >> uint(br.pos%8) & 1
+		bits |= int(b) << uint(i)
+		br.pos++
+	}
+	return bits, true
+}
@@ -0,0 +1,96 @@
+package mpq
+
+import (
+	"bytes"
+	"testing"
+)
+
+// encodeHuffman encodes data using the Huffman tree of the given compression
+// type, mirroring the tree updates of decodeHuffman.
+func encodeHuffman(data []byte, compressionType int) []byte {
+	bw := new(huffmanBitWriter)
+	bw.writeBits(compressionType, 8)
+	t := newHuffmanTree(huffmanWeights[compressionType])
+	adaptive := compressionType == 0
+	writeCode := func(value int) {
+		var bits []int
+		for item := t.leaves[value]; item.parent != nil; item = item.parent {
+			if item.parent.childLo == item {
+				bits = append(bits, 0)
+			} else {
+				bits = append(bits, 1)
+			}
+		}
+		for i := len(bits) - 1; i >= 0; i-- {
+			bw.writeBits(bits[i], 1)
+		}
+	}
+	for _, b := range data {
+		value := int(b)
+		if t.leaves[value] == nil {
+			writeCode(huffmanNewByte)
+			bw.writeBits(value, 8)
+			t.addValue(value)
+			if !adaptive {
+				t.incWeight(t.leaves[value])
+			}
+		} else {
+			writeCode(value)
+		}
+		if adaptive {
+			t.incWeight(t.leaves[value])
+		}
+	}
+	writeCode(huffmanEnd)
+	return bw.data
+}
+
+// A huffmanBitWriter writes bits, starting with the least significant bit of
+// each byte.
+type huffmanBitWriter struct {
+	data []byte
+	pos  int
+}
+
+func (bw *huffmanBitWriter) writeBits(bits, n int) {
+	for i := 0; i < n; i++ {
+		if bw.pos%8 == 0 {
+			bw.data = append(bw.data, 0)
+		}
+		bw.data[bw.pos/8] |= byte(bits>>uint(i)&1) << uint(bw.pos%8)
+		bw.pos++
+	}
+}
+
+func TestHuffmanRoundTrip(t *testing.T) {
+	// Test weights; the weights used by Storm are not needed to verify the
+	// tree updates.
+	var adaptiveWeights, staticWeights [256]uint8
+	for i := range adaptiveWeights {
+		adaptiveWeights[i] = uint8(1 + i%7)
+	}
+	for i := 0; i < 16; i++ {
+		staticWeights[i] = uint8(0xF0 - 8*i)
+		staticWeights[0x40+i] = uint8(0x80 - 4*i)
+	}
+	defer func(old map[int]*[256]uint8) { huffmanWeights = old }(huffmanWeights)
+	huffmanWeights = map[int]*[256]uint8{0: &adaptiveWeights, 7: &staticWeights}
+
+	var data []byte
+	for i := 0; i < 5000; i++ {
+		data = append(data, byte(i*i%13), byte(0x40+i%5), byte(i*31))
+	}
+	for _, compressionType := range []int{0, 7} {
+		enc := encodeHuffman(data, compressionType)
+		got, err := decodeHuffman(enc)
+		if err != nil {
+			t.Fatalf("compression type %d: %v", compressionType, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("compression type %d: decoded data mismatch", compressionType)
+		}
+	}
+	if _, err := decodeHuffman([]byte{5}); err == nil {
+		t.Fatal("expected error for compression type without weight table")
+	}
+}
@@ -0,0 +1,97 @@
+package mpq
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// CleanPath returns the canonical form of a file name within an MPQ archive, as
+// used by the index; in lower case, with forward slashes as separators.
+func CleanPath(name string) (relPath string) {
+	relPath = strings.ToLower(strings.TrimSpace(name))
+	relPath = strings.Replace(relPath, `\`, "/", -1)
+	return strings.TrimPrefix(path.Clean("/"+relPath), "/")
+}
+
+// ReadListfile reads the file names of a listfile, such as the "(listfile)" of
+// an MPQ archive. The file names are separated by line breaks or semicolons,
+// and are returned in canonical form as described by CleanPath.
+func ReadListfile(r io.Reader) (relPaths []string, err error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		for _, name := range strings.Split(s.Text(), ";") {
+			if strings.TrimSpace(name) == "" {
+				continue
+			}
+			relPaths = append(relPaths, CleanPath(name))
+		}
+	}
+	err = s.Err()
+	if err != nil {
+		return nil, err
+	}
+	return relPaths, nil
+}
+
+// Index returns a map from names to relative paths, as stored in the ini file
+// used by OpenArchive. Files are named by their base name. Base names which
+// occur in more than one directory are instead named by their relative path,
+// with '/' replaced by '-'. Such collisions are returned as a map from base
+// name to relative paths.
+//
+// Names are compared in canonical form, as described by CleanPath. Relative
+// paths keep their case, as it matters when accessing an extracted MPQ archive,
+// and only the first of several relative paths which are equal in canonical
+// form is kept.
+func Index(relPaths []string) (index map[string]string, collisions map[string][]string) {
+	dirs := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, relPath := range relPaths {
+		relPath = strings.Replace(relPath, `\`, "/", -1)
+		cleanPath := CleanPath(relPath)
+		if seen[cleanPath] {
+			continue
+		}
+		seen[cleanPath] = true
+		name := path.Base(cleanPath)
+		dirs[name] = append(dirs[name], relPath)
+	}
+	index = make(map[string]string)
+	collisions = make(map[string][]string)
+	for name, paths := range dirs {
+		if len(paths) == 1 {
+			index[name] = paths[0]
+			continue
+		}
+		sort.Strings(paths)
+		collisions[name] = paths
+		for _, relPath := range paths {
+			index[strings.Replace(CleanPath(relPath), "/", "-", -1)] = relPath
+		}
+	}
+	return index, collisions
+}
+
+// WriteIndex writes index to w using the ini format of 'mpq.ini'. The entries
+// are sorted by relative path, in canonical form.
+func WriteIndex(w io.Writer, index map[string]string) (err error) {
+	var names []string
+	for name := range index {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return CleanPath(index[names[i]]) < CleanPath(index[names[j]])
+	})
+	bw := bufio.NewWriter(w)
+	for i, name := range names {
+		if i > 0 {
+			fmt.Fprintln(bw)
+		}
+		fmt.Fprintf(bw, "[%s]\npath = %s\n", name, index[name])
+	}
+	return bw.Flush()
+}
@@ -1,52 +1,196 @@
-// Package mpq provides access to an extracted MPQ archive.
+// Package mpq provides access to the files of an MPQ archive, either by reading
+// the archive directly or by accessing an extracted MPQ archive.
 package mpq
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
 	"path"
+	"path/filepath"
+	"sort"
 
 	"github.com/mewbak/goini"
 )
 
-var dict ini.Dict
+// An Archive provides access to the files of an MPQ archive. Files are located
+// using an ini file which provides relative path information, and are read
+// from an ordered stack of layers; directories of loose files and MPQ archives
+// (e.g. mods/, patch_rt.mpq, hellfire.mpq and DIABDAT.MPQ), followed by an
+// extracted MPQ archive.
+type Archive struct {
+	// ExtractPath is the path to an extracted MPQ file.
+	ExtractPath string
+	// Layers contains the sources of files, in order of decreasing priority.
+	Layers Overlay
+	// dict provides relative path information.
+	dict ini.Dict
+	// index provides relative path information for files not present in dict,
+	// based on the listfiles of the MPQ archives.
+	index map[string]string
+	// closers contains the MPQ archives to close.
+	closers []io.Closer
+}
 
-// IniPath is the path to an ini file which provides relative path information
-// for files in an extracted MPQ archive.
-var IniPath string
+// OpenArchive loads the ini file located at iniPath, which provides relative
+// path information for files in an extracted MPQ archive located at
+// extractPath. Files are read from the directories and MPQ archives specified
+// by layerPaths, in order of decreasing priority, and files not present in any
+// of them are read from extractPath. The priority of extractPath may instead be
+// specified by including it in layerPaths (e.g. to override the files of the
+// MPQ archives by the patched files of mpqfix). Empty layer paths are ignored.
+func OpenArchive(extractPath, iniPath string, layerPaths ...string) (a *Archive, err error) {
+	a = &Archive{ExtractPath: extractPath, index: make(map[string]string)}
+	a.dict, err = ini.Load(iniPath)
+	if err != nil {
+		return nil, err
+	}
+	hasExtractPath := false
+	for _, layerPath := range layerPaths {
+		if layerPath == "" {
+			continue
+		}
+		if extractPath != "" && filepath.Clean(layerPath) == filepath.Clean(extractPath) {
+			a.Layers = append(a.Layers, Layer{Name: extractPath, FS: os.DirFS(extractPath)})
+			hasExtractPath = true
+			continue
+		}
+		err = a.addLayer(layerPath)
+		if err != nil {
+			a.Close()
+			return nil, err
+		}
+	}
+	if extractPath != "" && !hasExtractPath {
+		a.Layers = append(a.Layers, Layer{Name: extractPath, FS: os.DirFS(extractPath)})
+	}
+	return a, nil
+}
+
+// addLayer adds the directory or MPQ archive located at layerPath as the layer
+// of lowest priority.
+func (a *Archive) addLayer(layerPath string) (err error) {
+	fi, err := os.Stat(layerPath)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		a.Layers = append(a.Layers, Layer{Name: layerPath, FS: os.DirFS(layerPath)})
+		return nil
+	}
+	rc, err := OpenReader(layerPath)
+	if err != nil {
+		return err
+	}
+	a.closers = append(a.closers, rc)
+	a.Layers = append(a.Layers, Layer{Name: layerPath, FS: rc})
 
-// Init loads an ini file which provides relative path information for files in
-// an extracted MPQ archive.
-func Init() (err error) {
-	dict, err = ini.Load(IniPath)
+	// Add the files of the listfile to the index, unless already present in a
+	// layer of higher priority.
+	if !rc.Has(listfileName) {
+		return nil
+	}
+	buf, err := rc.ReadFile(listfileName)
+	if err != nil {
+		return err
+	}
+	relPaths, err := ReadListfile(bytes.NewReader(buf))
 	if err != nil {
 		return err
 	}
+	index, _ := Index(relPaths)
+	for name, relPath := range index {
+		if _, ok := a.index[name]; !ok {
+			a.index[name] = relPath
+		}
+	}
 	return nil
 }
 
-// ExtractPath is the path to an extracted MPQ file.
-var ExtractPath string
+// Close closes the MPQ archives, if any.
+func (a *Archive) Close() (err error) {
+	for _, c := range a.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	a.closers = nil
+	return err
+}
 
 // AbsPath returns the absolute path of relPath. The absolute path of relPath is
-// relative to mpq.ExtractPath.
-func AbsPath(relPath string) (absPath string) {
-	return path.Join(ExtractPath, relPath)
+// relative to a.ExtractPath.
+func (a *Archive) AbsPath(relPath string) (absPath string) {
+	return path.Join(a.ExtractPath, relPath)
 }
 
-// GetPath returns the full path of name.
-func GetPath(name string) (path string, err error) {
-	relPath, err := GetRelPath(name)
+// GetPath returns the full path of name, within the layer of highest priority
+// which contains it. Files located within MPQ archives are specified by the
+// path to the archive, followed by a colon and the relative path of the file
+// (e.g. "diabdat.mpq:levels/l1data/l1.cel").
+func (a *Archive) GetPath(name string) (fullPath string, err error) {
+	layer, relPath, err := a.Which(name)
 	if err != nil {
 		return "", err
 	}
-	return AbsPath(relPath), nil
+	if _, ok := layer.FS.(*ReadCloser); ok {
+		return layer.Name + ":" + relPath, nil
+	}
+	return path.Join(layer.Name, relPath), nil
+}
+
+// Which returns the relative path of name, and the layer of highest priority
+// which contains it.
+func (a *Archive) Which(name string) (layer Layer, relPath string, err error) {
+	relPath, err = a.GetRelPath(name)
+	if err != nil {
+		return Layer{}, "", err
+	}
+	layers, err := a.Layers.Which(relPath)
+	if err != nil {
+		return Layer{}, "", err
+	}
+	return layers[0], relPath, nil
 }
 
 // GetRelPath returns the relative path of name.
-func GetRelPath(name string) (relPath string, err error) {
-	relPath, found := dict.GetString(name, "path")
-	if !found {
-		return "", fmt.Errorf("mpq.GetRelPath: path not found for %q", name)
+func (a *Archive) GetRelPath(name string) (relPath string, err error) {
+	relPath, found := a.dict.GetString(name, "path")
+	if found {
+		return relPath, nil
+	}
+	relPath, found = a.index[name]
+	if found {
+		return relPath, nil
+	}
+	return "", fmt.Errorf("mpq.Archive.GetRelPath: path not found for %q", name)
+}
+
+// Names returns the sorted names of the files known to the archive, as provided
+// by the ini file and the listfiles of the MPQ archives.
+func (a *Archive) Names() (names []string) {
+	for name := range a.dict {
+		if name == "" {
+			continue
+		}
+		if _, found := a.dict.GetString(name, "path"); found {
+			names = append(names, name)
+		}
+	}
+	for name := range a.index {
+		if _, found := a.dict.GetString(name, "path"); !found {
+			names = append(names, name)
+		}
 	}
-	return relPath, nil
+	sort.Strings(names)
+	return names
+}
+
+// FS returns a file system of the MPQ archive. Files are read from the layer of
+// highest priority which contains them. File names are relative paths, as
+// returned by a.GetRelPath.
+func (a *Archive) FS() fs.FS {
+	return a.Layers
 }
@@ -0,0 +1,61 @@
+package mpq
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenArchive(t *testing.T) {
+	// An extracted MPQ archive and a directory of loose files, which both
+	// contain town.pal.
+	dir := t.TempDir()
+	iniPath := filepath.Join(dir, "mpq.ini")
+	extractPath := filepath.Join(dir, "mpqdump")
+	modPath := filepath.Join(dir, "mods")
+	files := map[string]string{
+		iniPath: "[town.pal]\npath = levels/towndata/town.pal\n",
+		filepath.Join(extractPath, "levels/towndata/town.pal"): "mpqdump",
+		filepath.Join(modPath, "levels/towndata/town.pal"):     "mods",
+	}
+	for path, data := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	golden := []struct {
+		layerPaths []string
+		// Number of layers.
+		n    int
+		want string
+	}{
+		// The extracted MPQ archive is the layer of lowest priority by default.
+		{layerPaths: []string{modPath}, n: 2, want: "mods"},
+		{layerPaths: nil, n: 1, want: "mpqdump"},
+		// The priority of the extracted MPQ archive is specified explicitly.
+		{layerPaths: []string{extractPath + "/", modPath}, n: 2, want: "mpqdump"},
+		{layerPaths: []string{modPath, extractPath}, n: 2, want: "mods"},
+	}
+	for i, g := range golden {
+		a, err := OpenArchive(extractPath, iniPath, g.layerPaths...)
+		if err != nil {
+			t.Fatalf("i=%d: %v", i, err)
+		}
+		if len(a.Layers) != g.n {
+			t.Errorf("i=%d: layer count mismatch; expected %d, got %d", i, g.n, len(a.Layers))
+		}
+		buf, err := fs.ReadFile(a.FS(), "levels/towndata/town.pal")
+		if err != nil {
+			t.Fatalf("i=%d: %v", i, err)
+		}
+		if string(buf) != g.want {
+			t.Errorf("i=%d: layer mismatch; expected %q, got %q", i, g.want, buf)
+		}
+		a.Close()
+	}
+}
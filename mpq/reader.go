@@ -0,0 +1,497 @@
+package mpq
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/mewrnd/blizzconv/pkware"
+)
+
+// An MPQ archive is located at a 512 byte boundary of the file. Below is a
+// description of the MPQ archive format (version 0, as used by Diablo). All
+// integers are stored in little endian.
+//
+// MPQ format:
+//    header     Header
+//    // files contains the file data, located at blockEntry.offset.
+//    files      []byte
+//    // hashTable is encrypted using the key hashString("(hash table)", 3).
+//    hashTable  [hashTableEntries]HashEntry
+//    // blockTable is encrypted using the key hashString("(block table)", 3).
+//    blockTable [blockTableEntries]blockEntry
+//
+// Header format:
+//    magic             [4]byte // "MPQ\x1A"
+//    headerSize        uint32
+//    archiveSize       uint32
+//    formatVersion     uint16
+//    // sectorSize = 512 << sectorSizeShift
+//    sectorSizeShift   uint16
+//    hashTableOffset   uint32
+//    blockTableOffset  uint32
+//    hashTableEntries  uint32
+//    blockTableEntries uint32
+//
+// Hash entry format:
+//    nameA      uint32 // hashString(name, 1)
+//    nameB      uint32 // hashString(name, 2)
+//    locale     uint16
+//    platform   uint16
+//    blockIndex uint32
+//
+// Block entry format:
+//    offset         uint32 // relative to the start of the archive.
+//    compressedSize uint32
+//    fileSize       uint32
+//    flags          uint32
+//
+// Compressed files are split into sectors. The file data of compressed files
+// starts with a sector offset table, which contains sectorCount + 1 offsets
+// relative to the start of the file data. Encrypted files are encrypted sector
+// by sector, using the file key plus the sector number as key. The sector
+// offset table is encrypted using the file key minus one.
+
+// Header is the header of an MPQ archive.
+type Header struct {
+	Magic             [4]byte
+	HeaderSize        uint32
+	ArchiveSize       uint32
+	FormatVersion     uint16
+	SectorSizeShift   uint16
+	HashTableOffset   uint32
+	BlockTableOffset  uint32
+	HashTableEntries  uint32
+	BlockTableEntries uint32
+}
+
+// magic is the signature of MPQ archives.
+const magic = "MPQ\x1A"
+
+// A HashEntry is an entry of the hash table, which identifies a file by the
+// hashes of its name.
+type HashEntry struct {
+	NameA      uint32
+	NameB      uint32
+	Locale     uint16
+	Platform   uint16
+	BlockIndex uint32
+}
+
+// Special block indices of hash entries.
+const (
+	// blockIndexEmpty marks a hash entry which has never been used.
+	blockIndexEmpty = 0xFFFFFFFF
+	// blockIndexDeleted marks a hash entry of a deleted file.
+	blockIndexDeleted = 0xFFFFFFFE
+)
+
+// blockEntry is an entry of the block table.
+type blockEntry struct {
+	Offset         uint32
+	CompressedSize uint32
+	FileSize       uint32
+	Flags          uint32
+}
+
+// Flags of block entries.
+const (
+	// flagImplode specifies that the file is compressed using PKWARE implode.
+	flagImplode = 0x00000100
+	// flagCompress specifies that the sectors of the file are prefixed with a
+	// byte that specifies the compression methods used.
+	flagCompress = 0x00000200
+	// flagEncrypted specifies that the file is encrypted.
+	flagEncrypted = 0x00010000
+	// flagFixKey specifies that the file key is adjusted by the block offset
+	// and the file size.
+	flagFixKey = 0x00020000
+	// flagSingleUnit specifies that the file is stored as a single sector.
+	flagSingleUnit = 0x01000000
+	// flagSectorCRC specifies that the sector offset table is followed by an
+	// offset to the sector checksums.
+	flagSectorCRC = 0x04000000
+	// flagExists specifies that the block entry is in use.
+	flagExists = 0x80000000
+)
+
+// Compression methods of the compression byte used by flagCompress.
+const (
+	compressHuffman     = 0x01
+	compressZlib        = 0x02
+	compressPKLib       = 0x08
+	compressBzip2       = 0x10
+	compressADPCMMono   = 0x40
+	compressADPCMStereo = 0x80
+)
+
+// A Reader provides read access to the files of an MPQ archive.
+type Reader struct {
+	// Header is the header of the archive.
+	Header Header
+	r      io.ReaderAt
+	// base is the offset of the archive within r.
+	base       int64
+	sectorSize int
+	hashTable  []HashEntry
+	blockTable []blockEntry
+}
+
+// A ReadCloser is a Reader that must be closed when no longer needed.
+type ReadCloser struct {
+	Reader
+	f *os.File
+}
+
+// OpenReader opens the MPQ archive specified by path and returns a ReadCloser.
+func OpenReader(path string) (rc *ReadCloser, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	rc = &ReadCloser{f: f}
+	err = rc.init(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("mpq.OpenReader: unable to open %q: %v", path, err)
+	}
+	return rc, nil
+}
+
+// Close closes the MPQ archive, rendering it unusable for I/O.
+func (rc *ReadCloser) Close() error {
+	return rc.f.Close()
+}
+
+// NewReader returns a new Reader reading from r, which is assumed to have the
+// given size in bytes.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	mr := new(Reader)
+	err := mr.init(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return mr, nil
+}
+
+// init locates the archive header and reads the hash and block tables.
+func (mr *Reader) init(r io.ReaderAt, size int64) (err error) {
+	mr.r = r
+
+	// Locate header.
+	buf := make([]byte, 4)
+	for mr.base = 0; ; mr.base += 512 {
+		if mr.base+32 > size {
+			return errors.New("unable to locate MPQ header")
+		}
+		_, err = r.ReadAt(buf, mr.base)
+		if err != nil {
+			return err
+		}
+		if string(buf) == magic {
+			break
+		}
+	}
+	sr := io.NewSectionReader(r, mr.base, size-mr.base)
+	err = binary.Read(sr, binary.LittleEndian, &mr.Header)
+	if err != nil {
+		return fmt.Errorf("unable to read header: %v", err)
+	}
+	mr.sectorSize = 512 << mr.Header.SectorSizeShift
+
+	// Read hash table.
+	mr.hashTable = make([]HashEntry, mr.Header.HashTableEntries)
+	err = mr.readTable(mr.hashTable, mr.Header.HashTableOffset, hashString("(hash table)", hashFileKey))
+	if err != nil {
+		return fmt.Errorf("unable to read hash table: %v", err)
+	}
+
+	// Read block table.
+	mr.blockTable = make([]blockEntry, mr.Header.BlockTableEntries)
+	err = mr.readTable(mr.blockTable, mr.Header.BlockTableOffset, hashString("(block table)", hashFileKey))
+	if err != nil {
+		return fmt.Errorf("unable to read block table: %v", err)
+	}
+
+	return nil
+}
+
+// readTable reads and decrypts the table located at offset into table, which
+// is a slice of either hash or block entries.
+func (mr *Reader) readTable(table interface{}, offset uint32, key uint32) (err error) {
+	buf := make([]byte, binary.Size(table))
+	_, err = mr.r.ReadAt(buf, mr.base+int64(offset))
+	if err != nil {
+		return err
+	}
+	decrypt(buf, key)
+	return binary.Read(bytes.NewReader(buf), binary.LittleEndian, table)
+}
+
+// lookup returns the block entry of the file specified by name.
+func (mr *Reader) lookup(name string) (block *blockEntry, found bool) {
+	n := uint32(len(mr.hashTable))
+	if n == 0 {
+		return nil, false
+	}
+	start := hashString(name, hashTableIndex) & (n - 1)
+	nameA := hashString(name, hashNameA)
+	nameB := hashString(name, hashNameB)
+	for i := start; ; {
+		entry := mr.hashTable[i]
+		if entry.BlockIndex == blockIndexEmpty {
+			return nil, false
+		}
+		if entry.BlockIndex != blockIndexDeleted && entry.NameA == nameA && entry.NameB == nameB {
+			if entry.BlockIndex >= uint32(len(mr.blockTable)) {
+				return nil, false
+			}
+			block = &mr.blockTable[entry.BlockIndex]
+			if block.Flags&flagExists == 0 {
+				return nil, false
+			}
+			return block, true
+		}
+		i = (i + 1) & (n - 1)
+		if i == start {
+			return nil, false
+		}
+	}
+}
+
+// HashEntries returns the hash entries of the files of the archive, which may
+// be used to recover the names of files not present in any listfile.
+func (mr *Reader) HashEntries() (entries []HashEntry) {
+	for _, entry := range mr.hashTable {
+		if entry.BlockIndex >= uint32(len(mr.blockTable)) {
+			// Empty or deleted entry.
+			continue
+		}
+		if mr.blockTable[entry.BlockIndex].Flags&flagExists == 0 {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// Has reports whether the archive contains a file with the given name.
+func (mr *Reader) Has(name string) bool {
+	_, found := mr.lookup(archiveName(name))
+	return found
+}
+
+// Open opens the file specified by name for reading. The name is case
+// insensitive and may use either '/' or '\' as path separator. The returned
+// file implements the File interface.
+//
+// Open implements the fs.FS interface.
+func (mr *Reader) Open(name string) (fs.File, error) {
+	buf, err := mr.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return newFile(buf, name), nil
+}
+
+// ReadFile reads the file specified by name and returns its contents.
+//
+// ReadFile implements the fs.ReadFileFS interface.
+func (mr *Reader) ReadFile(name string) (buf []byte, err error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	name = archiveName(name)
+	block, found := mr.lookup(name)
+	if !found {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	buf, err = mr.readBlock(name, block)
+	if err != nil {
+		return nil, fmt.Errorf("mpq.Reader.ReadFile: unable to read %q: %v", name, err)
+	}
+	return buf, nil
+}
+
+// Stat returns a FileInfo describing the file specified by name, without
+// reading its contents.
+//
+// Stat implements the fs.StatFS interface.
+func (mr *Reader) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	block, found := mr.lookup(archiveName(name))
+	if !found {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return newFileInfo(name, int64(block.FileSize)), nil
+}
+
+// readBlock reads and decodes the contents of the given block entry.
+func (mr *Reader) readBlock(name string, block *blockEntry) (buf []byte, err error) {
+	raw := make([]byte, block.CompressedSize)
+	_, err = mr.r.ReadAt(raw, mr.base+int64(block.Offset))
+	if err != nil {
+		return nil, err
+	}
+	var key uint32
+	if block.Flags&flagEncrypted != 0 {
+		key = fileKey(name, block.Offset, block.FileSize, block.Flags&flagFixKey != 0)
+	}
+
+	// Uncompressed files.
+	if block.Flags&(flagImplode|flagCompress) == 0 {
+		if block.Flags&flagEncrypted != 0 {
+			for sectorNum := 0; sectorNum*mr.sectorSize < len(raw); sectorNum++ {
+				start := sectorNum * mr.sectorSize
+				end := start + mr.sectorSize
+				if end > len(raw) {
+					end = len(raw)
+				}
+				decrypt(raw[start:end], key+uint32(sectorNum))
+			}
+		}
+		if uint32(len(raw)) < block.FileSize {
+			return nil, errors.New("file data truncated")
+		}
+		return raw[:block.FileSize], nil
+	}
+
+	// Compressed files stored as a single sector.
+	if block.Flags&flagSingleUnit != 0 {
+		if block.Flags&flagEncrypted != 0 {
+			decrypt(raw, key)
+		}
+		return decompressSector(raw, int(block.FileSize), block.Flags)
+	}
+
+	// Compressed files split into sectors.
+	sectorCount := (int(block.FileSize) + mr.sectorSize - 1) / mr.sectorSize
+	offsetCount := sectorCount + 1
+	if block.Flags&flagSectorCRC != 0 {
+		offsetCount++
+	}
+	if len(raw) < 4*offsetCount {
+		return nil, errors.New("sector offset table truncated")
+	}
+	if block.Flags&flagEncrypted != 0 {
+		decrypt(raw[:4*offsetCount], key-1)
+	}
+	sectorOffsets := make([]uint32, offsetCount)
+	for i := range sectorOffsets {
+		sectorOffsets[i] = binary.LittleEndian.Uint32(raw[4*i:])
+	}
+	buf = make([]byte, 0, block.FileSize)
+	for sectorNum := 0; sectorNum < sectorCount; sectorNum++ {
+		start, end := sectorOffsets[sectorNum], sectorOffsets[sectorNum+1]
+		if start > end || end > uint32(len(raw)) {
+			return nil, fmt.Errorf("invalid offsets of sector %d", sectorNum)
+		}
+		sector := raw[start:end]
+		if block.Flags&flagEncrypted != 0 {
+			decrypt(sector, key+uint32(sectorNum))
+		}
+		size := mr.sectorSize
+		if left := int(block.FileSize) - len(buf); left < size {
+			size = left
+		}
+		data, err := decompressSector(sector, size, block.Flags)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress sector %d: %v", sectorNum, err)
+		}
+		buf = append(buf, data...)
+	}
+	return buf, nil
+}
+
+// decompressSector decompresses the sector based on the provided block flags.
+// Sectors which are not smaller than their uncompressed size are stored as is.
+func decompressSector(sector []byte, size int, flags uint32) (buf []byte, err error) {
+	if len(sector) >= size {
+		return sector[:size], nil
+	}
+	if flags&flagImplode != 0 {
+		buf, err = pkware.Decompress(sector)
+	} else {
+		buf, err = decompress(sector)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) != size {
+		return nil, fmt.Errorf("decompressed size mismatch; expected %d, got %d", size, len(buf))
+	}
+	return buf, nil
+}
+
+// decompress decompresses a sector whose first byte specifies the compression
+// methods used. The methods are reversed in the opposite order of compression;
+// general purpose compression followed by Huffman coding and ADPCM, the latter
+// two of which are used by Storm for WAV files.
+func decompress(sector []byte) (buf []byte, err error) {
+	if len(sector) < 1 {
+		return nil, errors.New("empty sector")
+	}
+	mask := sector[0]
+	buf = sector[1:]
+	switch {
+	case mask&compressBzip2 != 0:
+		buf, err = ioutil.ReadAll(bzip2.NewReader(bytes.NewReader(buf)))
+		mask &^= compressBzip2
+	case mask&compressPKLib != 0:
+		buf, err = pkware.Decompress(buf)
+		mask &^= compressPKLib
+	case mask&compressZlib != 0:
+		var zr io.ReadCloser
+		zr, err = zlib.NewReader(bytes.NewReader(buf))
+		if err == nil {
+			buf, err = ioutil.ReadAll(zr)
+			zr.Close()
+		}
+		mask &^= compressZlib
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mask&compressHuffman != 0 {
+		buf, err = decodeHuffman(buf)
+		if err != nil {
+			return nil, err
+		}
+		mask &^= compressHuffman
+	}
+	switch {
+	case mask&compressADPCMMono != 0:
+		buf, err = decodeADPCM(buf, 1)
+		mask &^= compressADPCMMono
+	case mask&compressADPCMStereo != 0:
+		buf, err = decodeADPCM(buf, 2)
+		mask &^= compressADPCMStereo
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mask != 0 {
+		return nil, fmt.Errorf("unsupported compression method (0x%02X)", mask)
+	}
+	return buf, nil
+}
+
+// archiveName converts name into the path format used within MPQ archives.
+func archiveName(name string) string {
+	return strings.Replace(name, "/", `\`, -1)
+}
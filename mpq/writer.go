@@ -0,0 +1,253 @@
+package mpq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mewrnd/blizzconv/pkware"
+)
+
+// A Writer creates an MPQ archive (format version 0, as used by Diablo). The
+// contents of the files are kept in memory until the Writer is closed, at
+// which point the archive is written to the underlying writer.
+type Writer struct {
+	// SectorSizeShift specifies the sector size (512 << SectorSizeShift). The
+	// default value of NewWriter is 3 (4096 bytes), as used by Diablo.
+	SectorSizeShift uint16
+	// Implode specifies whether the sectors of the files are compressed using
+	// PKWARE implode. Sectors which would not shrink are stored as is.
+	Implode bool
+	// Encrypt specifies whether the files are encrypted. The file keys are
+	// adjusted by the block offset and the file size.
+	Encrypt bool
+	w       io.Writer
+	files   []*writerFile
+	closed  bool
+}
+
+// writerFile is a file of an MPQ archive being written.
+type writerFile struct {
+	// name is the file name, using '\' as path separator.
+	name string
+	buf  bytes.Buffer
+}
+
+// NewWriter returns a new Writer which writes an MPQ archive to w. The files
+// are compressed and encrypted by default, which may be changed before the
+// Writer is closed.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		SectorSizeShift: 3,
+		Implode:         true,
+		Encrypt:         true,
+		w:               w,
+	}
+}
+
+// errWriterClosed is returned when using a closed Writer.
+var errWriterClosed = errors.New("mpq: use of closed Writer")
+
+// Create adds a file with the given name to the archive, and returns a writer
+// to which the file contents should be written. The name may use either '/'
+// or '\' as path separator. A listfile, named "(listfile)", is generated unless
+// created explicitly.
+func (mw *Writer) Create(name string) (w io.Writer, err error) {
+	if mw.closed {
+		return nil, errWriterClosed
+	}
+	name = archiveName(name)
+	if name == "" {
+		return nil, errors.New("mpq.Writer.Create: empty file name")
+	}
+	if mw.has(name) {
+		return nil, fmt.Errorf("mpq.Writer.Create: duplicate file name %q", name)
+	}
+	f := &writerFile{name: name}
+	mw.files = append(mw.files, f)
+	return &f.buf, nil
+}
+
+// headerSize is the size of the header of MPQ archives (format version 0).
+const headerSize = 32
+
+// Close writes the archive to the underlying writer.
+func (mw *Writer) Close() (err error) {
+	if mw.closed {
+		return errWriterClosed
+	}
+	mw.closed = true
+	if mw.SectorSizeShift > 15 {
+		return fmt.Errorf("mpq.Writer.Close: invalid sector size shift (%d)", mw.SectorSizeShift)
+	}
+	files := mw.files
+	if !mw.has(listfileName) {
+		listfile := &writerFile{name: listfileName}
+		for _, f := range files {
+			fmt.Fprintf(&listfile.buf, "%s\r\n", f.name)
+		}
+		files = append(files, listfile)
+	}
+
+	// Store files.
+	var data []byte
+	blockTable := make([]blockEntry, len(files))
+	for i, f := range files {
+		offset := uint32(headerSize + len(data))
+		raw, flags, err := mw.encode(f.name, f.buf.Bytes(), offset)
+		if err != nil {
+			return fmt.Errorf("mpq.Writer.Close: unable to store %q: %v", f.name, err)
+		}
+		blockTable[i] = blockEntry{
+			Offset:         offset,
+			CompressedSize: uint32(len(raw)),
+			FileSize:       uint32(f.buf.Len()),
+			Flags:          flags,
+		}
+		data = append(data, raw...)
+	}
+
+	// Create hash table.
+	hashTable := make([]HashEntry, hashTableSize(len(files)))
+	for i := range hashTable {
+		hashTable[i] = HashEntry{
+			NameA:      0xFFFFFFFF,
+			NameB:      0xFFFFFFFF,
+			Locale:     0xFFFF,
+			Platform:   0xFFFF,
+			BlockIndex: blockIndexEmpty,
+		}
+	}
+	n := uint32(len(hashTable))
+	for blockIndex, f := range files {
+		i := hashString(f.name, hashTableIndex) & (n - 1)
+		for hashTable[i].BlockIndex != blockIndexEmpty {
+			i = (i + 1) & (n - 1)
+		}
+		hashTable[i] = HashEntry{
+			NameA:      hashString(f.name, hashNameA),
+			NameB:      hashString(f.name, hashNameB),
+			BlockIndex: uint32(blockIndex),
+		}
+	}
+
+	// Write archive.
+	hashTableOffset := uint32(headerSize + len(data))
+	blockTableOffset := hashTableOffset + uint32(binary.Size(hashTable))
+	hdr := Header{
+		HeaderSize:        headerSize,
+		ArchiveSize:       blockTableOffset + uint32(binary.Size(blockTable)),
+		FormatVersion:     0,
+		SectorSizeShift:   mw.SectorSizeShift,
+		HashTableOffset:   hashTableOffset,
+		BlockTableOffset:  blockTableOffset,
+		HashTableEntries:  uint32(len(hashTable)),
+		BlockTableEntries: uint32(len(blockTable)),
+	}
+	copy(hdr.Magic[:], magic)
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, hdr)
+	buf.Write(data)
+	writeTable(buf, hashTable, hashString("(hash table)", hashFileKey))
+	writeTable(buf, blockTable, hashString("(block table)", hashFileKey))
+	_, err = mw.w.Write(buf.Bytes())
+	return err
+}
+
+// listfileName is the name of the file which lists the files of an archive.
+const listfileName = "(listfile)"
+
+// has reports whether a file with the given name has been created.
+func (mw *Writer) has(name string) bool {
+	for _, f := range mw.files {
+		if strings.EqualFold(f.name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// encode returns the file data and the block flags of a file, which is stored
+// at the given block offset.
+func (mw *Writer) encode(name string, buf []byte, offset uint32) (raw []byte, flags uint32, err error) {
+	flags = flagExists
+	var key uint32
+	if mw.Encrypt {
+		flags |= flagEncrypted | flagFixKey
+		key = fileKey(name, offset, uint32(len(buf)), true)
+	}
+	sectorSize := 512 << mw.SectorSizeShift
+
+	// Uncompressed files.
+	if !mw.Implode || len(buf) == 0 {
+		raw = append([]byte(nil), buf...)
+		if mw.Encrypt {
+			for sectorNum := 0; sectorNum*sectorSize < len(raw); sectorNum++ {
+				start := sectorNum * sectorSize
+				end := start + sectorSize
+				if end > len(raw) {
+					end = len(raw)
+				}
+				encrypt(raw[start:end], key+uint32(sectorNum))
+			}
+		}
+		return raw, flags, nil
+	}
+
+	// Compressed files split into sectors.
+	flags |= flagImplode
+	sectorCount := (len(buf) + sectorSize - 1) / sectorSize
+	sectorOffsets := make([]byte, 4*(sectorCount+1))
+	raw = sectorOffsets
+	for sectorNum := 0; sectorNum < sectorCount; sectorNum++ {
+		start := sectorNum * sectorSize
+		end := start + sectorSize
+		if end > len(buf) {
+			end = len(buf)
+		}
+		sector, err := pkware.Compress(buf[start:end], pkware.Binary, pkware.DictSize4K)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(sector) >= end-start {
+			// Store the sector as is.
+			sector = append([]byte(nil), buf[start:end]...)
+		}
+		if mw.Encrypt {
+			encrypt(sector, key+uint32(sectorNum))
+		}
+		binary.LittleEndian.PutUint32(sectorOffsets[4*sectorNum:], uint32(len(raw)))
+		raw = append(raw, sector...)
+		// The sector offset table may have been moved by append.
+		sectorOffsets = raw[:len(sectorOffsets)]
+	}
+	binary.LittleEndian.PutUint32(sectorOffsets[4*sectorCount:], uint32(len(raw)))
+	if mw.Encrypt {
+		encrypt(sectorOffsets, key-1)
+	}
+	return raw, flags, nil
+}
+
+// hashTableSize returns the number of hash table entries used for an archive
+// of n files; the smallest power of two which leaves at least a quarter of the
+// entries empty.
+func hashTableSize(n int) int {
+	size := 16
+	for size-size/4 < n {
+		size <<= 1
+	}
+	return size
+}
+
+// writeTable encrypts and writes table to buf, which is a slice of either hash
+// or block entries.
+func writeTable(buf *bytes.Buffer, table interface{}, key uint32) {
+	tmp := new(bytes.Buffer)
+	binary.Write(tmp, binary.LittleEndian, table)
+	b := tmp.Bytes()
+	encrypt(b, key)
+	buf.Write(b)
+}
@@ -0,0 +1,82 @@
+package mpq
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := make([]byte, 10007)
+	r.Read(random)
+	// Files are read back using '/' as path separator.
+	files := map[string][]byte{
+		"levels/l1data/l1.min": bytes.Repeat([]byte("abcabcabd"), 3000),
+		"levels/l1data/l1.til": {1, 2, 3},
+		"monsters/empty.trn":   nil,
+		"music/random.wav":     random,
+	}
+	golden := []struct {
+		implode, encrypt bool
+		sectorSizeShift  uint16
+	}{
+		// Stored files.
+		{implode: false, encrypt: false, sectorSizeShift: 3},
+		// Imploded files.
+		{implode: true, encrypt: false, sectorSizeShift: 3},
+		// Encrypted files.
+		{implode: false, encrypt: true, sectorSizeShift: 3},
+		// Imploded and encrypted files, using several sectors per file.
+		{implode: true, encrypt: true, sectorSizeShift: 3},
+		{implode: true, encrypt: true, sectorSizeShift: 0},
+	}
+	for i, g := range golden {
+		buf := new(bytes.Buffer)
+		mw := NewWriter(buf)
+		mw.Implode, mw.Encrypt, mw.SectorSizeShift = g.implode, g.encrypt, g.sectorSizeShift
+		for name, data := range files {
+			w, err := mw.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write(data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := mw.Create(`Levels\L1Data\L1.TIL`); err == nil {
+			t.Errorf("i=%d: expected error for duplicate file name", i)
+		}
+		if err := mw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		mr, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("i=%d: %v", i, err)
+		}
+		for name, want := range files {
+			got, err := mr.ReadFile(name)
+			if err != nil {
+				t.Errorf("i=%d: %v", i, err)
+				continue
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("i=%d: contents mismatch of %q", i, name)
+			}
+			block, _ := mr.lookup(archiveName(name))
+			if g.implode && len(want) > 0 && block.Flags&flagImplode == 0 {
+				t.Errorf("i=%d: %q not imploded", i, name)
+			}
+			if g.encrypt && block.Flags&flagEncrypted == 0 {
+				t.Errorf("i=%d: %q not encrypted", i, name)
+			}
+			if !g.implode && !g.encrypt && block.CompressedSize != block.FileSize {
+				t.Errorf("i=%d: %q not stored as is", i, name)
+			}
+		}
+		if !mr.Has("(listfile)") {
+			t.Errorf("i=%d: listfile not found", i)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Package pkware implements reading and writing of data compressed using the
+// PKWARE Data Compression Library (DCL) "implode" algorithm.
+//
+// The DCL format is used by Storm to compress the sectors of MPQ archives and
+// the save files of Diablo. Below is a description of the compressed format.
+// The data is read as a bit stream, starting with the least significant bit of
+// each byte. Huffman codes are stored inverted, most significant bit first.
+//
+// Compressed format:
+//    // mode specifies if literals are uncoded (Binary) or coded (ASCII).
+//    mode uint8
+//    // dictBits specifies the dictionary size; 4 (1K), 5 (2K) or 6 (4K).
+//    dictBits uint8
+//    // data contains the bit stream of literals and length-distance pairs,
+//    // terminated by the end code (length 519).
+//    data []byte
+//
+// Literal format:
+//    flag    1 bit // 0
+//    // literal is stored as 8 bits in Binary mode and as a Huffman code in
+//    // ASCII mode.
+//    literal []bit
+//
+// Length-distance pair format:
+//    flag  1 bit // 1
+//    // length = lenBase[lenSymbol] + lenExtra
+//    lenSymbol  Huffman code
+//    lenExtra   [lenExtraBits[lenSymbol]]bit
+//    // distance = (distSymbol<<n | distLow) + 1, where n is 2 for a length of
+//    // 2 and dictBits otherwise.
+//    distSymbol Huffman code
+//    distLow    [n]bit
+//
+// The implementation of the decompressor is based on Mark Adler's blast.c.
+package pkware
+
+// Mode specifies how literals are stored.
+type Mode uint8
+
+// Literal modes.
+const (
+	// Binary stores literals uncoded, as 8 bits each.
+	Binary Mode = 0
+	// ASCII stores literals using a Huffman code optimized for text.
+	ASCII Mode = 1
+)
+
+// Dictionary sizes.
+const (
+	DictSize1K = 1024
+	DictSize2K = 2048
+	DictSize4K = 4096
+)
+
+// Length limits of length-distance pairs.
+const (
+	minLen = 2
+	maxLen = 518
+	// endLen is the length used by the end code.
+	endLen = 519
+)
+
+// maxBits is the maximum length of a Huffman code.
+const maxBits = 13
+
+// huffman is a canonical Huffman code.
+type huffman struct {
+	// count holds the number of symbols of each code length.
+	count [maxBits + 1]int
+	// symbol holds the symbols ordered by code length and value.
+	symbol []int
+	// code and codeLen hold the code and code length of each symbol; used for
+	// compression.
+	code    []int
+	codeLen []int
+}
+
+// newHuffman returns a canonical Huffman code based on the compacted code
+// lengths in rep. The high nibble of each byte holds the number of repeats
+// minus one, and the low nibble holds the code length.
+func newHuffman(rep []byte) *huffman {
+	var lengths []int
+	for _, b := range rep {
+		n := int(b>>4) + 1
+		for i := 0; i < n; i++ {
+			lengths = append(lengths, int(b&0x0F))
+		}
+	}
+	h := &huffman{
+		symbol:  make([]int, len(lengths)),
+		code:    make([]int, len(lengths)),
+		codeLen: lengths,
+	}
+	for _, l := range lengths {
+		h.count[l]++
+	}
+	var offs [maxBits + 1]int
+	for l := 1; l < maxBits; l++ {
+		offs[l+1] = offs[l] + h.count[l]
+	}
+	for symbol, l := range lengths {
+		if l != 0 {
+			h.symbol[offs[l]] = symbol
+			offs[l]++
+		}
+	}
+	// Assign codes in the same order as they are decoded.
+	first, index := 0, 0
+	for l := 1; l <= maxBits; l++ {
+		for i := 0; i < h.count[l]; i++ {
+			h.code[h.symbol[index+i]] = first + i
+		}
+		index += h.count[l]
+		first = (first + h.count[l]) << 1
+	}
+	return h
+}
+
+// Code lengths of the literal, length and distance codes, in compacted form.
+var (
+	litLen = []byte{
+		11, 124, 8, 7, 28, 7, 188, 13, 76, 4, 10, 8, 12, 10, 12, 10, 8, 23, 8,
+		9, 7, 6, 7, 8, 7, 6, 55, 8, 23, 24, 12, 11, 7, 9, 11, 12, 6, 7, 22, 5,
+		7, 24, 6, 11, 9, 6, 7, 22, 7, 11, 38, 7, 9, 8, 25, 11, 8, 11, 9, 12,
+		8, 12, 5, 38, 5, 38, 5, 11, 7, 5, 6, 21, 6, 10, 53, 8, 7, 24, 10, 27,
+		44, 253, 253, 253, 252, 252, 252, 13, 12, 45, 12, 45, 12, 61, 12, 45,
+		44, 173,
+	}
+	lenLen  = []byte{2, 35, 36, 53, 38, 23}
+	distLen = []byte{2, 20, 53, 230, 247, 151, 248}
+)
+
+// Huffman codes of literals, lengths and distances.
+var (
+	litHuff  = newHuffman(litLen)
+	lenHuff  = newHuffman(lenLen)
+	distHuff = newHuffman(distLen)
+)
+
+// Base values and number of extra bits of the length symbols.
+var (
+	lenBase      = [16]int{3, 2, 4, 5, 6, 7, 8, 9, 10, 12, 16, 24, 40, 72, 136, 264}
+	lenExtraBits = [16]int{0, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8}
+)
+
+// dictBits returns the number of low distance bits for the given dictionary
+// size.
+func dictBits(dictSize int) (n int, ok bool) {
+	switch dictSize {
+	case DictSize1K:
+		return 4, true
+	case DictSize2K:
+		return 5, true
+	case DictSize4K:
+		return 6, true
+	}
+	return 0, false
+}
@@ -0,0 +1,119 @@
+package pkware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// The example data of the blast decompressor of zlib, compressed by the PKWARE
+// library using binary mode and a dictionary size of 1024.
+var (
+	blastPlain      = []byte("AIAIAIAIAIAIA")
+	blastCompressed = []byte{0x00, 0x04, 0x82, 0x24, 0x25, 0x8F, 0x80, 0x7F}
+)
+
+func TestDecompress(t *testing.T) {
+	got, err := Decompress(blastCompressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, blastPlain) {
+		t.Fatalf("decompressed data mismatch; expected %q, got %q", blastPlain, got)
+	}
+
+	// Invalid data.
+	golden := [][]byte{
+		// Invalid literal mode.
+		{0x02, 0x04, 0x82, 0x24, 0x25, 0x8F, 0x80, 0x7F},
+		// Invalid dictionary size.
+		{0x00, 0x07, 0x82, 0x24, 0x25, 0x8F, 0x80, 0x7F},
+		// Missing end of stream.
+		blastCompressed[:5],
+	}
+	for i, src := range golden {
+		if _, err := Decompress(src); err == nil {
+			t.Errorf("i=%d: expected error", i)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	got, err := Compress(blastPlain, Binary, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, blastCompressed) {
+		t.Fatalf("compressed data mismatch; expected % X, got % X", blastCompressed, got)
+	}
+	if _, err := Compress(blastPlain, Binary, 8192); err == nil {
+		t.Fatal("expected error for invalid dictionary size")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := make([]byte, 50000)
+	r.Read(random)
+	small := make([]byte, 100000)
+	for i := range small {
+		small[i] = byte(r.Intn(4))
+	}
+	inputs := [][]byte{
+		nil,
+		[]byte("a"),
+		[]byte("ab"),
+		[]byte(strings.Repeat("hello world, ", 1000)),
+		random,
+		small,
+	}
+	for _, src := range inputs {
+		for _, mode := range []Mode{Binary, ASCII} {
+			for _, dictSize := range []int{1024, 2048, 4096} {
+				dst, err := Compress(src, mode, dictSize)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := Decompress(dst)
+				if err != nil {
+					t.Fatalf("len=%d, mode=%d, dictSize=%d: %v", len(src), mode, dictSize, err)
+				}
+				if !bytes.Equal(got, src) {
+					t.Fatalf("len=%d, mode=%d, dictSize=%d: decompressed data mismatch", len(src), mode, dictSize)
+				}
+
+				// Compress using several writes, and decompress using a Reader.
+				buf := new(bytes.Buffer)
+				zw, err := NewWriter(buf, mode, dictSize)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < len(src); i += 777 {
+					end := i + 777
+					if end > len(src) {
+						end = len(src)
+					}
+					if _, err := zw.Write(src[i:end]); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := zw.Close(); err != nil {
+					t.Fatal(err)
+				}
+				zr, err := NewReader(buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err = ioutil.ReadAll(zr)
+				if err != nil {
+					t.Fatalf("len=%d, mode=%d, dictSize=%d: %v", len(src), mode, dictSize, err)
+				}
+				if !bytes.Equal(got, src) {
+					t.Fatalf("len=%d, mode=%d, dictSize=%d: decompressed data mismatch", len(src), mode, dictSize)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package pkware
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A Reader decompresses data read from an underlying reader.
+type Reader struct {
+	r    io.ByteReader
+	mode Mode
+	// dictBits is the number of low distance bits.
+	dictBits int
+	// bitBuf holds bitCount unread bits.
+	bitBuf   int
+	bitCount int
+	// hist holds the decompressed data; the bytes starting at pos have not yet
+	// been returned by Read.
+	hist []byte
+	pos  int
+	err  error
+}
+
+// NewReader returns a new Reader which decompresses the data read from r. The
+// literal mode and the dictionary size are read from the header of the
+// compressed data.
+func NewReader(r io.Reader) (*Reader, error) {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	zr := &Reader{r: br}
+	mode, err := br.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("pkware.NewReader: unable to read literal mode: %v", err)
+	}
+	dict, err := br.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("pkware.NewReader: unable to read dictionary size: %v", err)
+	}
+	zr.mode = Mode(mode)
+	if zr.mode != Binary && zr.mode != ASCII {
+		return nil, fmt.Errorf("pkware.NewReader: invalid literal mode (%d)", mode)
+	}
+	zr.dictBits = int(dict)
+	if zr.dictBits < 4 || zr.dictBits > 6 {
+		return nil, fmt.Errorf("pkware.NewReader: invalid dictionary size (%d)", dict)
+	}
+	return zr, nil
+}
+
+// Decompress decompresses the provided data.
+func Decompress(src []byte) (dst []byte, err error) {
+	zr, err := NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	for zr.err == nil {
+		zr.step()
+	}
+	if zr.err != io.EOF {
+		return nil, zr.err
+	}
+	return zr.hist, nil
+}
+
+// Read implements the io.Reader interface.
+func (zr *Reader) Read(p []byte) (n int, err error) {
+	for len(zr.hist)-zr.pos < len(p) && zr.err == nil {
+		zr.step()
+	}
+	n = copy(p, zr.hist[zr.pos:])
+	zr.pos += n
+	if n == 0 && len(p) > 0 {
+		return 0, zr.err
+	}
+	// Only the last 4K of history are referenced by length-distance pairs, so
+	// drop the bytes which have already been read.
+	if zr.pos > 2*DictSize4K {
+		drop := zr.pos
+		if max := len(zr.hist) - DictSize4K; drop > max {
+			drop = max
+		}
+		zr.hist = append(zr.hist[:0], zr.hist[drop:]...)
+		zr.pos -= drop
+	}
+	return n, nil
+}
+
+// ErrTruncated is returned when the end of the compressed data is reached
+// before the end code.
+var ErrTruncated = errors.New("pkware: unexpected end of compressed data")
+
+// step decodes one literal or length-distance pair and appends the result to
+// the history. At the end code, zr.err is set to io.EOF.
+func (zr *Reader) step() {
+	bit, err := zr.bits(1)
+	if err != nil {
+		zr.err = err
+		return
+	}
+	if bit == 0 {
+		// Literal.
+		var c int
+		if zr.mode == ASCII {
+			c, err = zr.decode(litHuff)
+		} else {
+			c, err = zr.bits(8)
+		}
+		if err != nil {
+			zr.err = err
+			return
+		}
+		zr.hist = append(zr.hist, byte(c))
+		return
+	}
+
+	// Length-distance pair.
+	symbol, err := zr.decode(lenHuff)
+	if err != nil {
+		zr.err = err
+		return
+	}
+	extra, err := zr.bits(lenExtraBits[symbol])
+	if err != nil {
+		zr.err = err
+		return
+	}
+	n := lenBase[symbol] + extra
+	if n == endLen {
+		zr.err = io.EOF
+		return
+	}
+	shift := zr.dictBits
+	if n == minLen {
+		shift = 2
+	}
+	symbol, err = zr.decode(distHuff)
+	if err != nil {
+		zr.err = err
+		return
+	}
+	low, err := zr.bits(shift)
+	if err != nil {
+		zr.err = err
+		return
+	}
+	dist := symbol<<uint(shift) + low + 1
+	if dist > len(zr.hist) {
+		zr.err = fmt.Errorf("pkware: distance (%d) too far back", dist)
+		return
+	}
+	// The copy may overlap with the bytes being produced.
+	start := len(zr.hist) - dist
+	for i := 0; i < n; i++ {
+		zr.hist = append(zr.hist, zr.hist[start+i])
+	}
+}
+
+// bits reads n bits and returns them as an integer, with the first bit read in
+// the least significant position.
+func (zr *Reader) bits(n int) (x int, err error) {
+	for zr.bitCount < n {
+		b, err := zr.r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return 0, ErrTruncated
+			}
+			return 0, err
+		}
+		zr.bitBuf |= int(b) << uint(zr.bitCount)
+		zr.bitCount += 8
+	}
+	x = zr.bitBuf & (1<<uint(n) - 1)
+	zr.bitBuf >>= uint(n)
+	zr.bitCount -= n
+	return x, nil
+}
+
+// decode decodes one symbol using the provided Huffman code.
+func (zr *Reader) decode(h *huffman) (symbol int, err error) {
+	code, first, index := 0, 0, 0
+	for l := 1; l <= maxBits; l++ {
+		bit, err := zr.bits(1)
+		if err != nil {
+			return 0, err
+		}
+		code |= bit ^ 1
+		count := h.count[l]
+		if code < first+count {
+			return h.symbol[index+code-first], nil
+		}
+		index += count
+		first += count
+		first <<= 1
+		code <<= 1
+	}
+	return 0, errors.New("pkware: invalid Huffman code")
+}
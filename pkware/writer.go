@@ -0,0 +1,307 @@
+package pkware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A Writer compresses the data written to it and writes the compressed data to
+// an underlying writer.
+//
+// The compressed data is valid DCL data, which may be decompressed by any
+// conforming implementation. It is not guaranteed to be identical to the output
+// of the PKWARE library for the same input.
+type Writer struct {
+	w        io.Writer
+	mode     Mode
+	dictSize int
+	dictBits int
+	// buf holds the data to compress. The bytes before pos have already been
+	// compressed and are kept as dictionary.
+	buf []byte
+	pos int
+	// head and prev are hash chains of the positions in buf, keyed by the two
+	// bytes located at each position.
+	head map[int]int
+	prev []int
+	// out holds the compressed data not yet written to w.
+	out      []byte
+	bitBuf   uint32
+	bitCount uint
+	closed   bool
+	err      error
+}
+
+// NewWriter returns a new Writer which compresses the data written to it using
+// the given literal mode and dictionary size (1024, 2048 or 4096). The data is
+// written to w once enough input is available, and the remaining data is
+// flushed when the Writer is closed.
+func NewWriter(w io.Writer, mode Mode, dictSize int) (*Writer, error) {
+	if mode != Binary && mode != ASCII {
+		return nil, fmt.Errorf("pkware.NewWriter: invalid literal mode (%d)", mode)
+	}
+	n, ok := dictBits(dictSize)
+	if !ok {
+		return nil, fmt.Errorf("pkware.NewWriter: invalid dictionary size (%d)", dictSize)
+	}
+	zw := &Writer{
+		w:        w,
+		mode:     mode,
+		dictSize: dictSize,
+		dictBits: n,
+		head:     make(map[int]int),
+	}
+	zw.out = append(zw.out, byte(mode), byte(n))
+	return zw, nil
+}
+
+// Compress compresses the provided data using the given literal mode and
+// dictionary size.
+func Compress(src []byte, mode Mode, dictSize int) (dst []byte, err error) {
+	buf := new(bytes.Buffer)
+	zw, err := NewWriter(buf, mode, dictSize)
+	if err != nil {
+		return nil, err
+	}
+	_, err = zw.Write(src)
+	if err != nil {
+		return nil, err
+	}
+	err = zw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// errClosed is returned when writing to a closed Writer.
+var errClosed = errors.New("pkware: write to closed Writer")
+
+// Write implements the io.Writer interface.
+func (zw *Writer) Write(p []byte) (n int, err error) {
+	if zw.closed {
+		return 0, errClosed
+	}
+	if zw.err != nil {
+		return 0, zw.err
+	}
+	zw.buf = append(zw.buf, p...)
+	// Keep enough lookahead to find matches of the maximum length.
+	zw.compress(len(zw.buf) - maxLen)
+	zw.flush()
+	if zw.err != nil {
+		return 0, zw.err
+	}
+	return len(p), nil
+}
+
+// Close compresses the remaining data, writes the end code and flushes the
+// compressed data to the underlying writer.
+func (zw *Writer) Close() error {
+	if zw.closed {
+		return zw.err
+	}
+	zw.closed = true
+	if zw.err != nil {
+		return zw.err
+	}
+	zw.compress(len(zw.buf))
+	// End code.
+	zw.writeBits(1, 1)
+	zw.writeLen(endLen)
+	if zw.bitCount > 0 {
+		zw.out = append(zw.out, byte(zw.bitBuf))
+		zw.bitBuf, zw.bitCount = 0, 0
+	}
+	zw.flush()
+	return zw.err
+}
+
+// maxChain is the maximum number of hash chain entries searched for each
+// position.
+const maxChain = 256
+
+// compress compresses the buffered data up to end.
+func (zw *Writer) compress(end int) {
+	for zw.pos < end {
+		n, dist := zw.findMatch(zw.pos)
+		if n >= minLen && zw.matchCost(n, dist) < zw.litCost(zw.pos, n) {
+			zw.writeBits(1, 1)
+			zw.writeLen(n)
+			zw.writeDist(n, dist)
+			for i := 0; i < n; i++ {
+				zw.insert(zw.pos)
+				zw.pos++
+			}
+			continue
+		}
+		zw.writeBits(0, 1)
+		zw.writeLit(zw.buf[zw.pos])
+		zw.insert(zw.pos)
+		zw.pos++
+	}
+	zw.slide()
+}
+
+// slide drops the data which is no longer within reach of the dictionary.
+func (zw *Writer) slide() {
+	drop := zw.pos - zw.dictSize
+	if drop < 2*zw.dictSize {
+		return
+	}
+	zw.buf = append(zw.buf[:0], zw.buf[drop:]...)
+	zw.prev = append(zw.prev[:0], zw.prev[drop:]...)
+	for i := range zw.prev {
+		zw.prev[i] -= drop
+	}
+	for key, pos := range zw.head {
+		if pos < drop {
+			delete(zw.head, key)
+		} else {
+			zw.head[key] = pos - drop
+		}
+	}
+	zw.pos -= drop
+}
+
+// insert adds pos to the hash chains.
+func (zw *Writer) insert(pos int) {
+	if pos+1 >= len(zw.buf) {
+		// The last byte has no successor to form a key; keep prev aligned.
+		zw.prev = append(zw.prev, -1)
+		return
+	}
+	key := int(zw.buf[pos])<<8 | int(zw.buf[pos+1])
+	p, ok := zw.head[key]
+	if !ok {
+		p = -1
+	}
+	zw.prev = append(zw.prev, p)
+	zw.head[key] = pos
+}
+
+// findMatch returns the length and distance of the longest match of the data
+// at pos within the dictionary.
+func (zw *Writer) findMatch(pos int) (n, dist int) {
+	if pos+minLen > len(zw.buf) {
+		return 0, 0
+	}
+	limit := len(zw.buf) - pos
+	if limit > maxLen {
+		limit = maxLen
+	}
+	key := int(zw.buf[pos])<<8 | int(zw.buf[pos+1])
+	cand, ok := zw.head[key]
+	for i := 0; ok && cand >= 0 && i < maxChain; i++ {
+		d := pos - cand
+		if d > zw.dictSize {
+			break
+		}
+		l := 0
+		for l < limit && zw.buf[cand+l] == zw.buf[pos+l] {
+			l++
+		}
+		// Matches of length 2 are limited to a distance of 256.
+		if l > n && (l > minLen || d <= 256) {
+			n, dist = l, d
+			if n == limit {
+				break
+			}
+		}
+		cand = zw.prev[cand]
+	}
+	return n, dist
+}
+
+// matchCost returns the number of bits required to store a length-distance
+// pair.
+func (zw *Writer) matchCost(n, dist int) int {
+	symbol := lenSymbol(n)
+	shift := zw.dictBits
+	if n == minLen {
+		shift = 2
+	}
+	return 1 + lenHuff.codeLen[symbol] + lenExtraBits[symbol] + distHuff.codeLen[(dist-1)>>uint(shift)] + shift
+}
+
+// litCost returns the number of bits required to store the n bytes at pos as
+// literals.
+func (zw *Writer) litCost(pos, n int) (cost int) {
+	for i := 0; i < n; i++ {
+		if zw.mode == ASCII {
+			cost += 1 + litHuff.codeLen[zw.buf[pos+i]]
+		} else {
+			cost += 1 + 8
+		}
+	}
+	return cost
+}
+
+// lenSymbol returns the length symbol of n.
+func lenSymbol(n int) int {
+	for symbol := len(lenBase) - 1; symbol >= 0; symbol-- {
+		base := lenBase[symbol]
+		if n >= base && n < base+1<<uint(lenExtraBits[symbol]) {
+			return symbol
+		}
+	}
+	panic(fmt.Sprintf("pkware: invalid length (%d)", n))
+}
+
+// writeLit writes a literal.
+func (zw *Writer) writeLit(c byte) {
+	if zw.mode == ASCII {
+		zw.writeCode(litHuff, int(c))
+		return
+	}
+	zw.writeBits(uint32(c), 8)
+}
+
+// writeLen writes the length of a length-distance pair.
+func (zw *Writer) writeLen(n int) {
+	symbol := lenSymbol(n)
+	zw.writeCode(lenHuff, symbol)
+	zw.writeBits(uint32(n-lenBase[symbol]), uint(lenExtraBits[symbol]))
+}
+
+// writeDist writes the distance of a length-distance pair of length n.
+func (zw *Writer) writeDist(n, dist int) {
+	shift := uint(zw.dictBits)
+	if n == minLen {
+		shift = 2
+	}
+	zw.writeCode(distHuff, (dist-1)>>shift)
+	zw.writeBits(uint32(dist-1)&(1<<shift-1), shift)
+}
+
+// writeCode writes the Huffman code of symbol; inverted, most significant bit
+// first.
+func (zw *Writer) writeCode(h *huffman, symbol int) {
+	code, n := h.code[symbol], h.codeLen[symbol]
+	for i := n - 1; i >= 0; i-- {
+		zw.writeBits(uint32(code>>uint(i)&1^1), 1)
+	}
+}
+
+// writeBits writes the n least significant bits of x, least significant bit
+// first.
+func (zw *Writer) writeBits(x uint32, n uint) {
+	zw.bitBuf |= x << zw.bitCount
+	zw.bitCount += n
+	for zw.bitCount >= 8 {
+		zw.out = append(zw.out, byte(zw.bitBuf))
+		zw.bitBuf >>= 8
+		zw.bitCount -= 8
+	}
+}
+
+// flush writes the compressed data to the underlying writer.
+func (zw *Writer) flush() {
+	if zw.err != nil || len(zw.out) == 0 {
+		return
+	}
+	_, zw.err = zw.w.Write(zw.out)
+	zw.out = zw.out[:0]
+}
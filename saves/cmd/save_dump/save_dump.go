@@ -0,0 +1,211 @@
+// save_dump is a tool for printing the heroes of save files as JSON, and for
+// storing paper-doll png images of their inventories.
+//
+// Usage:
+//
+//    save_dump [OPTION]... [single_N.sv|multi_N.sv]...
+//
+// Flags:
+//
+//    -exe=""
+//            Path to Diablo.exe, from which the base item information is
+//            extracted instead of using the items ini file.
+//    -imgini="cel.ini"
+//            Path to an ini file containing image information.
+//    -itemini="items.ini"
+//            Path to an ini file containing base item information.
+//    -mpq=""
+//            Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of
+//            MPQ archives and directories in order of priority.
+//    -mpqdump="mpqdump/"
+//            Path to an extracted MPQ file.
+//    -mpqini="mpq.ini"
+//            Path to an ini file containing relative path information.
+//    -png=false
+//            Store a paper-doll png image of each hero.
+//
+// The paper-doll images are stored in the "_dump_/_saves_/" directory, and
+// named after the save files (e.g. single_0.png). Items without an inventory
+// icon in the items ini file are drawn as outlines. The items ini file only
+// contains gold; use the -exe flag to draw the icons of every base item.
+//
+// Example:
+//
+//    $ save_dump -mpq=diabdat.mpq -exe=diablo.exe -png single_0.sv
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mewkiz/pkg/imgutil"
+	"github.com/mewrnd/blizzconv/images/cel"
+	"github.com/mewrnd/blizzconv/images/imgconf"
+	"github.com/mewrnd/blizzconv/mpq"
+	"github.com/mewrnd/blizzconv/saves/itemconf"
+	"github.com/mewrnd/blizzconv/saves/save"
+)
+
+// flagPNG specifies if paper-doll images should be stored or not.
+var flagPNG bool
+
+// Paths specified by command line flags.
+var imgIniPath, itemIniPath, exePath, archivePath, extractPath, mpqIniPath string
+
+func init() {
+	flag.Usage = usage
+	flag.StringVar(&exePath, "exe", "", "Path to Diablo.exe, from which the base item information is extracted instead of using the items ini file.")
+	flag.StringVar(&imgIniPath, "imgini", "cel.ini", "Path to an ini file containing image information.")
+	flag.StringVar(&itemIniPath, "itemini", "items.ini", "Path to an ini file containing base item information.")
+	flag.StringVar(&archivePath, "mpq", "", "Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of MPQ archives and directories in order of priority.")
+	flag.StringVar(&extractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
+	flag.StringVar(&mpqIniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
+	flag.BoolVar(&flagPNG, "png", false, "Store a paper-doll png image of each hero.")
+	flag.Parse()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]... [single_N.sv|multi_N.sv]...\n", os.Args[0])
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+var (
+	// archive provides access to the files of the MPQ archive; only used for
+	// paper-doll images.
+	archive *mpq.Archive
+	// fsys is the file system of the MPQ archive.
+	fsys fs.FS
+	// imgConf provides image information.
+	imgConf *imgconf.Config
+	// itemConf provides base item information.
+	itemConf *itemconf.Config
+	// icons contains the frames of objcurs.cel.
+	icons []image.Image
+)
+
+func main() {
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if flagPNG {
+		var err error
+		archive, err = mpq.OpenArchive(extractPath, mpqIniPath, strings.Split(archivePath, ",")...)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer archive.Close()
+		fsys = archive.FS()
+		imgConf, err = imgconf.Load(imgIniPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		itemConf, err = itemconf.Load(itemIniPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		icons, err = decodeFrames("objcurs.cel")
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	for _, savePath := range flag.Args() {
+		err := saveDump(savePath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
+
+// dumpPrefix is the name of the dump directory.
+const dumpPrefix = "_dump_/"
+
+// saveDump prints the hero of the save file located at savePath as JSON, and
+// stores a paper-doll image of the hero if the -png flag is set.
+func saveDump(savePath string) (err error) {
+	hero, err := save.ReadHero(savePath)
+	if err != nil {
+		return err
+	}
+	buf, err := json.MarshalIndent(hero, "", "\t")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(buf))
+	if !flagPNG {
+		return nil
+	}
+	invName := "inv.cel"
+	switch hero.Class {
+	case "Rogue":
+		invName = "inv_rog.cel"
+	case "Sorcerer":
+		invName = "inv_sor.cel"
+	}
+	bgs, err := decodeFrames(invName)
+	if err != nil {
+		return err
+	}
+	dumpDir := dumpPrefix + "_saves_/"
+	err = os.MkdirAll(dumpDir, 0755)
+	if err != nil {
+		return err
+	}
+	saveName := filepath.Base(savePath)
+	pngPath := dumpDir + saveName[:len(saveName)-len(filepath.Ext(saveName))] + ".png"
+	img := hero.PaperDoll(bgs[0], icon)
+	return imgutil.WriteFile(pngPath, img)
+}
+
+// icon returns the inventory icon of the item, or nil if not present.
+func icon(item *save.Item) image.Image {
+	curs, found := itemConf.GetCurs(item.Idx)
+	if !found {
+		return nil
+	}
+	// The icon of gold depends on the amount of gold.
+	if item.Idx == 0 {
+		switch {
+		case item.Value > 2500:
+			curs += 2
+		case item.Value > 1000:
+			curs++
+		}
+	}
+	frameNum := curs + 11
+	if frameNum < 0 || frameNum >= len(icons) {
+		return nil
+	}
+	return icons[frameNum]
+}
+
+// decodeFrames decodes the frames of the given CEL image, using its first
+// palette.
+func decodeFrames(imgName string) (imgs []image.Image, err error) {
+	relImgPath, err := archive.GetRelPath(imgName)
+	if err != nil {
+		return nil, err
+	}
+	relPalPath := imgConf.GetRelPalPathsFor(fsys, imgName, relImgPath)[0]
+	conf, err := cel.GetConf(fsys, imgConf, imgName, relPalPath)
+	if err != nil {
+		return nil, err
+	}
+	imgs, err = cel.DecodeAll(fsys, relImgPath, conf)
+	if err != nil {
+		return nil, err
+	}
+	if len(imgs) < 1 {
+		return nil, fmt.Errorf("no frames in %q.", imgName)
+	}
+	return imgs, nil
+}
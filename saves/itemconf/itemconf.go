@@ -0,0 +1,75 @@
+// Package itemconf implements functions for retrieving relevant information
+// about the base items of the game, such as their names and inventory icons.
+//
+// This information is stored in an ini file, since the save files only contain
+// the index of each base item. The sections of the ini file are named after the
+// base item indices. Alternatively, the information is extracted from the base
+// item table of Diablo.exe (see LoadExe).
+package itemconf
+
+import (
+	"strconv"
+
+	"github.com/mewbak/goini"
+	"github.com/mewrnd/blizzconv/configs/exe"
+)
+
+// A Config provides base item information.
+type Config struct {
+	dict ini.Dict
+	// recs contains the records of the base item table, indexed by base item
+	// index; only used if loaded by LoadExe.
+	recs []exe.Record
+}
+
+// Load loads the ini file located at iniPath, which provides base item
+// information.
+func Load(iniPath string) (conf *Config, err error) {
+	dict, err := ini.Load(iniPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{dict: dict}, nil
+}
+
+// LoadExe loads the base item table of the PE executable located at exePath
+// (e.g. Diablo.exe), which provides base item information.
+func LoadExe(exePath string) (conf *Config, err error) {
+	f, err := exe.Open(exePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	va, err := f.Locate(exe.Items)
+	if err != nil {
+		return nil, err
+	}
+	recs, err := f.Decode(exe.Items, va, -1)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{recs: recs}, nil
+}
+
+// GetName returns the name of a given base item.
+func (conf *Config) GetName(itemIdx int) (name string, found bool) {
+	if conf.recs != nil {
+		if itemIdx < 0 || itemIdx >= len(conf.recs) {
+			return "", false
+		}
+		return conf.recs[itemIdx]["name"].(string), true
+	}
+	return conf.dict.GetString(strconv.Itoa(itemIdx), "name")
+}
+
+// GetCurs returns the inventory icon of a given base item. The icon is the item
+// cursor of the game; frame curs+11 of objcurs.cel, with frames starting at 0.
+func (conf *Config) GetCurs(itemIdx int) (curs int, found bool) {
+	if conf.recs != nil {
+		if itemIdx < 0 || itemIdx >= len(conf.recs) {
+			return 0, false
+		}
+		return conf.recs[itemIdx]["curs"].(int), true
+	}
+	return conf.dict.GetInt(strconv.Itoa(itemIdx), "curs")
+}
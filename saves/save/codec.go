@@ -0,0 +1,101 @@
+package save
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Passwords of the save files.
+const (
+	// PasswordSingle is the password of single player save files (single_N.sv).
+	PasswordSingle = "xrgyrkj1"
+	// PasswordMulti is the password of multiplayer save files (multi_N.sv).
+	PasswordMulti = "szqnlsk1"
+)
+
+// sigSize is the size of the codec signature in bytes.
+//
+// Signature format:
+//    // checksum is the first 4 bytes of the digest of the decoded data.
+//    checksum      uint32
+//    err           uint8
+//    // lastChunkSize is the number of used bytes in the last block.
+//    lastChunkSize uint8
+//    _             uint16
+const sigSize = 8
+
+// Decode decodes the data of a save file encrypted using the given password,
+// and returns the decoded data. The data is decrypted block by block; each
+// block is XORed with the digest of the preceding decoded blocks, and followed
+// by a signature.
+func Decode(data []byte, password string) (buf []byte, err error) {
+	if len(password) == 0 {
+		return nil, errors.New("save.Decode: empty password")
+	}
+	if len(data) <= sigSize || (len(data)-sigSize)%shaBlockSize != 0 {
+		return nil, errors.New("save.Decode: invalid data size")
+	}
+	var ctx shaContext
+	ctx.init(password)
+	n := len(data) - sigSize
+	buf = make([]byte, n)
+	for i := 0; i < n; i += shaBlockSize {
+		digest := ctx.digest()
+		block := buf[i : i+shaBlockSize]
+		for j := range block {
+			block[j] = data[i+j] ^ digest[j%shaDigestSize]
+		}
+		ctx.write(block)
+	}
+	sig := data[n:]
+	if sig[4] != 0 {
+		return nil, errors.New("save.Decode: signature error set")
+	}
+	digest := ctx.digest()
+	if binary.LittleEndian.Uint32(sig) != binary.LittleEndian.Uint32(digest[:]) {
+		return nil, errors.New("save.Decode: checksum mismatch; invalid password")
+	}
+	lastChunkSize := int(sig[5])
+	if lastChunkSize > shaBlockSize {
+		return nil, errors.New("save.Decode: invalid last chunk size")
+	}
+	return buf[:n-shaBlockSize+lastChunkSize], nil
+}
+
+// init initializes the context using a key derived from the password and the
+// pseudo-random numbers of the game.
+func (ctx *shaContext) init(password string) {
+	var key [136]byte
+	rnd := newRand(0x7058)
+	for i := range key {
+		key[i] = byte(rnd.next())
+	}
+	var pw [64]byte
+	for i := range pw {
+		pw[i] = password[i%len(password)]
+	}
+	ctx.reset()
+	ctx.write(pw[:])
+	digest := ctx.digest()
+	for i := range key {
+		key[i] ^= digest[i%shaDigestSize]
+	}
+	ctx.reset()
+	ctx.write(key[72:])
+}
+
+// rand is the pseudo-random number generator of the Microsoft C runtime.
+type rand struct {
+	seed uint32
+}
+
+// newRand returns a new pseudo-random number generator, using the given seed.
+func newRand(seed uint32) *rand {
+	return &rand{seed: seed}
+}
+
+// next returns the next pseudo-random number in the range [0, 0x7FFF].
+func (r *rand) next() int {
+	r.seed = r.seed*214013 + 2531011
+	return int(r.seed>>16) & 0x7FFF
+}
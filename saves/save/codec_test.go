@@ -0,0 +1,96 @@
+package save
+
+import (
+	"bytes"
+	"testing"
+)
+
+// encode encodes the given data using the password; the reverse of Decode.
+func encode(buf []byte, password string) []byte {
+	n := (len(buf) + shaBlockSize - 1) / shaBlockSize * shaBlockSize
+	data := make([]byte, n+sigSize)
+	copy(data, buf)
+	var ctx shaContext
+	ctx.init(password)
+	for i := 0; i < n; i += shaBlockSize {
+		digest := ctx.digest()
+		ctx.write(data[i : i+shaBlockSize])
+		for j := 0; j < shaBlockSize; j++ {
+			data[i+j] ^= digest[j%shaDigestSize]
+		}
+	}
+	digest := ctx.digest()
+	copy(data[n:], digest[:4])
+	data[n+5] = byte(len(buf) - (n - shaBlockSize))
+	return data
+}
+
+func TestDecode(t *testing.T) {
+	golden := []struct {
+		size     int
+		password string
+	}{
+		{size: 1, password: PasswordSingle},
+		{size: 64, password: PasswordSingle},
+		{size: pkPlayerSize, password: PasswordSingle},
+		{size: pkPlayerSize, password: PasswordMulti},
+	}
+	for i, g := range golden {
+		want := make([]byte, g.size)
+		for j := range want {
+			want[j] = byte(j * 7)
+		}
+		data := encode(want, g.password)
+		got, err := Decode(data, g.password)
+		if err != nil {
+			t.Errorf("i=%d: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("i=%d: data mismatch; expected % X, got % X", i, want, got)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	data := encode(make([]byte, pkPlayerSize), PasswordSingle)
+	if _, err := Decode(data, PasswordMulti); err == nil {
+		t.Error("expected error for wrong password")
+	}
+	corrupt := append([]byte(nil), data...)
+	corrupt[100] ^= 1
+	if _, err := Decode(corrupt, PasswordSingle); err == nil {
+		t.Error("expected error for corrupt data")
+	}
+	if _, err := Decode(data[:len(data)-1], PasswordSingle); err == nil {
+		t.Error("expected error for truncated data")
+	}
+}
+
+func TestRand(t *testing.T) {
+	// The random number generator of the C runtime library of Visual C++.
+	r := newRand(1)
+	for i, want := range []int{41, 18467, 6334, 26500, 19169} {
+		if got := r.next(); got != want {
+			t.Errorf("i=%d: random number mismatch; expected %d, got %d", i, want, got)
+		}
+	}
+}
+
+func TestShift(t *testing.T) {
+	golden := []struct {
+		n    uint
+		x    uint32
+		want uint32
+	}{
+		{n: 4, x: 0x12345678, want: 0x23456781},
+		// The right shift is signed.
+		{n: 1, x: 0x80000000, want: 0xFFFFFFFF},
+		{n: 5, x: 0x87654321, want: 0xFFFFFFF0},
+	}
+	for i, g := range golden {
+		if got := shift(g.n, g.x); got != g.want {
+			t.Errorf("i=%d: shift mismatch; expected 0x%08X, got 0x%08X", i, g.want, got)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package save
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// pkItem is the packed item structure of save files.
+type pkItem struct {
+	Seed       uint32
+	CreateInfo uint16
+	Idx        uint16
+	Identified uint8
+	Dur        uint8
+	MaxDur     uint8
+	Charges    uint8
+	MaxCharges uint8
+	Value      uint16
+	Buff       uint32
+}
+
+// pkPlayer is the packed player structure of save files.
+type pkPlayer struct {
+	_           [8]byte
+	DestAction  int8
+	DestParam1  int8
+	DestParam2  int8
+	DungeonLvl  uint8
+	X           uint8
+	Y           uint8
+	TargX       uint8
+	TargY       uint8
+	Name        [32]byte
+	Class       int8
+	BaseStr     uint8
+	BaseMag     uint8
+	BaseDex     uint8
+	BaseVit     uint8
+	Level       int8
+	StatPts     uint8
+	Experience  int32
+	Gold        int32
+	HPBase      int32
+	MaxHPBase   int32
+	ManaBase    int32
+	MaxManaBase int32
+	SpellLvls   [spellCount]int8
+	MemSpells   uint64
+	InvBody     [bodyCount]pkItem
+	InvList     [invCount]pkItem
+	InvGrid     [invCount]int8
+	NumInv      int8
+	SpdList     [beltCount]pkItem
+	TownWarps   int8
+	DungMsgs    int8
+	LvlLoad     int8
+	BattleNet   int8
+	ManaShield  uint8
+	_           [3]byte
+	_           [8]int16
+	DiabloKills uint32
+	_           [7]int32
+}
+
+// pkPlayerSize is the size of the packed player structure in bytes.
+const pkPlayerSize = 1266
+
+// Sizes of the item lists of a hero.
+const (
+	// spellCount is the number of spells, including the unused spell 0.
+	spellCount = 37
+	// bodyCount is the number of equipment slots.
+	bodyCount = 7
+	// invCount is the number of cells of the inventory grid.
+	invCount = 40
+	// invCols is the number of cols of the inventory grid.
+	invCols = 10
+	// beltCount is the number of belt slots.
+	beltCount = 8
+)
+
+// A Hero is the player character of a save file.
+type Hero struct {
+	Name       string `json:"name"`
+	Class      string `json:"class"`
+	Level      int    `json:"level"`
+	Experience int    `json:"experience"`
+	Gold       int    `json:"gold"`
+	// StatPoints is the number of unassigned stat points.
+	StatPoints int `json:"stat_points"`
+	Strength   int `json:"strength"`
+	Magic      int `json:"magic"`
+	Dexterity  int `json:"dexterity"`
+	Vitality   int `json:"vitality"`
+	// The base life and mana of the hero, excluding item bonuses, in whole
+	// points. The game stores them in 1/64ths of a point.
+	Life       int `json:"life"`
+	MaxLife    int `json:"max_life"`
+	Mana       int `json:"mana"`
+	MaxMana    int `json:"max_mana"`
+	DungeonLvl int `json:"dungeon_level"`
+	// Spells contains the spells known by the hero, either memorized or with a
+	// spell level above zero.
+	Spells []Spell `json:"spells"`
+	// Body maps from equipment slots to the equipped items.
+	Body map[string]*Item `json:"body"`
+	// Inventory contains the items of the inventory grid.
+	Inventory []*InvItem `json:"inventory"`
+	// Belt contains the items of the belt, or nil for empty slots.
+	Belt       [beltCount]*Item `json:"belt"`
+	ManaShield bool             `json:"mana_shield"`
+	// DiabloKills is the highest difficulty at which Diablo has been killed.
+	DiabloKills int `json:"diablo_kills"`
+}
+
+// A Spell is a spell known by a hero.
+type Spell struct {
+	Name      string `json:"name"`
+	Level     int    `json:"level"`
+	Memorized bool   `json:"memorized"`
+}
+
+// An Item is an item of a hero. The properties of the item are regenerated by
+// the game based on its seed, creation info and base item index.
+type Item struct {
+	Seed       uint32 `json:"seed"`
+	CreateInfo uint16 `json:"create_info"`
+	// Idx is the index of the base item in the item table of the game.
+	Idx           int  `json:"idx"`
+	Identified    bool `json:"identified"`
+	Durability    int  `json:"durability"`
+	MaxDurability int  `json:"max_durability"`
+	Charges       int  `json:"charges"`
+	MaxCharges    int  `json:"max_charges"`
+	// Value is the amount of gold of gold items.
+	Value int    `json:"value"`
+	Buff  uint32 `json:"buff"`
+}
+
+// An InvItem is an item of the inventory grid.
+type InvItem struct {
+	*Item
+	// The position of the top left cell of the item and its size in cells.
+	Col    int `json:"col"`
+	Row    int `json:"row"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// itemIdxNone is the base item index of empty item slots.
+const itemIdxNone = 0xFFFF
+
+// classNames maps from class numbers to class names.
+var classNames = []string{
+	0: "Warrior",
+	1: "Rogue",
+	2: "Sorcerer",
+}
+
+// BodySlots contains the names of the equipment slots, in the order used by
+// the game.
+var BodySlots = [bodyCount]string{
+	0: "head",
+	1: "left_ring",
+	2: "right_ring",
+	3: "amulet",
+	4: "left_hand",
+	5: "right_hand",
+	6: "chest",
+}
+
+// spellNames maps from spell numbers to spell names.
+var spellNames = [spellCount]string{
+	1:  "Firebolt",
+	2:  "Healing",
+	3:  "Lightning",
+	4:  "Flash",
+	5:  "Identify",
+	6:  "Fire Wall",
+	7:  "Town Portal",
+	8:  "Stone Curse",
+	9:  "Infravision",
+	10: "Phasing",
+	11: "Mana Shield",
+	12: "Fireball",
+	13: "Guardian",
+	14: "Chain Lightning",
+	15: "Flame Wave",
+	16: "Doom Serpents",
+	17: "Blood Ritual",
+	18: "Nova",
+	19: "Invisibility",
+	20: "Inferno",
+	21: "Golem",
+	22: "Blood Boil",
+	23: "Teleport",
+	24: "Apocalypse",
+	25: "Etherealize",
+	26: "Item Repair",
+	27: "Staff Recharge",
+	28: "Trap Disarm",
+	29: "Elemental",
+	30: "Charged Bolt",
+	31: "Holy Bolt",
+	32: "Resurrect",
+	33: "Telekinesis",
+	34: "Heal Other",
+	35: "Blood Star",
+	36: "Bone Spirit",
+}
+
+// DecodeHero decodes the packed player structure contained within buf, as
+// stored in the decoded "hero" file of save files.
+func DecodeHero(buf []byte) (hero *Hero, err error) {
+	if len(buf) != pkPlayerSize {
+		return nil, fmt.Errorf("save.DecodeHero: invalid size (%d) of player structure", len(buf))
+	}
+	pk := new(pkPlayer)
+	err = binary.Read(bytes.NewReader(buf), binary.LittleEndian, pk)
+	if err != nil {
+		return nil, err
+	}
+	if int(pk.Class) < 0 || int(pk.Class) >= len(classNames) {
+		return nil, fmt.Errorf("save.DecodeHero: invalid class (%d)", pk.Class)
+	}
+	name := pk.Name[:]
+	if i := bytes.IndexByte(name, 0); i != -1 {
+		name = name[:i]
+	}
+	hero = &Hero{
+		Name:        string(name),
+		Class:       classNames[pk.Class],
+		Level:       int(pk.Level),
+		Experience:  int(pk.Experience),
+		Gold:        int(pk.Gold),
+		StatPoints:  int(pk.StatPts),
+		Strength:    int(pk.BaseStr),
+		Magic:       int(pk.BaseMag),
+		Dexterity:   int(pk.BaseDex),
+		Vitality:    int(pk.BaseVit),
+		Life:        int(pk.HPBase) >> 6,
+		MaxLife:     int(pk.MaxHPBase) >> 6,
+		Mana:        int(pk.ManaBase) >> 6,
+		MaxMana:     int(pk.MaxManaBase) >> 6,
+		DungeonLvl:  int(pk.DungeonLvl),
+		Body:        make(map[string]*Item),
+		ManaShield:  pk.ManaShield != 0,
+		DiabloKills: int(pk.DiabloKills),
+	}
+
+	// Spells; bit n-1 of MemSpells specifies if spell n is memorized.
+	for spell := 1; spell < spellCount; spell++ {
+		level := int(pk.SpellLvls[spell])
+		memorized := pk.MemSpells&(1<<uint(spell-1)) != 0
+		if level > 0 || memorized {
+			hero.Spells = append(hero.Spells, Spell{Name: spellNames[spell], Level: level, Memorized: memorized})
+		}
+	}
+
+	// Equipment.
+	for i, slot := range BodySlots {
+		if item := newItem(pk.InvBody[i]); item != nil {
+			hero.Body[slot] = item
+		}
+	}
+
+	// Inventory grid; each cell contains the inventory number plus one of the
+	// item located at the cell. The number is positive at the bottom left cell
+	// of the item and negative at its other cells.
+	for i := 0; i < int(pk.NumInv) && i < invCount; i++ {
+		item := newItem(pk.InvList[i])
+		if item == nil {
+			continue
+		}
+		minCol, minRow, maxCol, maxRow := invCols, invCount/invCols, -1, -1
+		for cell, x := range pk.InvGrid {
+			if int(x) != i+1 && int(x) != -(i+1) {
+				continue
+			}
+			col, row := cell%invCols, cell/invCols
+			if col < minCol {
+				minCol = col
+			}
+			if col > maxCol {
+				maxCol = col
+			}
+			if row < minRow {
+				minRow = row
+			}
+			if row > maxRow {
+				maxRow = row
+			}
+		}
+		if maxCol < 0 {
+			// The item is not present in the inventory grid.
+			continue
+		}
+		invItem := &InvItem{
+			Item:   item,
+			Col:    minCol,
+			Row:    minRow,
+			Width:  maxCol - minCol + 1,
+			Height: maxRow - minRow + 1,
+		}
+		hero.Inventory = append(hero.Inventory, invItem)
+	}
+
+	// Belt.
+	for i := range pk.SpdList {
+		hero.Belt[i] = newItem(pk.SpdList[i])
+	}
+	return hero, nil
+}
+
+// newItem returns the item of a packed item structure, or nil for empty item
+// slots.
+func newItem(pk pkItem) *Item {
+	if pk.Idx == itemIdxNone {
+		return nil
+	}
+	return &Item{
+		Seed:          pk.Seed,
+		CreateInfo:    pk.CreateInfo,
+		Idx:           int(pk.Idx),
+		Identified:    pk.Identified != 0,
+		Durability:    int(pk.Dur),
+		MaxDurability: int(pk.MaxDur),
+		Charges:       int(pk.Charges),
+		MaxCharges:    int(pk.MaxCharges),
+		Value:         int(pk.Value),
+		Buff:          pk.Buff,
+	}
+}
@@ -0,0 +1,127 @@
+package save
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewrnd/blizzconv/mpq"
+)
+
+// testPlayer returns the packed player structure of a level 12 rogue, with an
+// equipped item, a 2x3 item in the inventory grid and a potion in the belt.
+func testPlayer() []byte {
+	pk := pkPlayer{Class: 1, Level: 12, BaseStr: 30, HPBase: 70 << 6, MaxHPBase: 80 << 6, MemSpells: 1 << 6}
+	copy(pk.Name[:], "Adria")
+	pk.SpellLvls[1] = 3
+	for i := range pk.InvBody {
+		pk.InvBody[i].Idx = itemIdxNone
+	}
+	for i := range pk.SpdList {
+		pk.SpdList[i].Idx = itemIdxNone
+	}
+	pk.InvBody[4].Idx = 3
+	pk.SpdList[2].Idx = 25
+	pk.NumInv = 1
+	pk.InvList[0].Idx = 24
+	// The 2x3 item is located at col 2, row 1; the bottom left cell contains
+	// the item number and the other cells -1.
+	for row := 1; row <= 3; row++ {
+		for col := 2; col <= 3; col++ {
+			pk.InvGrid[row*invCols+col] = -1
+		}
+	}
+	pk.InvGrid[3*invCols+2] = 1
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, &pk)
+	return buf.Bytes()
+}
+
+// checkHero reports an error if the hero doesn't match testPlayer.
+func checkHero(t *testing.T, hero *Hero) {
+	t.Helper()
+	if hero.Name != "Adria" || hero.Class != "Rogue" || hero.Level != 12 || hero.Strength != 30 {
+		t.Errorf("hero mismatch; got %+v", hero)
+	}
+	if hero.Life != 70 || hero.MaxLife != 80 {
+		t.Errorf("life mismatch; expected 70/80, got %d/%d", hero.Life, hero.MaxLife)
+	}
+	wantSpells := []Spell{{Name: "Firebolt", Level: 3}, {Name: "Town Portal", Memorized: true}}
+	if len(hero.Spells) != len(wantSpells) {
+		t.Fatalf("spells mismatch; expected %v, got %v", wantSpells, hero.Spells)
+	}
+	for i := range wantSpells {
+		if hero.Spells[i] != wantSpells[i] {
+			t.Errorf("spells mismatch; expected %v, got %v", wantSpells, hero.Spells)
+		}
+	}
+	if len(hero.Body) != 1 || hero.Body["left_hand"] == nil || hero.Body["left_hand"].Idx != 3 {
+		t.Errorf("body mismatch; got %v", hero.Body)
+	}
+	if len(hero.Inventory) != 1 {
+		t.Fatalf("inventory size mismatch; expected 1, got %d", len(hero.Inventory))
+	}
+	got := hero.Inventory[0]
+	if got.Idx != 24 || got.Col != 2 || got.Row != 1 || got.Width != 2 || got.Height != 3 {
+		t.Errorf("inventory item mismatch; expected idx 24 at (2, 1) of size 2x3, got idx %d at (%d, %d) of size %dx%d", got.Idx, got.Col, got.Row, got.Width, got.Height)
+	}
+	for i, item := range hero.Belt {
+		if (item != nil) != (i == 2) || (item != nil && item.Idx != 25) {
+			t.Errorf("belt mismatch at slot %d; got %v", i, item)
+		}
+	}
+}
+
+func TestDecodeHero(t *testing.T) {
+	hero, err := DecodeHero(testPlayer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHero(t, hero)
+	if _, err := DecodeHero(make([]byte, pkPlayerSize-1)); err == nil {
+		t.Error("expected error for invalid size")
+	}
+}
+
+func TestReadHero(t *testing.T) {
+	if size := binary.Size(pkPlayer{}); size != pkPlayerSize {
+		t.Fatalf("player structure size mismatch; expected %d, got %d", pkPlayerSize, size)
+	}
+	dir, err := ioutil.TempDir("", "save")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	golden := []struct {
+		name     string
+		password string
+	}{
+		{name: "single_0.sv", password: PasswordSingle},
+		{name: "multi_3.sv", password: PasswordMulti},
+	}
+	for i, g := range golden {
+		buf := new(bytes.Buffer)
+		mw := mpq.NewWriter(buf)
+		w, err := mw.Create("hero")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(encode(testPlayer(), g.password))
+		if err := mw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		savePath := filepath.Join(dir, g.name)
+		if err := ioutil.WriteFile(savePath, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		hero, err := ReadHero(savePath)
+		if err != nil {
+			t.Errorf("i=%d: %v", i, err)
+			continue
+		}
+		checkHero(t, hero)
+	}
+}
@@ -0,0 +1,89 @@
+package save
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// The size of an inventory cell in pixels.
+const (
+	CellWidth  = 28
+	CellHeight = 28
+)
+
+// bodyRects maps from equipment slots to their location on the inventory panel.
+var bodyRects = map[string]image.Rectangle{
+	"head":       image.Rect(133, 3, 189, 59),
+	"left_ring":  image.Rect(48, 177, 76, 205),
+	"right_ring": image.Rect(249, 177, 277, 205),
+	"amulet":     image.Rect(205, 32, 233, 60),
+	"left_hand":  image.Rect(17, 76, 73, 160),
+	"right_hand": image.Rect(248, 76, 304, 160),
+	"chest":      image.Rect(133, 76, 189, 160),
+}
+
+// The location of the top left cell of the inventory grid on the inventory
+// panel, and the distance between the cells in pixels.
+const (
+	invX    = 17
+	invY    = 194
+	invStep = 29
+)
+
+// placeholder is the color of the outline of items without an icon.
+var placeholder = color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xFF}
+
+// PaperDoll returns an image of the inventory panel bg, onto which the equipped
+// items and the items of the inventory grid of the hero have been drawn. The
+// icon of each item is provided by icon; items without an icon (nil) are drawn
+// as the outline of their location.
+//
+// Equipped items are centered within their slot, and the items of the
+// inventory grid are aligned to the bottom left corner of their cells, as done
+// by the game.
+func (hero *Hero) PaperDoll(bg image.Image, icon func(item *Item) image.Image) (img image.Image) {
+	bounds := bg.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), bg, bounds.Min, draw.Src)
+	for _, slot := range BodySlots {
+		item, ok := hero.Body[slot]
+		if !ok {
+			continue
+		}
+		rect := bodyRects[slot]
+		src := icon(item)
+		if src == nil {
+			drawOutline(dst, rect)
+			continue
+		}
+		size := src.Bounds().Size()
+		min := rect.Min.Add(rect.Size().Sub(size).Div(2))
+		draw.Draw(dst, image.Rectangle{Min: min, Max: min.Add(size)}, src, src.Bounds().Min, draw.Over)
+	}
+	for _, invItem := range hero.Inventory {
+		min := image.Pt(invX+invItem.Col*invStep, invY+invItem.Row*invStep)
+		max := image.Pt(invX+(invItem.Col+invItem.Width-1)*invStep+CellWidth, invY+(invItem.Row+invItem.Height-1)*invStep+CellHeight)
+		src := icon(invItem.Item)
+		if src == nil {
+			drawOutline(dst, image.Rectangle{Min: min, Max: max})
+			continue
+		}
+		size := src.Bounds().Size()
+		dr := image.Rect(min.X, max.Y-size.Y, min.X+size.X, max.Y)
+		draw.Draw(dst, dr, src, src.Bounds().Min, draw.Over)
+	}
+	return dst
+}
+
+// drawOutline draws the outline of rect onto dst.
+func drawOutline(dst *image.RGBA, rect image.Rectangle) {
+	for x := rect.Min.X; x < rect.Max.X; x++ {
+		dst.Set(x, rect.Min.Y, placeholder)
+		dst.Set(x, rect.Max.Y-1, placeholder)
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		dst.Set(rect.Min.X, y, placeholder)
+		dst.Set(rect.Max.X-1, y, placeholder)
+	}
+}
@@ -0,0 +1,96 @@
+// Package save implements functionality for reading the save files of Diablo.
+//
+// Save files (e.g. single_0.sv) are MPQ archives, which contain the hero and
+// the game state. The files of a save archive are PKWARE imploded by the MPQ
+// layer, and encrypted using a password and a variant of SHA-1 (see Decode).
+// The decoded "hero" file contains the packed player structure, which is
+// described below. All integers are stored in little endian.
+//
+// Player format:
+//    _           [8]byte
+//    destAction  int8
+//    destParam1  int8
+//    destParam2  int8
+//    dungeonLvl  uint8
+//    x           uint8
+//    y           uint8
+//    targX       uint8
+//    targY       uint8
+//    name        [32]byte
+//    class       int8
+//    baseStr     uint8
+//    baseMag     uint8
+//    baseDex     uint8
+//    baseVit     uint8
+//    level       int8
+//    statPts     uint8
+//    experience  int32
+//    gold        int32
+//    // The life and mana are stored in 1/64ths of a point.
+//    hpBase      int32
+//    maxHPBase   int32
+//    manaBase    int32
+//    maxManaBase int32
+//    spellLvls   [37]int8
+//    // memSpells is a bitfield; bit n-1 is set if spell n is memorized.
+//    memSpells   uint64
+//    invBody     [7]Item
+//    invList     [40]Item
+//    invGrid     [40]int8
+//    numInv      int8
+//    spdList     [8]Item
+//    townWarps   int8
+//    dungMsgs    int8
+//    lvlLoad     int8
+//    battleNet   int8
+//    manaShield  uint8
+//    _           [3]byte
+//    _           [8]int16
+//    diabloKills uint32
+//    _           [7]int32
+//
+// Item format:
+//    seed       uint32
+//    createInfo uint16
+//    // idx is 0xFFFF for empty item slots.
+//    idx        uint16
+//    identified uint8
+//    dur        uint8
+//    maxDur     uint8
+//    charges    uint8
+//    maxCharges uint8
+//    value      uint16
+//    buff       uint32
+package save
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mewrnd/blizzconv/mpq"
+)
+
+// ReadHero reads the hero of the save file located at savePath. The password is
+// chosen based on the name of the save file; multiplayer save files are named
+// multi_N.sv.
+func ReadHero(savePath string) (hero *Hero, err error) {
+	rc, err := mpq.OpenReader(savePath)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := rc.ReadFile("hero")
+	if err != nil {
+		return nil, err
+	}
+	password := PasswordSingle
+	if strings.HasPrefix(strings.ToLower(filepath.Base(savePath)), "multi_") {
+		password = PasswordMulti
+	}
+	buf, err := Decode(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("save.ReadHero: unable to decode hero of %q: %v", savePath, err)
+	}
+	return DecodeHero(buf)
+}
@@ -0,0 +1,79 @@
+package save
+
+import "encoding/binary"
+
+// A shaContext is a context of the SHA-1 variant used by Diablo to encrypt save
+// files. The variant differs from SHA-1 in the following ways:
+//    - the message words are read in little endian.
+//    - the message schedule is not rotated (as in SHA-0).
+//    - the circular shift is performed on signed integers, which sign extends
+//      the bits shifted in from the right.
+//    - messages are not padded, and only whole blocks are processed.
+//    - the digest is stored in little endian.
+type shaContext struct {
+	state [5]uint32
+}
+
+// shaBlockSize is the size of a SHA-1 block in bytes.
+const shaBlockSize = 64
+
+// shaDigestSize is the size of a SHA-1 digest in bytes.
+const shaDigestSize = 20
+
+// reset resets the state of the context.
+func (ctx *shaContext) reset() {
+	ctx.state = [5]uint32{0x67452301, 0xEFCDAB89, 0x98BADCFE, 0x10325476, 0xC3D2E1F0}
+}
+
+// write processes each whole block of data.
+func (ctx *shaContext) write(data []byte) {
+	for ; len(data) >= shaBlockSize; data = data[shaBlockSize:] {
+		ctx.block(data[:shaBlockSize])
+	}
+}
+
+// digest returns the digest of the current state.
+func (ctx *shaContext) digest() (digest [shaDigestSize]byte) {
+	for i, x := range ctx.state {
+		binary.LittleEndian.PutUint32(digest[4*i:], x)
+	}
+	return digest
+}
+
+// block processes a single block of data.
+func (ctx *shaContext) block(data []byte) {
+	var w [80]uint32
+	for i := 0; i < 16; i++ {
+		w[i] = binary.LittleEndian.Uint32(data[4*i:])
+	}
+	for i := 16; i < 80; i++ {
+		w[i] = w[i-16] ^ w[i-14] ^ w[i-8] ^ w[i-3]
+	}
+	a, b, c, d, e := ctx.state[0], ctx.state[1], ctx.state[2], ctx.state[3], ctx.state[4]
+	for i := 0; i < 80; i++ {
+		var f, k uint32
+		switch {
+		case i < 20:
+			f, k = (b&c)|(^b&d), 0x5A827999
+		case i < 40:
+			f, k = b^c^d, 0x6ED9EBA1
+		case i < 60:
+			f, k = (b&c)|(b&d)|(c&d), 0x8F1BBCDC
+		default:
+			f, k = b^c^d, 0xCA62C1D6
+		}
+		tmp := shift(5, a) + f + e + w[i] + k
+		e, d, c, b, a = d, c, shift(30, b), a, tmp
+	}
+	ctx.state[0] += a
+	ctx.state[1] += b
+	ctx.state[2] += c
+	ctx.state[3] += d
+	ctx.state[4] += e
+}
+
+// shift returns the circular shift of x by n bits, as performed on signed
+// integers by the game.
+func shift(n uint, x uint32) uint32 {
+	return x<<n | uint32(int32(x)>>(32-n))
+}
@@ -0,0 +1,215 @@
+// snd_dump is a tool for converting WAV sounds into PCM WAV sounds, and for
+// creating a catalog of their durations and sample rates.
+//
+// Usage:
+//
+//    snd_dump [OPTION]... [name.wav]...
+//
+// Flags:
+//
+//    -a
+//            Dump all sound files.
+//    -catalog="_dump_/snd.ini"
+//            Output path of the sound catalog.
+//    -mpq=""
+//            Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of
+//            MPQ archives and directories in order of priority.
+//    -mpqdump="mpqdump/"
+//            Path to an extracted MPQ file.
+//    -mpqini="mpq.ini"
+//            Path to an ini file containing relative path information.
+//
+// Each sound is stored at the same relative path below "_dump_/", e.g.
+// "_dump_/sfx/misc/walk1.wav". Below is an example entry of the catalog:
+//    [sfx/misc/walk1.wav]
+//    duration = 0.320
+//    sample_rate = 22050
+//    channels = 1
+//    bits_per_sample = 16
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0xC3/progress/barcli"
+	"github.com/mewrnd/blizzconv/mpq"
+	"github.com/mewrnd/blizzconv/sounds/wav"
+)
+
+// flagAll specifies if all sounds should be dumped or not.
+var flagAll bool
+
+// Paths specified by command line flags.
+var catalogPath, archivePath, extractPath, mpqIniPath string
+
+func init() {
+	flag.Usage = usage
+	flag.BoolVar(&flagAll, "a", false, "Dump all sound files.")
+	flag.StringVar(&catalogPath, "catalog", dumpPrefix+"snd.ini", "Output path of the sound catalog.")
+	flag.StringVar(&archivePath, "mpq", "", "Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of MPQ archives and directories in order of priority.")
+	flag.StringVar(&extractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
+	flag.StringVar(&mpqIniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
+	flag.Parse()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]... [name.wav]...\n", os.Args[0])
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+// bar represents the progress bar.
+var bar *barcli.Bar
+
+var (
+	// archive provides access to the files of the MPQ archive.
+	archive *mpq.Archive
+	// fsys is the file system of the MPQ archive.
+	fsys fs.FS
+)
+
+// catalog maps from relative paths to the catalog entries of the dumped
+// sounds.
+var catalog = make(map[string]catalogEntry)
+
+// A catalogEntry specifies the duration and format of a dumped sound.
+type catalogEntry struct {
+	// The duration of the sound.
+	Duration time.Duration
+	// The number of samples per second.
+	SampleRate int
+	// The number of channels; 1 (mono) or 2 (stereo).
+	Channels int
+	// The number of bits per sample; 8 or 16.
+	BitsPerSample int
+}
+
+func main() {
+	var err error
+	archive, err = mpq.OpenArchive(extractPath, mpqIniPath, strings.Split(archivePath, ",")...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer archive.Close()
+	fsys = archive.FS()
+	sndNames := flag.Args()
+	if flagAll {
+		sndNames = nil
+		for _, name := range archive.Names() {
+			if path.Ext(name) == ".wav" {
+				sndNames = append(sndNames, name)
+			}
+		}
+		bar, err = barcli.New(len(sndNames))
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if len(sndNames) < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	failed := false
+	for _, sndName := range sndNames {
+		err := dump(sndName)
+		if err != nil {
+			log.Println(err)
+			failed = true
+		}
+	}
+	err = writeCatalog(catalogPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// dumpPrefix is the name of the dump directory.
+const dumpPrefix = "_dump_/"
+
+// dump decodes the sound and stores it as a PCM WAV sound in the dump
+// directory.
+func dump(sndName string) (err error) {
+	if flagAll {
+		bar.Inc()
+	}
+	relSndPath, err := archive.GetRelPath(sndName)
+	if err != nil {
+		return err
+	}
+	s, err := wav.DecodeFile(fsys, relSndPath)
+	if err != nil {
+		return err
+	}
+	dumpPath := path.Clean(dumpPrefix + relSndPath)
+	// prevent directory traversal
+	if !strings.HasPrefix(dumpPath, dumpPrefix) {
+		return fmt.Errorf("path (%s) contains no dump prefix (%s).", dumpPath, dumpPrefix)
+	}
+	err = os.MkdirAll(path.Dir(dumpPath), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(dumpPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	err = wav.Encode(bw, s)
+	if err != nil {
+		return err
+	}
+	// only keep the format of the sound, as the samples are no longer needed.
+	catalog[relSndPath] = catalogEntry{
+		Duration:      s.Duration(),
+		SampleRate:    s.SampleRate,
+		Channels:      s.Channels,
+		BitsPerSample: s.BitsPerSample,
+	}
+	return bw.Flush()
+}
+
+// writeCatalog writes the duration and format of each dumped sound to
+// catalogPath.
+func writeCatalog(catalogPath string) (err error) {
+	var relSndPaths []string
+	for relSndPath := range catalog {
+		relSndPaths = append(relSndPaths, relSndPath)
+	}
+	sort.Strings(relSndPaths)
+	err = os.MkdirAll(path.Dir(catalogPath), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(catalogPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	for i, relSndPath := range relSndPaths {
+		entry := catalog[relSndPath]
+		if i > 0 {
+			fmt.Fprintln(bw)
+		}
+		fmt.Fprintf(bw, "[%s]\n", relSndPath)
+		fmt.Fprintf(bw, "duration = %.3f\n", entry.Duration.Seconds())
+		fmt.Fprintf(bw, "sample_rate = %d\n", entry.SampleRate)
+		fmt.Fprintf(bw, "channels = %d\n", entry.Channels)
+		fmt.Fprintf(bw, "bits_per_sample = %d\n", entry.BitsPerSample)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,114 @@
+package wav
+
+import "encoding/binary"
+
+// IMA ADPCM data is split into blocks of blockAlign bytes. Each block starts
+// with a header for each channel, followed by 4-bit encoded samples which are
+// interleaved by channel in groups of 4 bytes (8 samples). The lower nibble of
+// each byte is decoded first.
+//
+// IMA ADPCM block header format:
+//    // sample is the first sample of the block.
+//    sample    int16
+//    stepIndex uint8
+//    _         uint8
+
+// imaStepSizes contains the step sizes of IMA ADPCM.
+var imaStepSizes = [89]int{
+	7, 8, 9, 10, 11, 12, 13, 14, 16, 17, 19, 21, 23, 25, 28, 31, 34, 37, 41, 45,
+	50, 55, 60, 66, 73, 80, 88, 97, 107, 118, 130, 143, 157, 173, 190, 209, 230,
+	253, 279, 307, 337, 371, 408, 449, 494, 544, 598, 658, 724, 796, 876, 963,
+	1060, 1166, 1282, 1411, 1552, 1707, 1878, 2066, 2272, 2499, 2749, 3024, 3327,
+	3660, 4026, 4428, 4871, 5358, 5894, 6484, 7132, 7845, 8630, 9493, 10442,
+	11487, 12635, 13899, 15289, 16818, 18500, 20350, 22385, 24623, 27086, 29794,
+	32767,
+}
+
+// imaStepIndexDeltas maps from the magnitude of encoded samples to step index
+// adjustments.
+var imaStepIndexDeltas = [8]int{-1, -1, -1, -1, 2, 4, 6, 8}
+
+// imaChannel is the decoder state of a channel.
+type imaChannel struct {
+	sample    int
+	stepIndex int
+}
+
+// decode decodes a 4-bit encoded sample.
+func (c *imaChannel) decode(nibble byte) int {
+	stepSize := imaStepSizes[c.stepIndex]
+	diff := stepSize >> 3
+	if nibble&1 != 0 {
+		diff += stepSize >> 2
+	}
+	if nibble&2 != 0 {
+		diff += stepSize >> 1
+	}
+	if nibble&4 != 0 {
+		diff += stepSize
+	}
+	if nibble&8 != 0 {
+		c.sample -= diff
+		if c.sample < -32768 {
+			c.sample = -32768
+		}
+	} else {
+		c.sample += diff
+		if c.sample > 32767 {
+			c.sample = 32767
+		}
+	}
+	c.stepIndex += imaStepIndexDeltas[nibble&7]
+	if c.stepIndex < 0 {
+		c.stepIndex = 0
+	} else if c.stepIndex > len(imaStepSizes)-1 {
+		c.stepIndex = len(imaStepSizes) - 1
+	}
+	return c.sample
+}
+
+// decodeIMAADPCM decodes IMA ADPCM data and returns the 16-bit PCM samples in
+// little endian. A truncated final block is decoded as far as possible.
+func decodeIMAADPCM(data []byte, channelCount, blockAlign int) (buf []byte) {
+	samplesPerBlock := 1 + (blockAlign/channelCount-4)*2
+	buf = make([]byte, 0, (len(data)/blockAlign+1)*samplesPerBlock*channelCount*2)
+	for len(data) >= 4*channelCount {
+		block := data
+		if len(block) > blockAlign {
+			block = block[:blockAlign]
+		}
+		data = data[len(block):]
+
+		// Decode the block header of each channel.
+		channels := make([]imaChannel, channelCount)
+		for i := range channels {
+			channels[i].sample = int(int16(binary.LittleEndian.Uint16(block[4*i:])))
+			channels[i].stepIndex = int(block[4*i+2])
+			if channels[i].stepIndex > len(imaStepSizes)-1 {
+				channels[i].stepIndex = len(imaStepSizes) - 1
+			}
+		}
+		block = block[4*channelCount:]
+		frameCount := 1 + len(block)/(4*channelCount)*8
+		samples := make([]int, frameCount*channelCount)
+		for i, c := range channels {
+			samples[i] = c.sample
+		}
+
+		// Decode groups of 8 samples of each channel.
+		for group := 0; len(block) >= 4*channelCount; group++ {
+			for i := range channels {
+				for j, b := range block[4*i : 4*i+4] {
+					frame := 1 + group*8 + 2*j
+					samples[frame*channelCount+i] = channels[i].decode(b & 0x0F)
+					samples[(frame+1)*channelCount+i] = channels[i].decode(b >> 4)
+				}
+			}
+			block = block[4*channelCount:]
+		}
+		for _, sample := range samples {
+			buf = append(buf, byte(sample), byte(sample>>8))
+		}
+	}
+	return buf
+}
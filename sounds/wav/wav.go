@@ -0,0 +1,180 @@
+// Package wav implements a WAV sound decoder and a PCM WAV encoder.
+//
+// Below is a description of the WAV sound format, as used by Diablo. All
+// integers are stored in little endian.
+//
+// WAV format:
+//    magic  [4]byte // "RIFF"
+//    size   uint32  // size of the remaining data.
+//    format [4]byte // "WAVE"
+//    // chunks contains the fmt chunk, the data chunk and optional chunks
+//    // (e.g. "LIST" or "fact") which are ignored while decoding.
+//    chunks []chunk
+//
+// Chunk format:
+//    id   [4]byte
+//    size uint32
+//    // data is padded to an even number of bytes.
+//    data [size]byte
+//
+// fmt chunk format:
+//    formatTag      uint16 // 1 (PCM) or 0x11 (IMA ADPCM).
+//    channels       uint16
+//    sampleRate     uint32
+//    byteRate       uint32
+//    blockAlign     uint16
+//    bitsPerSample  uint16
+//    // extra is only present for IMA ADPCM.
+//    extraSize       uint16
+//    samplesPerBlock uint16
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"time"
+)
+
+// Format tags of the fmt chunk.
+const (
+	formatPCM      = 0x0001
+	formatIMAADPCM = 0x0011
+)
+
+// A Sound is a sound of PCM encoded samples. Samples of 16 bits are signed,
+// and samples of 8 bits are unsigned.
+type Sound struct {
+	// The number of samples per second.
+	SampleRate int
+	// The number of channels; 1 (mono) or 2 (stereo).
+	Channels int
+	// The number of bits per sample; 8 or 16.
+	BitsPerSample int
+	// The samples in little endian, interleaved by channel.
+	Data []byte
+}
+
+// Duration returns the duration of the sound.
+func (s *Sound) Duration() time.Duration {
+	frameSize := s.Channels * s.BitsPerSample / 8
+	if frameSize == 0 || s.SampleRate == 0 {
+		return 0
+	}
+	frameCount := int64(len(s.Data) / frameSize)
+	return time.Duration(frameCount) * time.Second / time.Duration(s.SampleRate)
+}
+
+// DecodeFile decodes the WAV sound located at relWavPath within fsys.
+func DecodeFile(fsys fs.FS, relWavPath string) (s *Sound, err error) {
+	buf, err := fs.ReadFile(fsys, relWavPath)
+	if err != nil {
+		return nil, err
+	}
+	s, err = decode(buf)
+	if err != nil {
+		return nil, fmt.Errorf("wav.DecodeFile: unable to decode %q: %v", relWavPath, err)
+	}
+	return s, nil
+}
+
+// Decode decodes a WAV sound from r. IMA ADPCM samples are converted to 16-bit
+// PCM samples.
+func Decode(r io.Reader) (s *Sound, err error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return decode(buf)
+}
+
+// decode decodes the WAV sound contained within buf.
+func decode(buf []byte) (s *Sound, err error) {
+	if len(buf) < 12 || string(buf[0:4]) != "RIFF" || string(buf[8:12]) != "WAVE" {
+		return nil, errors.New("invalid RIFF WAVE header")
+	}
+	var fmtChunk, data []byte
+	for pos := 12; pos+8 <= len(buf); {
+		id := string(buf[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(buf[pos+4:]))
+		pos += 8
+		end := pos + size
+		if end > len(buf) || end < pos {
+			// Truncated chunks occur at the end of some files; use the
+			// remaining data.
+			end = len(buf)
+		}
+		switch id {
+		case "fmt ":
+			fmtChunk = buf[pos:end]
+		case "data":
+			data = buf[pos:end]
+		}
+		pos = end + size&1
+	}
+	if len(fmtChunk) < 16 {
+		return nil, errors.New("fmt chunk missing or truncated")
+	}
+	if data == nil {
+		return nil, errors.New("data chunk missing")
+	}
+	formatTag := binary.LittleEndian.Uint16(fmtChunk[0:])
+	s = &Sound{
+		Channels:      int(binary.LittleEndian.Uint16(fmtChunk[2:])),
+		SampleRate:    int(binary.LittleEndian.Uint32(fmtChunk[4:])),
+		BitsPerSample: int(binary.LittleEndian.Uint16(fmtChunk[14:])),
+	}
+	if s.Channels != 1 && s.Channels != 2 {
+		return nil, fmt.Errorf("unsupported channel count %d", s.Channels)
+	}
+	switch formatTag {
+	case formatPCM:
+		if s.BitsPerSample != 8 && s.BitsPerSample != 16 {
+			return nil, fmt.Errorf("unsupported bits per sample %d", s.BitsPerSample)
+		}
+		frameSize := s.Channels * s.BitsPerSample / 8
+		s.Data = data[:len(data)-len(data)%frameSize]
+	case formatIMAADPCM:
+		blockAlign := int(binary.LittleEndian.Uint16(fmtChunk[12:]))
+		if blockAlign < 4*s.Channels || blockAlign%(4*s.Channels) != 0 {
+			return nil, fmt.Errorf("invalid IMA ADPCM block size %d", blockAlign)
+		}
+		s.Data = decodeIMAADPCM(data, s.Channels, blockAlign)
+		s.BitsPerSample = 16
+	default:
+		return nil, fmt.Errorf("unsupported format tag 0x%04X", formatTag)
+	}
+	return s, nil
+}
+
+// Encode writes the sound s to w as a PCM WAV sound, which contains only the
+// fmt and data chunks.
+func Encode(w io.Writer, s *Sound) (err error) {
+	frameSize := s.Channels * s.BitsPerSample / 8
+	hdr := new(bytes.Buffer)
+	hdr.WriteString("RIFF")
+	binary.Write(hdr, binary.LittleEndian, uint32(36+len(s.Data)+len(s.Data)&1))
+	hdr.WriteString("WAVEfmt ")
+	binary.Write(hdr, binary.LittleEndian, []uint32{16})
+	binary.Write(hdr, binary.LittleEndian, []uint16{formatPCM, uint16(s.Channels)})
+	binary.Write(hdr, binary.LittleEndian, []uint32{uint32(s.SampleRate), uint32(s.SampleRate * frameSize)})
+	binary.Write(hdr, binary.LittleEndian, []uint16{uint16(frameSize), uint16(s.BitsPerSample)})
+	hdr.WriteString("data")
+	binary.Write(hdr, binary.LittleEndian, uint32(len(s.Data)))
+	_, err = w.Write(hdr.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(s.Data)
+	if err != nil {
+		return err
+	}
+	if len(s.Data)&1 != 0 {
+		_, err = w.Write([]byte{0})
+	}
+	return err
+}
@@ -0,0 +1,236 @@
+// smk_dump is a tool for converting Smacker videos into png images and WAV
+// sounds.
+//
+// Usage:
+//
+//    smk_dump [OPTION]... [name.smk]...
+//
+// Flags:
+//
+//    -a
+//            Dump all video files.
+//    -mpq=""
+//            Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of
+//            MPQ archives and directories in order of priority.
+//    -mpqdump="mpqdump/"
+//            Path to an extracted MPQ file.
+//    -mpqini="mpq.ini"
+//            Path to an ini file containing relative path information.
+//
+// The frames and audio tracks of each video are stored in a dump directory,
+// along with an ini file which contains the frame rate required to re-mux
+// them. Below is an example layout:
+//    _dump_/gendata/logo/logo.ini
+//    _dump_/gendata/logo/logo.wav
+//    _dump_/gendata/logo/logo_0000.png
+//    _dump_/gendata/logo/logo_0001.png
+//
+// Below is an example ini file:
+//    frame_rate = 15.000
+//    frame_count = 2
+//    width = 640
+//    height = 480
+//
+//    [logo.wav]
+//    track = 0
+//    sample_rate = 22050
+//    channels = 2
+//    bits_per_sample = 16
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/0xC3/progress/barcli"
+	"github.com/mewkiz/pkg/imgutil"
+	"github.com/mewrnd/blizzconv/mpq"
+	"github.com/mewrnd/blizzconv/sounds/wav"
+	"github.com/mewrnd/blizzconv/videos/smk"
+)
+
+// flagAll specifies if all videos should be dumped or not.
+var flagAll bool
+
+// Paths specified by command line flags.
+var archivePath, extractPath, mpqIniPath string
+
+func init() {
+	flag.Usage = usage
+	flag.BoolVar(&flagAll, "a", false, "Dump all video files.")
+	flag.StringVar(&archivePath, "mpq", "", "Path to an MPQ archive (e.g. DIABDAT.MPQ), or a comma-separated list of MPQ archives and directories in order of priority.")
+	flag.StringVar(&extractPath, "mpqdump", "mpqdump/", "Path to an extracted MPQ file.")
+	flag.StringVar(&mpqIniPath, "mpqini", "mpq.ini", "Path to an ini file containing relative path information.")
+	flag.Parse()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]... [name.smk]...\n", os.Args[0])
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+var (
+	// archive provides access to the files of the MPQ archive.
+	archive *mpq.Archive
+	// fsys is the file system of the MPQ archive.
+	fsys fs.FS
+)
+
+func main() {
+	var err error
+	archive, err = mpq.OpenArchive(extractPath, mpqIniPath, strings.Split(archivePath, ",")...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer archive.Close()
+	fsys = archive.FS()
+	smkNames := flag.Args()
+	if flagAll {
+		smkNames = nil
+		for _, name := range archive.Names() {
+			if path.Ext(name) == ".smk" {
+				smkNames = append(smkNames, name)
+			}
+		}
+	}
+	if len(smkNames) < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	for _, smkName := range smkNames {
+		err := dump(smkName)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
+
+// dumpPrefix is the name of the dump directory.
+const dumpPrefix = "_dump_/"
+
+// dump decodes the frames and audio tracks of the video, and stores them in a
+// dump directory.
+func dump(smkName string) (err error) {
+	relSmkPath, err := archive.GetRelPath(smkName)
+	if err != nil {
+		return err
+	}
+	d, c, err := smk.Open(fsys, relSmkPath)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// create dumpDir
+	smkDir, name := path.Split(relSmkPath)
+	nameWithoutExt := name[:len(name)-len(path.Ext(name))]
+	dumpDir := path.Clean(dumpPrefix+smkDir+nameWithoutExt) + "/"
+	// prevent directory traversal
+	if !strings.HasPrefix(dumpDir, dumpPrefix) {
+		return fmt.Errorf("path (%s) contains no dump prefix (%s).", dumpDir, dumpPrefix)
+	}
+	err = os.MkdirAll(dumpDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	// dump frames.
+	var sounds [7]*wav.Sound
+	for i, track := range d.Tracks {
+		if track.SampleRate != 0 {
+			sounds[i] = &wav.Sound{SampleRate: track.SampleRate, Channels: track.Channels, BitsPerSample: track.BitsPerSample}
+		}
+	}
+	bar, err := barcli.New(int(d.Header.FrameCount))
+	if err != nil {
+		return err
+	}
+	for frameNum := 0; ; frameNum++ {
+		frame, err := d.NextFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%q: %v", relSmkPath, err)
+		}
+		bar.Inc()
+		pngName := fmt.Sprintf("%s_%04d.png", nameWithoutExt, frameNum)
+		err = imgutil.WriteFile(dumpDir+pngName, frame.Image)
+		if err != nil {
+			return err
+		}
+		for i, s := range sounds {
+			if s != nil {
+				s.Data = append(s.Data, frame.Audio[i]...)
+			}
+		}
+	}
+
+	// dump audio tracks.
+	var wavNames [7]string
+	for i, s := range sounds {
+		if s == nil {
+			continue
+		}
+		wavNames[i] = nameWithoutExt + ".wav"
+		if i > 0 {
+			wavNames[i] = fmt.Sprintf("%s_track%d.wav", nameWithoutExt, i)
+		}
+		err = writeSound(dumpDir+wavNames[i], s)
+		if err != nil {
+			return err
+		}
+	}
+	return writeInfo(dumpDir+nameWithoutExt+".ini", d, sounds, wavNames)
+}
+
+// writeSound stores the sound as a PCM WAV sound at wavPath.
+func writeSound(wavPath string, s *wav.Sound) (err error) {
+	f, err := os.Create(wavPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	err = wav.Encode(bw, s)
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeInfo writes the frame rate, dimensions and audio formats of the video to
+// iniPath.
+func writeInfo(iniPath string, d *smk.Decoder, sounds [7]*wav.Sound, wavNames [7]string) (err error) {
+	f, err := os.Create(iniPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	bounds := d.Bounds()
+	fmt.Fprintf(bw, "frame_rate = %.3f\n", d.FrameRate())
+	fmt.Fprintf(bw, "frame_count = %d\n", d.Header.FrameCount)
+	fmt.Fprintf(bw, "width = %d\n", bounds.Dx())
+	fmt.Fprintf(bw, "height = %d\n", bounds.Dy())
+	for i, s := range sounds {
+		if s == nil {
+			continue
+		}
+		fmt.Fprintf(bw, "\n[%s]\n", wavNames[i])
+		fmt.Fprintf(bw, "track = %d\n", i)
+		fmt.Fprintf(bw, "sample_rate = %d\n", s.SampleRate)
+		fmt.Fprintf(bw, "channels = %d\n", s.Channels)
+		fmt.Fprintf(bw, "bits_per_sample = %d\n", s.BitsPerSample)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,89 @@
+package smk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// decodeAudio decodes the audio data of a frame, and returns the PCM samples in
+// little endian.
+//
+// Compressed audio format:
+//    // size specifies the size of the decoded samples in bytes.
+//    size    uint32
+//    present bit
+//    stereo  bit
+//    bits16  bit
+//    // trees contains a Huffman tree of byte values for each channel, or for
+//    // the low and high bytes of each channel of 16-bit audio.
+//    trees   []byteTree
+//    // bases contains the first sample of each channel, in reverse order.
+//    // 16-bit samples are stored in big endian.
+//    bases   []sample
+//    // deltas contains the difference of each sample to the previous sample
+//    // of the same channel.
+//    deltas  []huffCode
+func (track *Track) decodeAudio(data []byte) (buf []byte, err error) {
+	if !track.compressed {
+		return data, nil
+	}
+	if len(data) < 4 {
+		return nil, errors.New("audio truncated")
+	}
+	size := int(binary.LittleEndian.Uint32(data))
+	if size > 1<<24 {
+		return nil, fmt.Errorf("invalid audio size %d", size)
+	}
+	br := &bitReader{buf: data[4:]}
+	if br.readBit() == 0 {
+		// No samples.
+		return nil, nil
+	}
+	stereo := br.readBit()
+	bits16 := br.readBit()
+	if stereo+1 != track.Channels || 8+8*bits16 != track.BitsPerSample {
+		return nil, errors.New("audio format mismatch")
+	}
+	trees := make([]*huffTree, 1<<uint(stereo+bits16))
+	for i := range trees {
+		trees[i], err = readByteTree(br)
+		if err != nil {
+			return nil, err
+		}
+	}
+	buf = make([]byte, 0, size)
+	channels := stereo + 1
+	var preds [2]int
+	if bits16 == 1 {
+		for i := stereo; i >= 0; i-- {
+			hi := br.readBits(8)
+			lo := br.readBits(8)
+			preds[i] = int(int16(hi<<8 | lo))
+		}
+		for i := 0; i < size/2; i++ {
+			ch := i % channels
+			if i >= channels {
+				lo := trees[2*ch].decode(br).value
+				hi := trees[2*ch+1].decode(br).value
+				preds[ch] += int(int16(hi<<8 | lo))
+			}
+			buf = append(buf, byte(preds[ch]), byte(preds[ch]>>8))
+		}
+	} else {
+		for i := stereo; i >= 0; i-- {
+			preds[i] = br.readBits(8)
+		}
+		for i := 0; i < size; i++ {
+			ch := i % channels
+			if i >= channels {
+				preds[ch] += trees[ch].decode(br).value
+			}
+			buf = append(buf, byte(preds[ch]))
+		}
+	}
+	if br.err != nil {
+		return nil, br.err
+	}
+	return buf, nil
+}
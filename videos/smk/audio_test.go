@@ -0,0 +1,130 @@
+package smk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// encodeAudio encodes PCM samples in little endian, using a Huffman tree of the
+// delta bytes of each channel; the reverse of decodeAudio.
+func encodeAudio(pcm []byte, channels, bitsPerSample int) []byte {
+	stereo, bits16 := channels-1, bitsPerSample/16
+	var samples []int
+	if bits16 == 1 {
+		for i := 0; i+1 < len(pcm); i += 2 {
+			samples = append(samples, int(int16(binary.LittleEndian.Uint16(pcm[i:]))))
+		}
+	} else {
+		for _, b := range pcm {
+			samples = append(samples, int(b))
+		}
+	}
+	// deltas contains the tree and value of each delta byte, in order.
+	type delta struct {
+		tree, value int
+	}
+	var deltas []delta
+	trees := make([][]int, channels<<uint(bits16))
+	add := func(tree, value int) {
+		deltas = append(deltas, delta{tree: tree, value: value})
+		trees[tree] = appendDistinct(trees[tree], value)
+	}
+	for i := channels; i < len(samples); i++ {
+		ch := i % channels
+		d := samples[i] - samples[i-channels]
+		if bits16 == 1 {
+			add(2*ch, d&0xFF)
+			add(2*ch+1, d>>8&0xFF)
+		} else {
+			add(ch, d&0xFF)
+		}
+	}
+	bw := new(bitWriter)
+	bw.writeBit(1)
+	bw.writeBit(stereo)
+	bw.writeBit(bits16)
+	codes := make([]map[int][]int, len(trees))
+	for i, values := range trees {
+		codes[i] = bw.writeByteTree(values)
+	}
+	for ch := stereo; ch >= 0; ch-- {
+		if bits16 == 1 {
+			bw.writeBits(samples[ch]>>8&0xFF, 8)
+			bw.writeBits(samples[ch]&0xFF, 8)
+		} else {
+			bw.writeBits(samples[ch], 8)
+		}
+	}
+	for _, d := range deltas {
+		bw.writeCode(codes[d.tree][d.value])
+	}
+	data := make([]byte, 4, 4+len(bw.buf))
+	binary.LittleEndian.PutUint32(data, uint32(len(pcm)))
+	return append(data, bw.buf...)
+}
+
+// pcm16 returns the given 16-bit samples in little endian.
+func pcm16(samples ...int16) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, samples)
+	return buf.Bytes()
+}
+
+func TestDecodeAudio(t *testing.T) {
+	golden := []struct {
+		pcm           []byte
+		channels      int
+		bitsPerSample int
+	}{
+		{pcm: []byte{128, 130, 127, 127, 0, 255}, channels: 1, bitsPerSample: 8},
+		{pcm: []byte{10, 200, 12, 190, 9, 210, 9, 210}, channels: 2, bitsPerSample: 8},
+		{pcm: pcm16(-1000, 1200, 1200, -32768, 32767), channels: 1, bitsPerSample: 16},
+		{pcm: pcm16(100, -100, 300, -300, 5000, 0), channels: 2, bitsPerSample: 16},
+		// A single sample per channel, without deltas.
+		{pcm: pcm16(7, -7), channels: 2, bitsPerSample: 16},
+	}
+	for i, g := range golden {
+		track := &Track{SampleRate: 22050, Channels: g.channels, BitsPerSample: g.bitsPerSample, compressed: true}
+		got, err := track.decodeAudio(encodeAudio(g.pcm, g.channels, g.bitsPerSample))
+		if err != nil {
+			t.Errorf("i=%d: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(got, g.pcm) {
+			t.Errorf("i=%d: samples mismatch; expected % X, got % X", i, g.pcm, got)
+		}
+	}
+}
+
+func TestDecodeAudioSpecial(t *testing.T) {
+	// Uncompressed samples are returned as is.
+	track := &Track{SampleRate: 22050, Channels: 1, BitsPerSample: 8}
+	pcm := []byte{1, 2, 3}
+	got, err := track.decodeAudio(pcm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, pcm) {
+		t.Errorf("samples mismatch; expected % X, got % X", pcm, got)
+	}
+	// Compressed audio without samples.
+	track.compressed = true
+	got, err = track.decodeAudio([]byte{0, 0, 0, 0, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("samples mismatch; expected none, got % X", got)
+	}
+	// Stereo audio of a mono track.
+	if _, err := track.decodeAudio(encodeAudio([]byte{1, 2}, 2, 8)); err == nil {
+		t.Error("expected error for audio format mismatch")
+	}
+	// Truncated deltas.
+	data := encodeAudio(pcm16(1, 1000, -1000, 30000), 1, 16)
+	track = &Track{SampleRate: 22050, Channels: 1, BitsPerSample: 16, compressed: true}
+	if _, err := track.decodeAudio(data[:len(data)-1]); err == nil {
+		t.Error("expected error for truncated audio")
+	}
+}
@@ -0,0 +1,209 @@
+package smk
+
+import (
+	"errors"
+)
+
+// errTruncated is returned when reading past the end of a bitstream.
+var errTruncated = errors.New("bitstream truncated")
+
+// A bitReader reads the bits of a bitstream, starting with the least
+// significant bit of each byte.
+type bitReader struct {
+	buf []byte
+	// pos is the position of the next bit.
+	pos int
+	// err is set when reading past the end of buf.
+	err error
+}
+
+// readBit reads a single bit. Reading past the end of the bitstream returns 0
+// and sets br.err.
+func (br *bitReader) readBit() int {
+	if br.pos >= 8*len(br.buf) {
+		br.err = errTruncated
+		return 0
+	}
+	bit := int(br.buf[br.pos>>3]>>uint(br.pos&7)) & 1
+	br.pos++
+	return bit
+}
+
+// readBits reads n bits, the first of which is the least significant.
+func (br *bitReader) readBits(n int) (v int) {
+	for i := 0; i < n; i++ {
+		v |= br.readBit() << uint(i)
+	}
+	return v
+}
+
+// maxTreeDepth is the maximum depth of Huffman trees.
+const maxTreeDepth = 32
+
+// A huffTree is a Huffman tree stored in pre-order; each branch is followed by
+// its left (0) and right (1) subtree. A tree without nodes always decodes to 0,
+// without reading any bits.
+type huffTree struct {
+	nodes []huffNode
+}
+
+// A huffNode is a node of a Huffman tree.
+type huffNode struct {
+	branch bool
+	// skip is the number of nodes of the left subtree of a branch.
+	skip int
+	// value is the value of a leaf.
+	value int
+	// escape is the index plus one of the cache entry which provides the value
+	// of a leaf of a big tree, or 0.
+	escape int
+}
+
+// decode decodes a leaf of the tree.
+func (t *huffTree) decode(br *bitReader) *huffNode {
+	if len(t.nodes) == 0 {
+		return &huffNode{}
+	}
+	i := 0
+	for t.nodes[i].branch {
+		if br.readBit() == 1 {
+			i += t.nodes[i].skip
+		}
+		i++
+	}
+	return &t.nodes[i]
+}
+
+// readByteTree reads a Huffman tree of byte values. It is prefixed by a bit
+// which specifies if the tree is present, and followed by a 0 bit.
+func readByteTree(br *bitReader) (t *huffTree, err error) {
+	t = new(huffTree)
+	if br.readBit() == 0 {
+		return t, nil
+	}
+	err = t.readByteNode(br, 0)
+	if err != nil {
+		return nil, err
+	}
+	br.readBit()
+	return t, br.err
+}
+
+// readByteNode reads a node of a Huffman tree of byte values. Branches are
+// specified by a 1 bit and leafs by a 0 bit followed by an 8-bit value.
+func (t *huffTree) readByteNode(br *bitReader, depth int) (err error) {
+	if depth > maxTreeDepth {
+		return errors.New("Huffman tree too deep")
+	}
+	if br.readBit() == 0 {
+		t.nodes = append(t.nodes, huffNode{value: br.readBits(8)})
+		return br.err
+	}
+	i := len(t.nodes)
+	t.nodes = append(t.nodes, huffNode{branch: true})
+	err = t.readByteNode(br, depth+1)
+	if err != nil {
+		return err
+	}
+	t.nodes[i].skip = len(t.nodes) - i - 1
+	return t.readByteNode(br, depth+1)
+}
+
+// A bigTree is a Huffman tree of 16-bit values, which caches the three most
+// recently decoded values. Leafs which match one of three escape values decode
+// to the corresponding cache entry.
+type bigTree struct {
+	huffTree
+	cache [3]int
+}
+
+// readBigTree reads a Huffman tree of 16-bit values. It is prefixed by a bit
+// which specifies if the tree is present, and followed by a 0 bit.
+//
+// Big tree format:
+//    present  bit
+//    // lo and hi contain the low and high bytes of the values of leafs.
+//    lo       byteTree
+//    hi       byteTree
+//    escapes  [3]uint16
+//    nodes    []node
+//    _        bit
+func readBigTree(br *bitReader) (t *bigTree, err error) {
+	t = new(bigTree)
+	if br.readBit() == 0 {
+		return t, nil
+	}
+	lo, err := readByteTree(br)
+	if err != nil {
+		return nil, err
+	}
+	hi, err := readByteTree(br)
+	if err != nil {
+		return nil, err
+	}
+	var escapes [3]int
+	for i := range escapes {
+		escapes[i] = br.readBits(16)
+	}
+	// escLeafs contains the index plus one of the leaf of each escape value.
+	var escLeafs [3]int
+	err = t.readBigNode(br, lo, hi, escapes, &escLeafs, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i, leaf := range escLeafs {
+		if leaf != 0 {
+			t.nodes[leaf-1].escape = i + 1
+		}
+	}
+	br.readBit()
+	return t, br.err
+}
+
+// readBigNode reads a node of a Huffman tree of 16-bit values. The values of
+// leafs are decoded using the lo and hi trees.
+func (t *bigTree) readBigNode(br *bitReader, lo, hi *huffTree, escapes [3]int, escLeafs *[3]int, depth int) (err error) {
+	if depth > maxTreeDepth {
+		return errors.New("Huffman tree too deep")
+	}
+	if br.readBit() == 0 {
+		value := lo.decode(br).value | hi.decode(br).value<<8
+		for i, escape := range escapes {
+			if value == escape {
+				escLeafs[i] = len(t.nodes) + 1
+				value = 0
+				break
+			}
+		}
+		t.nodes = append(t.nodes, huffNode{value: value})
+		return br.err
+	}
+	i := len(t.nodes)
+	t.nodes = append(t.nodes, huffNode{branch: true})
+	err = t.readBigNode(br, lo, hi, escapes, escLeafs, depth+1)
+	if err != nil {
+		return err
+	}
+	t.nodes[i].skip = len(t.nodes) - i - 1
+	return t.readBigNode(br, lo, hi, escapes, escLeafs, depth+1)
+}
+
+// decode decodes a value, and updates the cache.
+func (t *bigTree) decode(br *bitReader) int {
+	node := t.huffTree.decode(br)
+	v := node.value
+	if node.escape != 0 {
+		v = t.cache[node.escape-1]
+	}
+	if v != t.cache[0] {
+		t.cache[2] = t.cache[1]
+		t.cache[1] = t.cache[0]
+		t.cache[0] = v
+	}
+	return v
+}
+
+// reset clears the cache, which is done at the start of each frame.
+func (t *bigTree) reset() {
+	t.cache = [3]int{}
+}
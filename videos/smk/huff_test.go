@@ -0,0 +1,185 @@
+package smk
+
+import (
+	"testing"
+)
+
+// A bitWriter writes a bitstream, starting with the least significant bit of
+// each byte; the reverse of bitReader.
+type bitWriter struct {
+	buf []byte
+	// n is the number of bits written.
+	n int
+}
+
+// writeBit writes a single bit.
+func (bw *bitWriter) writeBit(bit int) {
+	if bw.n%8 == 0 {
+		bw.buf = append(bw.buf, 0)
+	}
+	bw.buf[len(bw.buf)-1] |= byte(bit&1) << uint(bw.n%8)
+	bw.n++
+}
+
+// writeBits writes n bits, starting with the least significant bit of v.
+func (bw *bitWriter) writeBits(v, n int) {
+	for i := 0; i < n; i++ {
+		bw.writeBit(v >> uint(i))
+	}
+}
+
+// writeCode writes the bits of a Huffman code.
+func (bw *bitWriter) writeCode(code []int) {
+	for _, bit := range code {
+		bw.writeBit(bit)
+	}
+}
+
+// writeNodes writes the nodes of a balanced tree of n leafs in pre-order, and
+// returns the code of each leaf. The value of leaf i is written by leaf(i).
+func (bw *bitWriter) writeNodes(n int, leaf func(i int)) (codes [][]int) {
+	var write func(lo, hi int, code []int)
+	write = func(lo, hi int, code []int) {
+		if hi-lo == 1 {
+			bw.writeBit(0)
+			leaf(lo)
+			codes = append(codes, code)
+			return
+		}
+		bw.writeBit(1)
+		mid := (lo + hi) / 2
+		write(lo, mid, append(code[:len(code):len(code)], 0))
+		write(mid, hi, append(code[:len(code):len(code)], 1))
+	}
+	write(0, n, nil)
+	return codes
+}
+
+// writeByteTree writes a Huffman tree of the given distinct byte values, and
+// returns the code of each value. The tree is absent if values is empty.
+func (bw *bitWriter) writeByteTree(values []int) (codes map[int][]int) {
+	codes = make(map[int][]int)
+	if len(values) == 0 {
+		bw.writeBit(0)
+		return codes
+	}
+	bw.writeBit(1)
+	leafCodes := bw.writeNodes(len(values), func(i int) {
+		bw.writeBits(values[i], 8)
+	})
+	for i, v := range values {
+		codes[v] = leafCodes[i]
+	}
+	bw.writeBit(0)
+	return codes
+}
+
+// writeBigTree writes a Huffman tree of the given 16-bit values, and returns
+// the code of each leaf. The tree is absent if values is empty.
+func (bw *bitWriter) writeBigTree(values []int, escapes [3]int) (codes [][]int) {
+	if len(values) == 0 {
+		bw.writeBit(0)
+		return nil
+	}
+	bw.writeBit(1)
+	var los, his []int
+	for _, v := range values {
+		los = appendDistinct(los, v&0xFF)
+		his = appendDistinct(his, v>>8)
+	}
+	loCodes := bw.writeByteTree(los)
+	hiCodes := bw.writeByteTree(his)
+	for _, escape := range escapes {
+		bw.writeBits(escape, 16)
+	}
+	codes = bw.writeNodes(len(values), func(i int) {
+		bw.writeCode(loCodes[values[i]&0xFF])
+		bw.writeCode(hiCodes[values[i]>>8])
+	})
+	bw.writeBit(0)
+	return codes
+}
+
+// appendDistinct appends v to values, unless already present.
+func appendDistinct(values []int, v int) []int {
+	for _, value := range values {
+		if value == v {
+			return values
+		}
+	}
+	return append(values, v)
+}
+
+func TestBigTree(t *testing.T) {
+	bw := new(bitWriter)
+	values := []int{0x11, 0x22, 0x33, 0xE0, 0xE1, 0xE2, 0x44, 0x155}
+	codes := bw.writeBigTree(values, [3]int{0xE0, 0xE1, 0xE2})
+	// Leafs 3, 4 and 5 are escapes, which decode to the cache entries 0, 1 and
+	// 2 respectively. Each decoded value is pushed onto the cache, even if
+	// already present.
+	golden := []struct {
+		leaf  int
+		want  int
+		cache [3]int
+	}{
+		{leaf: 0, want: 0x11, cache: [3]int{0x11, 0, 0}},
+		{leaf: 1, want: 0x22, cache: [3]int{0x22, 0x11, 0}},
+		{leaf: 2, want: 0x33, cache: [3]int{0x33, 0x22, 0x11}},
+		{leaf: 5, want: 0x11, cache: [3]int{0x11, 0x33, 0x22}},
+		{leaf: 4, want: 0x33, cache: [3]int{0x33, 0x11, 0x33}},
+		// Values equal to the most recent value leave the cache unchanged.
+		{leaf: 3, want: 0x33, cache: [3]int{0x33, 0x11, 0x33}},
+		{leaf: 7, want: 0x155, cache: [3]int{0x155, 0x33, 0x11}},
+	}
+	for _, g := range golden {
+		bw.writeCode(codes[g.leaf])
+	}
+	bw.writeCode(codes[3])
+	br := &bitReader{buf: bw.buf}
+	tree, err := readBigTree(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, g := range golden {
+		got := tree.decode(br)
+		if got != g.want {
+			t.Errorf("i=%d: value mismatch; expected 0x%X, got 0x%X", i, g.want, got)
+		}
+		if tree.cache != g.cache {
+			t.Errorf("i=%d: cache mismatch; expected %X, got %X", i, g.cache, tree.cache)
+		}
+	}
+	// The cache is cleared at the start of each frame.
+	tree.reset()
+	if got := tree.decode(br); got != 0 {
+		t.Errorf("value mismatch after reset; expected 0, got 0x%X", got)
+	}
+	if br.err != nil {
+		t.Fatal(br.err)
+	}
+}
+
+func TestReadBigTreeAbsent(t *testing.T) {
+	// An absent tree decodes to 0 without reading any bits.
+	br := &bitReader{buf: []byte{0xFE}}
+	tree, err := readBigTree(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tree.decode(br); got != 0 || br.pos != 1 {
+		t.Fatalf("absent tree mismatch; expected value 0 at bit 1, got value 0x%X at bit %d", got, br.pos)
+	}
+}
+
+func TestReadByteTreeInvalid(t *testing.T) {
+	// A tree of only branches exceeds the maximum depth.
+	br := &bitReader{buf: []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}}
+	if _, err := readByteTree(br); err == nil {
+		t.Error("expected error for too deep tree")
+	}
+	// A truncated tree.
+	br = &bitReader{buf: []byte{0x03}}
+	if _, err := readByteTree(br); err == nil {
+		t.Error("expected error for truncated tree")
+	}
+}
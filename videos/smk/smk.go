@@ -0,0 +1,335 @@
+// Package smk implements a Smacker video decoder.
+//
+// Below is a description of the Smacker video format, as used by the
+// cinematics of Diablo. All integers are stored in little endian.
+//
+// Smacker format:
+//    header     Header
+//    // frameSizes contains the size of each frame. The two least significant
+//    // bits are flags; bit 0 specifies a key frame.
+//    frameSizes [frameCount]uint32
+//    // frameTypes specifies the contents of each frame. Bit 0 specifies that a
+//    // palette is present, and bit 1+n that audio of track n is present.
+//    frameTypes [frameCount]uint8
+//    // trees contains the Huffman trees of the video, in the order MMap, MClr,
+//    // Full and Type.
+//    trees      [treesSize]byte
+//    frames     [frameCount][]byte
+//
+// The frame count includes an additional ring frame, if present.
+//
+// Frame format:
+//    // pal is present if bit 0 of the frame type is set.
+//    pal       palette
+//    // audio is present for each audio track, as specified by the frame type.
+//    audio     []audio
+//    // video contains the remaining data of the frame.
+//    video     []byte
+//
+// Palette format:
+//    // size specifies the size of the palette in bytes, divided by 4.
+//    size uint8
+//    data [4*size-1]byte
+//
+// Audio format:
+//    // size specifies the size of the audio in bytes, including size.
+//    size uint32
+//    data [size-4]byte
+package smk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/fs"
+)
+
+// Header is the header of a Smacker video.
+type Header struct {
+	// Magic is "SMK2" or "SMK4".
+	Magic      [4]byte
+	Width      uint32
+	Height     uint32
+	FrameCount uint32
+	// FrameRate specifies the duration of each frame in milliseconds if
+	// positive, and in units of 10 microseconds if negative. A frame rate of 0
+	// specifies 10 frames per second.
+	FrameRate int32
+	Flags     uint32
+	// AudioSizes specifies the maximum size of the audio of each track.
+	AudioSizes [7]uint32
+	TreesSize  uint32
+	MMapSize   uint32
+	MClrSize   uint32
+	FullSize   uint32
+	TypeSize   uint32
+	// AudioRates specifies the sample rate (bits 0-23) and audio flags (bits
+	// 24-31) of each track.
+	AudioRates [7]uint32
+	_          uint32
+}
+
+// Flags of the header.
+const (
+	// flagRingFrame specifies that the video contains an additional frame, used
+	// for looping.
+	flagRingFrame = 0x01
+	// flagYInterlaced specifies that each line is followed by an empty line.
+	flagYInterlaced = 0x02
+	// flagYDoubled specifies that each line is repeated.
+	flagYDoubled = 0x04
+)
+
+// Flags of audio rates.
+const (
+	audioCompressed = 0x80000000
+	audioPresent    = 0x40000000
+	audio16Bits     = 0x20000000
+	audioStereo     = 0x10000000
+	audioBink       = 0x08000000
+)
+
+// A Track specifies the format of an audio track.
+type Track struct {
+	// The number of samples per second, or 0 if the track is not present.
+	SampleRate int
+	// The number of channels; 1 (mono) or 2 (stereo).
+	Channels int
+	// The number of bits per sample; 8 or 16.
+	BitsPerSample int
+	// compressed specifies that the samples are Huffman encoded.
+	compressed bool
+}
+
+// A Frame is a decoded frame of a video.
+type Frame struct {
+	// Image contains the pixels and palette of the frame.
+	Image *image.Paletted
+	// Audio contains the PCM samples of each audio track, in little endian
+	// and interleaved by channel.
+	Audio [7][]byte
+}
+
+// A Decoder decodes the frames of a Smacker video.
+type Decoder struct {
+	// Header is the header of the video.
+	Header Header
+	// Tracks contains the format of each audio track.
+	Tracks [7]Track
+	r      io.Reader
+	// frameNum is the number of the next frame.
+	frameNum   int
+	frameSizes []uint32
+	frameTypes []uint8
+	// Huffman trees of the video.
+	mmap, mclr, full, typ *bigTree
+	// pal is the current palette.
+	pal [256]color.RGBA
+	// pix contains the pixels of the current frame.
+	pix []uint8
+}
+
+// Open opens the Smacker video located at relSmkPath within fsys, and returns a
+// decoder which reads from it. The returned Closer closes the video file, and
+// must be called when the decoder is no longer needed.
+func Open(fsys fs.FS, relSmkPath string) (d *Decoder, c io.Closer, err error) {
+	f, err := fsys.Open(relSmkPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	d, err = NewDecoder(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("smk.Open: unable to decode %q: %v", relSmkPath, err)
+	}
+	return d, f, nil
+}
+
+// NewDecoder returns a new decoder which reads a Smacker video from r. The
+// header, frame sizes and Huffman trees are read immediately.
+func NewDecoder(r io.Reader) (d *Decoder, err error) {
+	d = &Decoder{r: r}
+	err = binary.Read(r, binary.LittleEndian, &d.Header)
+	if err != nil {
+		return nil, err
+	}
+	hdr := &d.Header
+	if magic := string(hdr.Magic[:]); magic != "SMK2" && magic != "SMK4" {
+		return nil, fmt.Errorf("invalid magic %q", magic)
+	}
+	if hdr.Width == 0 || hdr.Height == 0 || hdr.Width > 4096 || hdr.Height > 4096 {
+		return nil, fmt.Errorf("invalid dimensions %dx%d", hdr.Width, hdr.Height)
+	}
+	for i, rate := range hdr.AudioRates {
+		if rate&0xFFFFFF == 0 && rate&audioPresent == 0 {
+			continue
+		}
+		if rate&audioBink != 0 {
+			return nil, fmt.Errorf("unsupported Bink audio in track %d", i)
+		}
+		track := &d.Tracks[i]
+		track.SampleRate = int(rate & 0xFFFFFF)
+		track.Channels = 1
+		if rate&audioStereo != 0 {
+			track.Channels = 2
+		}
+		track.BitsPerSample = 8
+		if rate&audio16Bits != 0 {
+			track.BitsPerSample = 16
+		}
+		track.compressed = rate&audioCompressed != 0
+	}
+
+	// Read frame sizes and types.
+	frameCount := int(hdr.FrameCount)
+	if hdr.Flags&flagRingFrame != 0 {
+		frameCount++
+	}
+	if frameCount > 1<<20 {
+		return nil, fmt.Errorf("invalid frame count %d", frameCount)
+	}
+	d.frameSizes = make([]uint32, frameCount)
+	err = binary.Read(r, binary.LittleEndian, d.frameSizes)
+	if err != nil {
+		return nil, err
+	}
+	d.frameTypes = make([]uint8, frameCount)
+	_, err = io.ReadFull(r, d.frameTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read Huffman trees.
+	if hdr.TreesSize > 1<<24 {
+		return nil, fmt.Errorf("invalid trees size %d", hdr.TreesSize)
+	}
+	buf := make([]byte, hdr.TreesSize)
+	_, err = io.ReadFull(r, buf)
+	if err != nil {
+		return nil, err
+	}
+	br := &bitReader{buf: buf}
+	for _, t := range []**bigTree{&d.mmap, &d.mclr, &d.full, &d.typ} {
+		*t, err = readBigTree(br)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read Huffman trees: %v", err)
+		}
+	}
+	d.pix = make([]uint8, hdr.Width*hdr.Height)
+	return d, nil
+}
+
+// FrameRate returns the number of frames per second.
+func (d *Decoder) FrameRate() float64 {
+	switch rate := d.Header.FrameRate; {
+	case rate > 0:
+		return 1000 / float64(rate)
+	case rate < 0:
+		return 100000 / float64(-rate)
+	}
+	return 10
+}
+
+// Bounds returns the dimensions of the decoded frames. The height is doubled
+// for interlaced and line doubled videos.
+func (d *Decoder) Bounds() image.Rectangle {
+	w, h := int(d.Header.Width), int(d.Header.Height)
+	if d.Header.Flags&(flagYInterlaced|flagYDoubled) != 0 {
+		h *= 2
+	}
+	return image.Rect(0, 0, w, h)
+}
+
+// NextFrame decodes the next frame of the video. It returns io.EOF after the
+// last frame. The ring frame, if present, is skipped.
+func (d *Decoder) NextFrame() (frame *Frame, err error) {
+	if d.frameNum >= int(d.Header.FrameCount) {
+		return nil, io.EOF
+	}
+	size := d.frameSizes[d.frameNum] &^ 3
+	typ := d.frameTypes[d.frameNum]
+	if size > 1<<26 {
+		return nil, fmt.Errorf("invalid size %d of frame %d", size, d.frameNum)
+	}
+	buf := make([]byte, size)
+	_, err = io.ReadFull(d.r, buf)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	frame = new(Frame)
+	err = d.decodeFrame(frame, buf, typ)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode frame %d: %v", d.frameNum, err)
+	}
+	d.frameNum++
+	return frame, nil
+}
+
+// decodeFrame decodes the palette, audio and video of a frame.
+func (d *Decoder) decodeFrame(frame *Frame, buf []byte, typ uint8) (err error) {
+	if typ&1 != 0 {
+		if len(buf) < 1 {
+			return errors.New("palette truncated")
+		}
+		size := 4 * int(buf[0])
+		if size == 0 || size > len(buf) {
+			return fmt.Errorf("invalid palette size %d", size)
+		}
+		d.decodePal(buf[1:size])
+		buf = buf[size:]
+	}
+	for i := range d.Tracks {
+		if typ&(2<<uint(i)) == 0 {
+			continue
+		}
+		if len(buf) < 4 {
+			return fmt.Errorf("audio of track %d truncated", i)
+		}
+		size := int(binary.LittleEndian.Uint32(buf))
+		if size < 4 || size > len(buf) {
+			return fmt.Errorf("invalid audio size %d of track %d", size, i)
+		}
+		if d.Tracks[i].SampleRate != 0 {
+			frame.Audio[i], err = d.Tracks[i].decodeAudio(buf[4:size])
+			if err != nil {
+				return fmt.Errorf("unable to decode audio of track %d: %v", i, err)
+			}
+		}
+		buf = buf[size:]
+	}
+	err = d.decodeVideo(buf)
+	if err != nil {
+		return err
+	}
+	frame.Image = d.image()
+	return nil
+}
+
+// image returns an image of the current frame.
+func (d *Decoder) image() *image.Paletted {
+	pal := make(color.Palette, len(d.pal))
+	for i, c := range d.pal {
+		pal[i] = c
+	}
+	img := image.NewPaletted(d.Bounds(), pal)
+	w, h := int(d.Header.Width), int(d.Header.Height)
+	for y := 0; y < h; y++ {
+		line := d.pix[y*w : (y+1)*w]
+		switch {
+		case d.Header.Flags&flagYDoubled != 0:
+			copy(img.Pix[2*y*img.Stride:], line)
+			copy(img.Pix[(2*y+1)*img.Stride:], line)
+		case d.Header.Flags&flagYInterlaced != 0:
+			copy(img.Pix[2*y*img.Stride:], line)
+		default:
+			copy(img.Pix[y*img.Stride:], line)
+		}
+	}
+	return img
+}
@@ -0,0 +1,95 @@
+package smk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"io"
+	"testing"
+)
+
+func TestNextFrame(t *testing.T) {
+	// Huffman trees; the type tree specifies a run of 2048 fill blocks of
+	// color 5, or a single full block.
+	bw := new(bitWriter)
+	bw.writeBigTree(nil, [3]int{})
+	bw.writeBigTree(nil, [3]int{})
+	fullCodes := bw.writeBigTree([]int{0x0201, 0x0403}, [3]int{0xFFFF, 0xFFFF, 0xFFFF})
+	typeCodes := bw.writeBigTree([]int{0x0500 | 63<<2 | blockFill, blockFull}, [3]int{0xFFFF, 0xFFFF, 0xFFFF})
+	trees := bw.buf
+
+	// Frame 0 contains a palette, audio and video.
+	pal := []byte{0, 0x84, 0x3F, 0x00, 0x00, 0, 0, 0}
+	pal[0] = byte(len(pal) / 4)
+	pcm := []byte{100, 101, 102, 103, 104}
+	audio := append(make([]byte, 4), encodeAudio(pcm, 1, 8)...)
+	binary.LittleEndian.PutUint32(audio, uint32(len(audio)))
+	bw = new(bitWriter)
+	bw.writeCode(typeCodes[0])
+	frame0 := append(append(pal, audio...), bw.buf...)
+
+	// Frame 1 contains video; a full block followed by fill blocks.
+	bw = new(bitWriter)
+	bw.writeCode(typeCodes[1])
+	for y := 0; y < 4; y++ {
+		bw.writeCode(fullCodes[0])
+		bw.writeCode(fullCodes[1])
+	}
+	bw.writeCode(typeCodes[0])
+	frame1 := bw.buf
+
+	var hdr Header
+	copy(hdr.Magic[:], "SMK2")
+	hdr.Width, hdr.Height, hdr.FrameCount, hdr.FrameRate = 8, 4, 2, -6667
+	hdr.TreesSize = uint32(len(trees))
+	hdr.AudioRates[0] = 22050 | audioCompressed | audioPresent
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, &hdr)
+	// The flags of the frame sizes are ignored.
+	binary.Write(buf, binary.LittleEndian, []uint32{uint32(len(frame0))<<2 | 1, uint32(len(frame1)) << 2})
+	buf.Write([]byte{1 | 2, 0})
+	buf.Write(trees)
+	// The frame sizes are multiples of 4.
+	for _, frame := range [][]byte{frame0, frame1} {
+		buf.Write(frame)
+		buf.Write(make([]byte, (len(frame)+3)&^3-len(frame)))
+	}
+	data := buf.Bytes()
+	binary.LittleEndian.PutUint32(data[binary.Size(hdr):], uint32((len(frame0)+3)&^3)|1)
+	binary.LittleEndian.PutUint32(data[binary.Size(hdr)+4:], uint32((len(frame1)+3)&^3))
+
+	d, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Tracks[0].SampleRate != 22050 || d.Tracks[0].Channels != 1 || d.Tracks[0].BitsPerSample != 8 {
+		t.Errorf("track mismatch; got %+v", d.Tracks[0])
+	}
+	if rate := d.FrameRate(); rate < 14.99 || rate > 15.0 {
+		t.Errorf("frame rate mismatch; expected 15, got %v", rate)
+	}
+	frame, err := d.NextFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(frame.Audio[0], pcm) {
+		t.Errorf("samples mismatch; expected % X, got % X", pcm, frame.Audio[0])
+	}
+	if want := bytes.Repeat([]byte{5}, 32); !bytes.Equal(frame.Image.Pix, want) {
+		t.Errorf("frame 0 pixels mismatch; expected % X, got % X", want, frame.Image.Pix)
+	}
+	if want := (color.RGBA{R: 0xFF, A: 0xFF}); frame.Image.Palette[5] != want {
+		t.Errorf("color mismatch; expected %v, got %v", want, frame.Image.Palette[5])
+	}
+	frame, err = d.NextFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bytes.Repeat([]byte{3, 4, 1, 2, 5, 5, 5, 5}, 4)
+	if !bytes.Equal(frame.Image.Pix, want) {
+		t.Errorf("frame 1 pixels mismatch; expected % X, got % X", want, frame.Image.Pix)
+	}
+	if _, err := d.NextFrame(); err != io.EOF {
+		t.Errorf("error mismatch; expected io.EOF, got %v", err)
+	}
+}
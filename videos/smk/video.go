@@ -0,0 +1,178 @@
+package smk
+
+import (
+	"image/color"
+)
+
+// decodePal decodes the palette data of a frame, which updates the current
+// palette using the following commands:
+//    1nnnnnnn                 keep the next n+1 colors.
+//    01nnnnnn oooooooo        copy n+1 colors of the previous palette, starting
+//                             at offset o.
+//    00rrrrrr 00gggggg 00bbbbbb
+//                             set the next color using 6-bit color components.
+func (d *Decoder) decodePal(data []byte) {
+	old := d.pal
+	for i := 0; i < len(d.pal) && len(data) > 0; {
+		cmd := data[0]
+		switch {
+		case cmd&0x80 != 0:
+			i += int(cmd&0x7F) + 1
+			data = data[1:]
+		case cmd&0x40 != 0:
+			if len(data) < 2 {
+				return
+			}
+			n := int(cmd&0x3F) + 1
+			off := int(data[1])
+			for j := 0; j < n && i < len(d.pal) && off+j < len(old); j++ {
+				d.pal[i] = old[off+j]
+				i++
+			}
+			data = data[2:]
+		default:
+			if len(data) < 3 {
+				return
+			}
+			d.pal[i] = color.RGBA{
+				R: expand6(data[0]),
+				G: expand6(data[1]),
+				B: expand6(data[2]),
+				A: 0xFF,
+			}
+			i++
+			data = data[3:]
+		}
+	}
+}
+
+// expand6 expands a 6-bit color component to 8 bits.
+func expand6(c byte) uint8 {
+	c &= 0x3F
+	return c<<2 | c>>4
+}
+
+// Block types of the video.
+const (
+	// blockMono specifies a block of two colors, selected by a bitmap.
+	blockMono = 0
+	// blockFull specifies a block of 16 colors.
+	blockFull = 1
+	// blockSkip specifies a block which is unchanged from the previous frame.
+	blockSkip = 2
+	// blockFill specifies a block of a single color.
+	blockFill = 3
+)
+
+// blockRuns maps from the run bits of block types to the number of consecutive
+// blocks of the type.
+var blockRuns = [64]int{
+	1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16,
+	17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32,
+	33, 34, 35, 36, 37, 38, 39, 40, 41, 42, 43, 44, 45, 46, 47, 48,
+	49, 50, 51, 52, 53, 54, 55, 56, 57, 58, 59, 128, 256, 512, 1024, 2048,
+}
+
+// decodeVideo decodes the video data of a frame, which updates the pixels of
+// the current frame. The frame is split into blocks of 4x4 pixels, in raster
+// order. Each run of blocks is specified by a value of the Type tree:
+//    bits 0-1:  block type.
+//    bits 2-7:  index into blockRuns.
+//    bits 8-15: color of fill blocks.
+func (d *Decoder) decodeVideo(data []byte) (err error) {
+	br := &bitReader{buf: data}
+	for _, t := range []*bigTree{d.mmap, d.mclr, d.full, d.typ} {
+		t.reset()
+	}
+	w := int(d.Header.Width)
+	bw := w / 4
+	blockCount := bw * (int(d.Header.Height) / 4)
+	v4 := string(d.Header.Magic[:]) == "SMK4"
+	for block := 0; block < blockCount; {
+		typ := d.typ.decode(br)
+		run := blockRuns[(typ>>2)&0x3F]
+		// mode specifies the layout of full blocks in Smacker 4 videos;
+		// 0 (16 colors), 1 (4 colors of 2x2 pixels) or 2 (8 colors of 2x1
+		// pixels).
+		mode := 0
+		if typ&3 == blockFull && v4 {
+			if br.readBit() == 1 {
+				mode = 1
+			} else if br.readBit() == 1 {
+				mode = 2
+			}
+		}
+		for ; run > 0 && block < blockCount; run-- {
+			// pix contains the pixels of the block, with a stride of w.
+			pix := d.pix[(block/bw)*4*w+(block%bw)*4:]
+			switch typ & 3 {
+			case blockMono:
+				clr := d.mclr.decode(br)
+				bitmap := d.mmap.decode(br)
+				hi, lo := uint8(clr>>8), uint8(clr)
+				for y := 0; y < 4; y++ {
+					for x := 0; x < 4; x++ {
+						if bitmap&1 != 0 {
+							pix[y*w+x] = hi
+						} else {
+							pix[y*w+x] = lo
+						}
+						bitmap >>= 1
+					}
+				}
+			case blockFull:
+				d.decodeFull(br, pix, w, mode)
+			case blockSkip:
+			case blockFill:
+				c := uint8(typ >> 8)
+				for y := 0; y < 4; y++ {
+					for x := 0; x < 4; x++ {
+						pix[y*w+x] = c
+					}
+				}
+			}
+			block++
+		}
+		if br.err != nil {
+			return br.err
+		}
+	}
+	return nil
+}
+
+// decodeFull decodes a full block, using the given mode.
+func (d *Decoder) decodeFull(br *bitReader, pix []uint8, w, mode int) {
+	switch mode {
+	case 0:
+		// Each pair of values specifies a line of 4 pixels, right half first.
+		for y := 0; y < 4; y++ {
+			right := d.full.decode(br)
+			left := d.full.decode(br)
+			line := pix[y*w:]
+			line[0], line[1] = uint8(left), uint8(left>>8)
+			line[2], line[3] = uint8(right), uint8(right>>8)
+		}
+	case 1:
+		// Each value specifies 2 lines of 4 pixels, using 2x2 pixels per color.
+		for y := 0; y < 4; y += 2 {
+			v := d.full.decode(br)
+			for dy := 0; dy < 2; dy++ {
+				line := pix[(y+dy)*w:]
+				line[0], line[1] = uint8(v), uint8(v)
+				line[2], line[3] = uint8(v>>8), uint8(v>>8)
+			}
+		}
+	case 2:
+		// Each pair of values specifies 2 identical lines of 4 pixels, right
+		// half first.
+		for y := 0; y < 4; y += 2 {
+			right := d.full.decode(br)
+			left := d.full.decode(br)
+			for dy := 0; dy < 2; dy++ {
+				line := pix[(y+dy)*w:]
+				line[0], line[1] = uint8(left), uint8(left>>8)
+				line[2], line[3] = uint8(right), uint8(right>>8)
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package smk
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+)
+
+func TestDecodePal(t *testing.T) {
+	d := new(Decoder)
+	for i := range d.pal {
+		d.pal[i] = color.RGBA{R: uint8(i), G: uint8(i), B: uint8(i), A: 0xFF}
+	}
+	old := d.pal
+	data := []byte{
+		// Keep colors 0 and 1.
+		0x81,
+		// Set color 2.
+		0x3F, 0x00, 0x20,
+		// Copy colors 2 and 3 of the previous palette to colors 3 and 4.
+		0x41, 0x02,
+		// Set color 5.
+		0x01, 0x02, 0x03,
+	}
+	d.decodePal(data)
+	want := old
+	want[2] = color.RGBA{R: 0xFF, G: 0x00, B: 0x82, A: 0xFF}
+	want[3] = old[2]
+	want[4] = old[3]
+	want[5] = color.RGBA{R: 0x04, G: 0x08, B: 0x0C, A: 0xFF}
+	for i := range want {
+		if d.pal[i] != want[i] {
+			t.Errorf("color %d mismatch; expected %v, got %v", i, want[i], d.pal[i])
+		}
+	}
+}
+
+// newTree returns a Huffman tree of the given 16-bit values, and the code of
+// each value.
+func newTree(t *testing.T, values ...int) (tree *bigTree, codes [][]int) {
+	t.Helper()
+	bw := new(bitWriter)
+	codes = bw.writeBigTree(values, [3]int{0xFFFF, 0xFFFF, 0xFFFF})
+	tree, err := readBigTree(&bitReader{buf: bw.buf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree, codes
+}
+
+// newTestDecoder returns a decoder of a video of the given dimensions, whose
+// pixels are 0xEE. The mono blocks use colors 4 and 9, the bitmap 0x8421 (the
+// diagonal), and full blocks the values 0x0201 and 0x0403.
+func newTestDecoder(t *testing.T, magic string, width, height int, types ...int) (d *Decoder, typeCodes, fullCodes [][]int) {
+	d = &Decoder{pix: bytes.Repeat([]byte{0xEE}, width*height)}
+	copy(d.Header.Magic[:], magic)
+	d.Header.Width, d.Header.Height = uint32(width), uint32(height)
+	d.mclr, _ = newTree(t, 0x0904)
+	d.mmap, _ = newTree(t, 0x8421)
+	d.full, fullCodes = newTree(t, 0x0201, 0x0403)
+	d.typ, typeCodes = newTree(t, types...)
+	return d, typeCodes, fullCodes
+}
+
+func TestDecodeVideo(t *testing.T) {
+	// An 8x8 video of 4 blocks; a mono, full, skip and fill block of color 7.
+	d, typeCodes, fullCodes := newTestDecoder(t, "SMK2", 8, 8, blockMono, blockFull, blockSkip, 0x0700|blockFill)
+	bw := new(bitWriter)
+	bw.writeCode(typeCodes[0])
+	bw.writeCode(typeCodes[1])
+	// Each line of the full block is specified by its right half followed by
+	// its left half.
+	for y := 0; y < 4; y++ {
+		bw.writeCode(fullCodes[0])
+		bw.writeCode(fullCodes[1])
+	}
+	bw.writeCode(typeCodes[2])
+	bw.writeCode(typeCodes[3])
+	err := d.decodeVideo(bw.buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		9, 4, 4, 4, 3, 4, 1, 2,
+		4, 9, 4, 4, 3, 4, 1, 2,
+		4, 4, 9, 4, 3, 4, 1, 2,
+		4, 4, 4, 9, 3, 4, 1, 2,
+		0xEE, 0xEE, 0xEE, 0xEE, 7, 7, 7, 7,
+		0xEE, 0xEE, 0xEE, 0xEE, 7, 7, 7, 7,
+		0xEE, 0xEE, 0xEE, 0xEE, 7, 7, 7, 7,
+		0xEE, 0xEE, 0xEE, 0xEE, 7, 7, 7, 7,
+	}
+	if !bytes.Equal(d.pix, want) {
+		t.Errorf("pixels mismatch; expected % X, got % X", want, d.pix)
+	}
+
+	// Truncated video data.
+	if err := d.decodeVideo(bw.buf[:1]); err == nil {
+		t.Error("expected error for truncated video")
+	}
+}
+
+func TestDecodeVideoSMK4(t *testing.T) {
+	// A 4x8 video of 2 full blocks, whose mode is specified by 1 (2x2 pixels)
+	// and 01 (2x1 pixels) respectively.
+	d, _, fullCodes := newTestDecoder(t, "SMK4", 4, 8, blockFull)
+	bw := new(bitWriter)
+	bw.writeBit(1)
+	bw.writeCode(fullCodes[0])
+	bw.writeCode(fullCodes[1])
+	bw.writeBit(0)
+	bw.writeBit(1)
+	for y := 0; y < 4; y += 2 {
+		bw.writeCode(fullCodes[0])
+		bw.writeCode(fullCodes[1])
+	}
+	err := d.decodeVideo(bw.buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		1, 1, 2, 2,
+		1, 1, 2, 2,
+		3, 3, 4, 4,
+		3, 3, 4, 4,
+		3, 4, 1, 2,
+		3, 4, 1, 2,
+		3, 4, 1, 2,
+		3, 4, 1, 2,
+	}
+	if !bytes.Equal(d.pix, want) {
+		t.Errorf("pixels mismatch; expected % X, got % X", want, d.pix)
+	}
+}